@@ -31,3 +31,9 @@ func SettleBaccarat(ctx context.Context, grpcClient *google_grpc.ClientConn, req
 	client := v1.NewRtpApiClient(grpcClient)
 	return client.SettleBaccarat(ctx, req)
 }
+
+// baccarat重置库存
+func ResetBaccarat(ctx context.Context, grpcClient *google_grpc.ClientConn, req *v1.ResetBaccaratRequest) (*v1.ResetBaccaratReply, error) {
+	client := v1.NewRtpApiClient(grpcClient)
+	return client.ResetBaccarat(ctx, req)
+}
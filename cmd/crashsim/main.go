@@ -0,0 +1,74 @@
+// crashsim 离线验证飞机类游戏的坠机曲线
+//
+//	crashsim -config curves.json -rounds 10000000 -targets 2,10
+//
+// 配置为consul中aigc/crash/curves的value，不指定时验证内置的默认曲线。
+// 配置校验失败或任意档位认证失败时退出码为1。
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/card-engine/game_common/gamehub/utils"
+	"github.com/card-engine/game_common/gamehub/utils/crashsim"
+)
+
+func main() {
+	configPath := flag.String("config", "", "crash curve json file, default built-in curves")
+	rounds := flag.Int("rounds", crashsim.DefaultRounds, "rounds per tier")
+	workers := flag.Int("workers", 0, "parallel workers, default NumCPU")
+	targets := flag.String("targets", "", "comma separated extra cashout targets")
+	z := flag.Float64("z", crashsim.DefaultConfidenceZ, "z value of the confidence interval")
+	tolerance := flag.Float64("tolerance", utils.DefaultCrashRtpTolerance, "allowed gap between expected and tier rtp")
+	seed := flag.Uint64("seed", 0, "random seed, default random")
+	asJSON := flag.Bool("json", false, "print report as json")
+	flag.Parse()
+
+	table := utils.GetCrashCurves()
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			fatal(err)
+		}
+		if table, err = utils.ParseCrashCurves(data, *tolerance); err != nil {
+			fatal(err)
+		}
+	}
+
+	opts := crashsim.Options{Rounds: *rounds, Workers: *workers, ConfidenceZ: *z, Seed: *seed}
+	if *targets != "" {
+		for _, s := range strings.Split(*targets, ",") {
+			m, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				fatal(fmt.Errorf("invalid target %q", s))
+			}
+			opts.Targets = append(opts.Targets, m)
+		}
+	}
+
+	report := crashsim.Run(table.Curves(), opts)
+	var err error
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if !report.Pass() {
+		os.Exit(1)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "crashsim:", err)
+	os.Exit(1)
+}
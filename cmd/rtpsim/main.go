@@ -0,0 +1,77 @@
+// rtpsim 离线验证slot的RtpConfig
+//
+//	rtpsim -config 98.json -samples pg_spin_98.csv -spins 10000000
+//
+// 配置为consul中aigc/<brand>/<gi>的value，样本为<brand>_spin_<gi>表导出的id,rate,gameType。
+// 任意档位认证失败时退出码为1。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/card-engine/game_common/slot/sim"
+)
+
+func main() {
+	configPath := flag.String("config", "", "rtp config json file")
+	samplesPath := flag.String("samples", "", "sample dump file (.csv or .json)")
+	spins := flag.Int("spins", sim.DefaultSpins, "spins per tier")
+	workers := flag.Int("workers", 0, "parallel workers, default NumCPU")
+	tiers := flag.String("tiers", "", "comma separated tiers to simulate, default all")
+	z := flag.Float64("z", sim.DefaultConfidenceZ, "z value of the confidence interval")
+	tolerance := flag.Float64("tolerance", sim.DefaultTolerance, "allowed gap between expected and nominal rtp")
+	seed := flag.Uint64("seed", 0, "random seed for reproducible runs, 0 means random")
+	asJSON := flag.Bool("json", false, "print report as json")
+	flag.Parse()
+
+	if *configPath == "" || *samplesPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config, err := sim.LoadConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+	samples, err := sim.LoadSamples(*samplesPath)
+	if err != nil {
+		fatal(err)
+	}
+	simulator, err := sim.New(config, samples)
+	if err != nil {
+		fatal(err)
+	}
+
+	opts := sim.Options{Spins: *spins, Workers: *workers, ConfidenceZ: *z, Tolerance: *tolerance, Seed: *seed}
+	if *tiers != "" {
+		for _, tier := range strings.Split(*tiers, ",") {
+			if tier = strings.TrimSpace(tier); tier != "" {
+				opts.Tiers = append(opts.Tiers, tier)
+			}
+		}
+	}
+
+	report, err := simulator.Run(opts)
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if !report.Pass() {
+		os.Exit(1)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "rtpsim:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,74 @@
+// 机器人: 玩家少的多人房间(例如飞机类)里按配置的分布下注和提现，让下注列表看起来有人在玩。
+// 机器人不会访问钱包，房间通过types.IsBot区分。
+package bot
+
+import (
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/player"
+	"github.com/gofiber/contrib/websocket"
+)
+
+// 机器人余额，只用于展示
+const botBalance = 1000000
+
+// 实现types.PlayerImp和types.BotImp，所有钱包相关的方法都是空操作，发送的消息直接丢弃
+type Bot struct {
+	appId    string
+	playerId string
+	currency string
+	rtp      string
+	nickname string
+	avatar   string
+
+	room        types.RoomImp
+	roomManager types.RoomManagerImp
+}
+
+func (b *Bot) IsBot() bool         { return true }
+func (b *Bot) GetNickname() string { return b.nickname }
+func (b *Bot) GetAvatar() string   { return b.avatar }
+
+func (b *Bot) SetConn(conn *websocket.Conn) {}
+func (b *Bot) GetConn() *websocket.Conn     { return nil }
+func (b *Bot) CloseConn()                   {}
+func (b *Bot) IsConnect() bool              { return true }
+
+func (b *Bot) SetRoom(room types.RoomImp) { b.room = room }
+func (b *Bot) GetRoom() types.RoomImp     { return b.room }
+func (b *Bot) ExitRoom(isDisconnect bool) error {
+	b.room = nil
+	return nil
+}
+
+func (b *Bot) GetRoomManager() types.RoomManagerImp            { return b.roomManager }
+func (b *Bot) SetRoomManager(roomManager types.RoomManagerImp) { b.roomManager = roomManager }
+
+func (b *Bot) GetBalance() float64                                          { return botBalance }
+func (b *Bot) SetBalanceByBalanceReply(balanceReply *v1.BalanceReply) error { return nil }
+func (b *Bot) SetBalanceByWinReply(winReply *v1.WinReply) error             { return nil }
+func (b *Bot) SetBalanceByBetReply(betReply *v1.BetReply) error             { return nil }
+func (b *Bot) SetBalanceByRefundReply(refundReply *v1.RefundReply) error    { return nil }
+
+func (b *Bot) GetPlayerIdent() string { return b.appId + "-" + b.playerId }
+func (b *Bot) GetPlayerInfo() *player.PlayerInfo {
+	return &player.PlayerInfo{
+		RTP:      b.rtp,
+		AppID:    b.appId,
+		PlayerID: b.playerId,
+		Currency: b.currency,
+		Balance:  botBalance,
+	}
+}
+func (b *Bot) GetPlayerId() string { return b.playerId }
+func (b *Bot) GetAppId() string    { return b.appId }
+func (b *Bot) GetCurrency() string { return b.currency }
+func (b *Bot) GetLang() string     { return "en" }
+func (b *Bot) GetRtpStr() string   { return b.rtp }
+func (b *Bot) GetRtp() float64     { return 0 }
+
+func (b *Bot) SendString(msg string) error  { return nil }
+func (b *Bot) SendBinary(data []byte) error { return nil }
+
+var _ types.BotImp = (*Bot)(nil)
+var _ types.ProfileImp = (*Bot)(nil)
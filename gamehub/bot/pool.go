@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+
+	"github.com/card-engine/game_common/gamehub/spribe"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/utils"
+)
+
+type Config struct {
+	MinBots       int     // 每局最少几个机器人下注
+	MaxBots       int     // 每局最多几个机器人下注
+	PoolSize      int     // 每个房间的机器人数量，默认为MaxBots的3倍
+	SecondBetRate float64 // 同时下第二注的概率
+	BetSize       Sampler // 下注金额
+	CashoutTarget Sampler // 自动提现倍数
+	Seed          uint64  // 0表示随机
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MinBots:       5,
+		MaxBots:       30,
+		SecondBetRate: 0.3,
+		BetSize:       LogUniform{Min: 1, Max: 100, Step: 0.1},
+		CashoutTarget: Pareto{Min: 1.1, Alpha: 1.2, Max: 100},
+	}
+}
+
+// 机器人的一次下注
+type Bet struct {
+	Bot         *Bot
+	Index       int // 第几个下注框
+	Amount      float64
+	AutoCashout float64
+	Delay       float64 // 在下注阶段的什么时候下注，[0,1)
+}
+
+// 一个房间的机器人，不是并发安全的，由房间的局循环调用
+type Pool struct {
+	cfg  Config
+	bots []*Bot
+	rng  *rand.Rand
+}
+
+func NewPool(args *types.RtpRoomArgs, cfg Config) *Pool {
+	if cfg.MaxBots < cfg.MinBots {
+		cfg.MaxBots = cfg.MinBots
+	}
+	if cfg.PoolSize < cfg.MaxBots {
+		cfg.PoolSize = cfg.MaxBots * 3
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	// 同一个房间每次生成相同的机器人，重启后名字不变
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s|%s", args.Appid, args.Rtp, args.Currency)
+	names := rand.New(rand.NewPCG(h.Sum64(), 0))
+
+	p := &Pool{cfg: cfg, rng: rand.New(rand.NewPCG(seed, h.Sum64()))}
+	for i := 0; i < cfg.PoolSize; i++ {
+		playerId := fmt.Sprintf("bot_%08x", names.Uint32())
+		p.bots = append(p.bots, &Bot{
+			appId:    args.Appid,
+			playerId: playerId,
+			currency: args.Currency,
+			rtp:      args.Rtp,
+			nickname: fmt.Sprintf("%s_%d", utils.BotNicknames[names.IntN(len(utils.BotNicknames))], names.IntN(99999)),
+			avatar:   spribe.GenerateSpribeAvatarFilename(args.Appid, playerId),
+		})
+	}
+	return p
+}
+
+func (p *Pool) Bots() []*Bot {
+	return p.bots
+}
+
+// 生成这一局机器人的下注，maxBetsPerPlayer为房间允许的下注框数量
+func (p *Pool) RoundBets(maxBetsPerPlayer int) []*Bet {
+	if len(p.bots) == 0 || p.cfg.BetSize == nil || p.cfg.CashoutTarget == nil {
+		return nil
+	}
+	n := p.cfg.MinBots
+	if p.cfg.MaxBots > p.cfg.MinBots {
+		n += p.rng.IntN(p.cfg.MaxBots - p.cfg.MinBots + 1)
+	}
+
+	var bets []*Bet
+	for _, i := range p.rng.Perm(len(p.bots))[:n] {
+		slots := 1
+		if maxBetsPerPlayer > 1 && p.rng.Float64() < p.cfg.SecondBetRate {
+			slots = 2
+		}
+		for index := 0; index < slots; index++ {
+			bets = append(bets, &Bet{
+				Bot:         p.bots[i],
+				Index:       index,
+				Amount:      p.cfg.BetSize.Sample(p.rng),
+				AutoCashout: max(p.cfg.CashoutTarget.Sample(p.rng), 1.01),
+				Delay:       p.rng.Float64(),
+			})
+		}
+	}
+	return bets
+}
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+var testArgs = &types.RtpRoomArgs{Appid: "app", Rtp: "97", Currency: "USD"}
+
+func TestPoolBots(t *testing.T) {
+	p := NewPool(testArgs, Config{MinBots: 2, MaxBots: 4, BetSize: LogUniform{Min: 1, Max: 10, Step: 0.5}, CashoutTarget: Pareto{Min: 1.1, Alpha: 1, Max: 50}, Seed: 1})
+	if len(p.Bots()) != 12 {
+		t.Fatalf("got %d bots", len(p.Bots()))
+	}
+
+	// 同一个房间的机器人每次都一样
+	again := NewPool(testArgs, Config{MinBots: 2, MaxBots: 4, Seed: 2})
+	for i, b := range p.Bots() {
+		if b.GetPlayerId() != again.Bots()[i].GetPlayerId() || b.GetNickname() != again.Bots()[i].GetNickname() {
+			t.Fatal("bots should be stable per room")
+		}
+		if !types.IsBot(b) || !strings.HasPrefix(b.GetPlayerIdent(), "app-bot_") || !strings.HasPrefix(b.GetAvatar(), "av-") {
+			t.Fatalf("unexpected bot %+v", b)
+		}
+	}
+
+	for round := 0; round < 100; round++ {
+		bets := p.RoundBets(2)
+		players := map[string]bool{}
+		for _, b := range bets {
+			players[b.Bot.GetPlayerId()] = true
+			if b.Amount < 0.5 || b.Amount > 10 || b.AutoCashout < 1.01 || b.AutoCashout > 50 || b.Delay < 0 || b.Delay >= 1 {
+				t.Fatalf("unexpected bet %+v", b)
+			}
+		}
+		if len(players) < 2 || len(players) > 4 {
+			t.Fatalf("got %d bots in round", len(players))
+		}
+	}
+}
+
+func TestSamplers(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	w := Weighted{Values: []float64{1, 5, 25}, Weights: []float64{0, 1, 0}}
+	for i := 0; i < 100; i++ {
+		if v := w.Sample(r); v != 5 {
+			t.Fatalf("weighted sample %v", v)
+		}
+	}
+
+	// P(X > 2) = (1/2)^1
+	p := Pareto{Min: 1, Alpha: 1}
+	n := 0
+	for i := 0; i < 100000; i++ {
+		if p.Sample(r) > 2 {
+			n++
+		}
+	}
+	if n < 49000 || n > 51000 {
+		t.Fatalf("pareto tail %d", n)
+	}
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// 随机分布，用于下注金额和提现倍数
+type Sampler interface {
+	Sample(r *rand.Rand) float64
+}
+
+// 对数均匀分布，小额下注多、大额下注少，结果按Step取整
+type LogUniform struct {
+	Min  float64
+	Max  float64
+	Step float64
+}
+
+func (s LogUniform) Sample(r *rand.Rand) float64 {
+	v := math.Exp(math.Log(s.Min) + r.Float64()*(math.Log(s.Max)-math.Log(s.Min)))
+	if s.Step > 0 {
+		v = math.Max(math.Round(v/s.Step)*s.Step, s.Step)
+	}
+	return v
+}
+
+// 按权重从固定的几个值中选一个，例如筹码面额
+type Weighted struct {
+	Values  []float64
+	Weights []float64
+}
+
+func (s Weighted) Sample(r *rand.Rand) float64 {
+	total := 0.0
+	for _, w := range s.Weights {
+		total += w
+	}
+	x := r.Float64() * total
+	for i, w := range s.Weights {
+		if x < w {
+			return s.Values[i]
+		}
+		x -= w
+	}
+	return s.Values[len(s.Values)-1]
+}
+
+// 帕累托分布的提现倍数: P(X > x) = (Min/x)^Alpha，Alpha越大越保守，结果保留两位小数并封顶Max
+type Pareto struct {
+	Min   float64
+	Alpha float64
+	Max   float64
+}
+
+func (s Pareto) Sample(r *rand.Rand) float64 {
+	v := s.Min / math.Pow(1-r.Float64(), 1/s.Alpha)
+	if s.Max > 0 {
+		v = math.Min(v, s.Max)
+	}
+	return math.Floor(v*100) / 100
+}
@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type moderateRequest struct {
+	PlayerIdent string `json:"playerIdent"` // appId-playerId
+	Seconds     int64  `json:"seconds"`     // 禁言时长
+	MessageId   string `json:"messageId"`
+	ChannelId   string `json:"channelId"` // 公告的频道，为空表示所有频道
+	Text        string `json:"text"`
+}
+
+// 注册管理接口，鉴权由调用方在router上加中间件:
+//
+//	POST mute    {"playerIdent", "seconds"}
+//	POST unmute  {"playerIdent"}
+//	POST ban     {"playerIdent"}
+//	POST unban   {"playerIdent"}
+//	POST delete  {"messageId"}
+//	POST announce {"channelId", "text"}
+func (h *Hub) RegisterModeratorRoutes(router fiber.Router) {
+	router.Post("/mute", h.moderate(func(ctx context.Context, req *moderateRequest) (interface{}, error) {
+		if req.PlayerIdent == "" || req.Seconds <= 0 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "playerIdent and seconds are required")
+		}
+		return nil, h.Mute(ctx, req.PlayerIdent, time.Duration(req.Seconds)*time.Second)
+	}))
+	router.Post("/unmute", h.moderate(func(ctx context.Context, req *moderateRequest) (interface{}, error) {
+		return nil, h.Unmute(ctx, req.PlayerIdent)
+	}))
+	router.Post("/ban", h.moderate(func(ctx context.Context, req *moderateRequest) (interface{}, error) {
+		if req.PlayerIdent == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "playerIdent is required")
+		}
+		return nil, h.Ban(ctx, req.PlayerIdent)
+	}))
+	router.Post("/unban", h.moderate(func(ctx context.Context, req *moderateRequest) (interface{}, error) {
+		return nil, h.Unban(ctx, req.PlayerIdent)
+	}))
+	router.Post("/delete", h.moderate(func(ctx context.Context, req *moderateRequest) (interface{}, error) {
+		if !h.Delete(req.MessageId) {
+			return nil, fiber.NewError(fiber.StatusNotFound, "message not found")
+		}
+		return nil, nil
+	}))
+	router.Post("/announce", h.moderate(func(ctx context.Context, req *moderateRequest) (interface{}, error) {
+		if req.Text == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "text is required")
+		}
+		return h.Announce(req.ChannelId, req.Text), nil
+	}))
+}
+
+func (h *Hub) moderate(fn func(ctx context.Context, req *moderateRequest) (interface{}, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req moderateRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
+		defer cancel()
+		data, err := fn(ctx, &req)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			data = fiber.Map{"ok": true}
+		}
+		return c.JSON(data)
+	}
+}
@@ -0,0 +1,230 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/qd2ss/sfs"
+	"github.com/redis/go-redis/v9"
+)
+
+// 只实现聊天用到的方法
+type testPlayer struct {
+	types.PlayerImp
+	id       string
+	messages []string
+}
+
+func (p *testPlayer) GetPlayerIdent() string { return "app-" + p.id }
+func (p *testPlayer) GetPlayerId() string    { return p.id }
+func (p *testPlayer) GetLang() string        { return "en" }
+func (p *testPlayer) SendString(msg string) error {
+	p.messages = append(p.messages, msg)
+	return nil
+}
+func (p *testPlayer) SendBinary(data []byte) error { return p.SendString(string(data)) }
+
+func (p *testPlayer) last() string {
+	if len(p.messages) == 0 {
+		return ""
+	}
+	return p.messages[len(p.messages)-1]
+}
+
+func TestFilter(t *testing.T) {
+	f := NewFilter(DefaultBadWords)
+	if got, err := f.Apply("  what the Fuck\nman ", 160); err != nil || got != "what the **** man" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	for _, text := range []string{"join www.example.org", "https://x.y/z", "go to bestbet.com now", "t.me/promo", "dm @promo_bot"} {
+		if _, err := f.Apply(text, 160); !errors.Is(err, ErrLinkNotAllowed) {
+			t.Fatalf("%q should be rejected, got %v", text, err)
+		}
+	}
+	if _, err := f.Apply("   ", 160); !errors.Is(err, ErrEmptyMessage) {
+		t.Fatal("expected empty message")
+	}
+	if _, err := f.Apply(strings.Repeat("好", 11), 10); !errors.Is(err, ErrTooLong) {
+		t.Fatal("expected too long")
+	}
+	if got, _ := f.Apply("nice 2.5x cashout", 160); got != "nice 2.5x cashout" {
+		t.Fatalf("got %q", got)
+	}
+
+	// 只替换整词，包含敏感词的正常单词不受影响
+	for text, want := range map[string]string{
+		"classic assistant":    "classic assistant",
+		"reading Dickens":      "reading Dickens",
+		"no scam, no cheat!":   "no ****, no *****!",
+		"SHIT happens":         "**** happens",
+		"cheaters and scammer": "cheaters and scammer",
+	} {
+		if got, err := f.Apply(text, 160); err != nil || got != want {
+			t.Fatalf("%q: got %q, %v", text, got, err)
+		}
+	}
+	// 中文没有分词，按子串匹配
+	if got, _ := NewFilter([]string{"傻瓜", ""}).Apply("你是傻瓜吗", 160); got != "你是**吗" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestChannel(t *testing.T) {
+	hub := NewHub(Options{Serializer: InoutSerializer{}, RateLimit: 2, RateWindow: time.Second})
+	now := time.UnixMilli(1700000000000)
+	hub.now = func() time.Time { return now }
+	c := hub.Channel("room-1")
+
+	p1, p2 := &testPlayer{id: "p1"}, &testPlayer{id: "p2"}
+	c.Join(p1)
+	if p1.last() != `42["onChatHistory",[]]` {
+		t.Fatalf("unexpected history %s", p1.last())
+	}
+	c.Join(p2)
+
+	ok, _ := c.OnMessage(p1, &types.InoutMsgData{MsgId: "431", Action: "chat-send-message", Payload: `{"text":"hello"}`})
+	if !ok || !strings.HasPrefix(p1.last(), `431[{"id":`) || !strings.HasPrefix(p2.last(), `42["onChatMessage",{"id":`) {
+		t.Fatalf("unexpected messages %v %v", p1.messages, p2.messages)
+	}
+	if ok, _ := c.OnMessage(p1, &types.InoutMsgData{MsgId: "432", Action: "bet", Payload: `{}`}); ok {
+		t.Fatal("non chat message should not be handled")
+	}
+
+	// 限流
+	c.Send(p1, "two")
+	if _, err := c.Send(p1, "three"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limit, got %v", err)
+	}
+	now = now.Add(time.Second)
+	msg, err := c.Send(p1, "four")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 离开频道后限流记录删除
+	c.Send(p2, "bye")
+	c.Leave(p2)
+	if _, ok := c.sent["app-p2"]; ok {
+		t.Fatal("rate limit records should be removed on leave")
+	}
+	c.Join(p2)
+
+	// 新进来的玩家可以看到历史消息
+	p3 := &testPlayer{id: "p3"}
+	c.Join(p3)
+	if !strings.Contains(p3.last(), `"text":"hello"`) || !strings.Contains(p3.last(), `"text":"four"`) {
+		t.Fatalf("unexpected history %s", p3.last())
+	}
+
+	if !hub.Delete(msg.Id) || p2.last() != `42["onChatMessageDeleted",{"id":"`+msg.Id+`"}]` {
+		t.Fatalf("unexpected delete %s", p2.last())
+	}
+
+	ctx := context.Background()
+	hub.Mute(ctx, "app-p2", time.Minute)
+	if _, err := c.Send(p2, "hi"); !errors.Is(err, ErrMuted) {
+		t.Fatalf("expected muted, got %v", err)
+	}
+	hub.Unmute(ctx, "app-p2")
+	if _, err := c.Send(p2, "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 封禁后历史消息也删除
+	hub.Ban(ctx, "app-p1")
+	if _, err := c.Send(p1, "hi"); !errors.Is(err, ErrBanned) {
+		t.Fatalf("expected banned, got %v", err)
+	}
+	for _, m := range c.history {
+		if m.PlayerIdent == "app-p1" {
+			t.Fatal("banned player's messages should be removed")
+		}
+	}
+}
+
+func TestSpribeSerializer(t *testing.T) {
+	hub := NewHub(Options{Serializer: SpribeSerializer{}})
+	c := hub.Channel("room-1")
+	p := &testPlayer{id: "p1"}
+	c.Join(p)
+
+	c.OnMessage(p, sfs.SFSObject{"c": "sendChatMessage", "p": sfs.SFSObject{"message": "visit www.x.com"}})
+	_, _, data, err := utils.Unpack([]byte(p.last()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["c"] != "sendChatMessageResponse" {
+		t.Fatalf("unexpected response %v", data)
+	}
+	if params, _ := data["p"].(sfs.SFSObject); params["reason"] != ErrLinkNotAllowed.Error() {
+		t.Fatalf("unexpected params %v", data["p"])
+	}
+}
+
+func TestModeratorRoutes(t *testing.T) {
+	hub := NewHub(Options{Serializer: InoutSerializer{}})
+	c := hub.Channel("room-1")
+	p := &testPlayer{id: "p1"}
+	c.Join(p)
+
+	app := fiber.New()
+	hub.RegisterModeratorRoutes(app.Group("/chat"))
+
+	post := func(path, body string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if code := post("/chat/mute", `{"playerIdent":"app-p1","seconds":60}`); code != 200 {
+		t.Fatalf("mute returned %d", code)
+	}
+	if _, err := c.Send(p, "hi"); !errors.Is(err, ErrMuted) {
+		t.Fatalf("expected muted, got %v", err)
+	}
+	if code := post("/chat/mute", `{"playerIdent":"app-p1"}`); code != 400 {
+		t.Fatalf("mute without seconds returned %d", code)
+	}
+	if code := post("/chat/announce", `{"text":"maintenance at 12:00"}`); code != 200 || !strings.Contains(p.last(), `"system":true`) {
+		t.Fatalf("announce returned %d, %s", code, p.last())
+	}
+	if code := post("/chat/delete", `{"messageId":"missing"}`); code != 404 {
+		t.Fatalf("delete returned %d", code)
+	}
+}
+
+// 禁言时长必须大于0，没有过期时间的禁言查询不到，会被当成没有禁言
+func TestModerationMute(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	ctx := context.Background()
+	for name, m := range map[string]Moderation{"memory": NewMemoryModeration(), "redis": NewRedisModeration(rdb)} {
+		for _, d := range []time.Duration{0, -time.Minute} {
+			if err := m.Mute(ctx, "app-p1", d); !errors.Is(err, ErrInvalidMute) {
+				t.Fatalf("%s: mute %v expected ErrInvalidMute, got %v", name, d, err)
+			}
+		}
+		if until, _, _ := m.Status(ctx, "app-p1"); !until.IsZero() {
+			t.Fatalf("%s: invalid mute should not be stored", name)
+		}
+		if err := m.Mute(ctx, "app-p1", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if until, _, _ := m.Status(ctx, "app-p1"); !until.After(time.Now()) {
+			t.Fatalf("%s: expected muted, until %v", name, until)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 默认的敏感词，实际使用时应该从配置加载
+var DefaultBadWords = []string{
+	"fuck", "shit", "bitch", "asshole", "bastard", "cunt", "dick", "pussy", "nigger", "faggot",
+	"scam", "cheat",
+}
+
+// 网址、域名和@用户名都算作链接
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|xyz|ru|cn|top|me|link|bet|vip|cc|co|app)\b|t\.me/\S+|@[a-z0-9_]{4,}`)
+
+// 敏感词替换成*，包含链接的消息直接拒绝
+type Filter struct {
+	words *regexp.Regexp
+}
+
+func NewFilter(badWords []string) *Filter {
+	f := &Filter{}
+	quoted := make([]string, 0, len(badWords))
+	for _, w := range badWords {
+		if w == "" {
+			continue
+		}
+		// 字母数字开头结尾的词按整词匹配，避免误伤包含它的正常单词(比如class、Dickens)；
+		// 中文等没有空格分词的语言仍然按子串匹配
+		q := regexp.QuoteMeta(w)
+		if isWordByte(w[0]) {
+			q = `\b` + q
+		}
+		if isWordByte(w[len(w)-1]) {
+			q += `\b`
+		}
+		quoted = append(quoted, q)
+	}
+	if len(quoted) > 0 {
+		f.words = regexp.MustCompile(`(?i)(` + strings.Join(quoted, "|") + `)`)
+	}
+	return f
+}
+
+// 与regexp的\b一致，只有ASCII字母数字和下划线算单词字符
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+// 返回过滤后的消息
+func (f *Filter) Apply(text string, maxLength int) (string, error) {
+	text = strings.TrimSpace(strings.Map(func(r rune) rune {
+		// 去掉控制字符，换行也换成空格
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text))
+	if text == "" {
+		return "", ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(text) > maxLength {
+		return "", ErrTooLong
+	}
+	if linkPattern.MatchString(text) {
+		return "", ErrLinkNotAllowed
+	}
+	if f.words != nil {
+		text = f.words.ReplaceAllStringFunc(text, func(w string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(w))
+		})
+	}
+	return text, nil
+}
@@ -0,0 +1,302 @@
+package chat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+// 一个品牌的所有聊天频道，并提供管理操作
+type Hub struct {
+	opts Options
+	log  *log.Helper
+	now  func() time.Time
+
+	mu       sync.Mutex
+	channels map[string]*Channel
+}
+
+func NewHub(opts Options) *Hub {
+	opts.normalize()
+	return &Hub{
+		opts:     opts,
+		log:      log.NewHelper(log.With(opts.Logger, "module", "chat")),
+		now:      time.Now,
+		channels: make(map[string]*Channel),
+	}
+}
+
+// 获取或者创建一个频道，通常每个房间一个
+func (h *Hub) Channel(id string) *Channel {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.channels[id]
+	if !ok {
+		c = &Channel{
+			id:      id,
+			hub:     h,
+			members: make(map[string]types.PlayerImp),
+			sent:    make(map[string][]time.Time),
+		}
+		h.channels[id] = c
+	}
+	return c
+}
+
+// 房间销毁时关闭频道
+func (h *Hub) CloseChannel(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.channels, id)
+}
+
+func (h *Hub) allChannels() []*Channel {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	channels := make([]*Channel, 0, len(h.channels))
+	for _, c := range h.channels {
+		channels = append(channels, c)
+	}
+	return channels
+}
+
+func (h *Hub) Mute(ctx context.Context, playerIdent string, d time.Duration) error {
+	return h.opts.Moderation.Mute(ctx, playerIdent, d)
+}
+
+func (h *Hub) Unmute(ctx context.Context, playerIdent string) error {
+	return h.opts.Moderation.Unmute(ctx, playerIdent)
+}
+
+// 封禁并删除该玩家所有频道中的历史消息
+func (h *Hub) Ban(ctx context.Context, playerIdent string) error {
+	if err := h.opts.Moderation.Ban(ctx, playerIdent); err != nil {
+		return err
+	}
+	for _, c := range h.allChannels() {
+		c.deleteWhere(func(m *Message) bool { return m.PlayerIdent == playerIdent })
+	}
+	return nil
+}
+
+func (h *Hub) Unban(ctx context.Context, playerIdent string) error {
+	return h.opts.Moderation.Unban(ctx, playerIdent)
+}
+
+// 删除一条消息，返回是否找到
+func (h *Hub) Delete(messageId string) bool {
+	found := false
+	for _, c := range h.allChannels() {
+		if c.deleteWhere(func(m *Message) bool { return m.Id == messageId }) > 0 {
+			found = true
+		}
+	}
+	return found
+}
+
+// 向所有频道(channelId为空)或者某个频道发送公告
+func (h *Hub) Announce(channelId, text string) *Message {
+	msg := &Message{Id: uuid.NewString(), Text: text, System: true, Time: h.now().UnixMilli()}
+	for _, c := range h.allChannels() {
+		if channelId == "" || c.id == channelId {
+			c.publish(msg)
+		}
+	}
+	return msg
+}
+
+func (h *Hub) send(player types.PlayerImp, ev *Event) {
+	frame, err := h.opts.Serializer.Encode(player, ev)
+	if err != nil {
+		h.log.Errorf("encode chat event %d failed: %v", ev.Type, err)
+		return
+	}
+	if frame == nil {
+		return
+	}
+	if frame.Binary {
+		err = player.SendBinary(frame.Data)
+	} else {
+		err = player.SendString(string(frame.Data))
+	}
+	if err != nil {
+		h.log.Warnf("send chat to %s failed: %v", player.GetPlayerIdent(), err)
+	}
+}
+
+// 一个房间的聊天
+type Channel struct {
+	id  string
+	hub *Hub
+
+	mu      sync.Mutex
+	members map[string]types.PlayerImp
+	history []*Message
+	sent    map[string][]time.Time // 玩家最近发送消息的时间，用于限流
+}
+
+func (c *Channel) Id() string {
+	return c.id
+}
+
+// 玩家进入频道，回放最近的消息
+func (c *Channel) Join(player types.PlayerImp) {
+	if types.IsBot(player) {
+		return
+	}
+	c.mu.Lock()
+	c.members[player.GetPlayerIdent()] = player
+	history := append([]*Message(nil), c.history...)
+	c.mu.Unlock()
+	c.hub.send(player, &Event{Type: EventHistory, Messages: history})
+}
+
+// 玩家离开频道，限流记录一起删除，避免频道里的玩家来来去去时一直增长
+func (c *Channel) Leave(player types.PlayerImp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ident := player.GetPlayerIdent()
+	if c.members[ident] == player {
+		delete(c.members, ident)
+		delete(c.sent, ident)
+	}
+}
+
+// 房间收到消息时先交给聊天处理，返回是否是聊天消息
+func (c *Channel) OnMessage(player types.PlayerImp, data interface{}) (bool, error) {
+	cmd, err := c.hub.opts.Serializer.Decode(data)
+	if err != nil || cmd == nil {
+		return false, nil
+	}
+	switch cmd.Type {
+	case CmdHistory:
+		c.mu.Lock()
+		history := append([]*Message(nil), c.history...)
+		c.mu.Unlock()
+		c.hub.send(player, &Event{Type: EventHistory, Messages: history, Reply: cmd})
+	case CmdSend:
+		msg, err := c.Send(player, cmd.Text)
+		if err != nil {
+			c.hub.send(player, &Event{Type: EventError, Reply: cmd, Err: err})
+		} else {
+			c.hub.send(player, &Event{Type: EventSent, Message: msg, Reply: cmd})
+		}
+	}
+	return true, nil
+}
+
+// 发送消息: 检查封禁/禁言、限流和过滤之后广播
+func (c *Channel) Send(player types.PlayerImp, text string) (*Message, error) {
+	if types.IsSpectator(player) {
+		return nil, ErrSpectator
+	}
+	ident := player.GetPlayerIdent()
+	opts := &c.hub.opts
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	mutedUntil, banned, err := opts.Moderation.Status(ctx, ident)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, ErrBanned
+	}
+	if !mutedUntil.IsZero() {
+		return nil, ErrMuted
+	}
+
+	text, err = opts.Filter.Apply(text, opts.MaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.hub.now()
+	c.mu.Lock()
+	// 滑动窗口限流
+	sent := c.sent[ident]
+	for len(sent) > 0 && now.Sub(sent[0]) >= opts.RateWindow {
+		sent = sent[1:]
+	}
+	if len(sent) >= opts.RateLimit {
+		c.sent[ident] = sent
+		c.mu.Unlock()
+		return nil, ErrRateLimited
+	}
+	c.sent[ident] = append(sent, now)
+	c.mu.Unlock()
+
+	msg := &Message{
+		Id:          uuid.NewString(),
+		PlayerIdent: ident,
+		PlayerId:    player.GetPlayerId(),
+		Text:        text,
+		Time:        now.UnixMilli(),
+	}
+	if profile, ok := player.(types.ProfileImp); ok {
+		msg.Nickname = profile.GetNickname()
+		msg.Avatar = profile.GetAvatar()
+	}
+	c.publish(msg, player)
+	return msg, nil
+}
+
+// 保存并广播，except不会收到广播(已经单独回复)
+func (c *Channel) publish(msg *Message, except ...types.PlayerImp) {
+	c.mu.Lock()
+	c.history = append(c.history, msg)
+	if len(c.history) > c.hub.opts.HistorySize {
+		c.history = c.history[len(c.history)-c.hub.opts.HistorySize:]
+	}
+	members := c.snapshotLocked(except...)
+	c.mu.Unlock()
+
+	ev := &Event{Type: EventMessage, Message: msg}
+	for _, p := range members {
+		c.hub.send(p, ev)
+	}
+}
+
+func (c *Channel) deleteWhere(match func(m *Message) bool) int {
+	c.mu.Lock()
+	var deleted []*Message
+	kept := c.history[:0]
+	for _, m := range c.history {
+		if match(m) {
+			deleted = append(deleted, m)
+		} else {
+			kept = append(kept, m)
+		}
+	}
+	c.history = kept
+	members := c.snapshotLocked()
+	c.mu.Unlock()
+
+	for _, m := range deleted {
+		ev := &Event{Type: EventDeleted, Message: m}
+		for _, p := range members {
+			c.hub.send(p, ev)
+		}
+	}
+	return len(deleted)
+}
+
+func (c *Channel) snapshotLocked(except ...types.PlayerImp) []types.PlayerImp {
+	members := make([]types.PlayerImp, 0, len(c.members))
+	for _, p := range c.members {
+		skip := false
+		for _, e := range except {
+			if p == e {
+				skip = true
+			}
+		}
+		if !skip {
+			members = append(members, p)
+		}
+	}
+	return members
+}
@@ -0,0 +1,123 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisMuteKeyPrefix = "chat:mute:"
+	redisBanKeyPrefix  = "chat:ban:"
+)
+
+// 禁言和封禁，key为玩家唯一标识(PlayerImp.GetPlayerIdent)
+type Moderation interface {
+	// 返回禁言到什么时候(零值表示没有禁言)和是否被封禁
+	Status(ctx context.Context, playerIdent string) (mutedUntil time.Time, banned bool, err error)
+	// d必须大于0，否则返回ErrInvalidMute，永久禁止发言使用Ban
+	Mute(ctx context.Context, playerIdent string, d time.Duration) error
+	Unmute(ctx context.Context, playerIdent string) error
+	Ban(ctx context.Context, playerIdent string) error
+	Unban(ctx context.Context, playerIdent string) error
+}
+
+// 多个服务之间共享，禁言使用带过期时间的key
+type RedisModeration struct {
+	rdb *redis.Client
+}
+
+func NewRedisModeration(rdb *redis.Client) *RedisModeration {
+	return &RedisModeration{rdb: rdb}
+}
+
+func (m *RedisModeration) Status(ctx context.Context, playerIdent string) (time.Time, bool, error) {
+	pipe := m.rdb.Pipeline()
+	ttl := pipe.PTTL(ctx, redisMuteKeyPrefix+playerIdent)
+	banned := pipe.Exists(ctx, redisBanKeyPrefix+playerIdent)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return time.Time{}, false, err
+	}
+	var until time.Time
+	if d := ttl.Val(); d > 0 {
+		until = time.Now().Add(d)
+	}
+	return until, banned.Val() > 0, nil
+}
+
+func (m *RedisModeration) Mute(ctx context.Context, playerIdent string, d time.Duration) error {
+	// 没有过期时间的key查不到剩余时间，会被当成没有禁言
+	if d <= 0 {
+		return ErrInvalidMute
+	}
+	return m.rdb.Set(ctx, redisMuteKeyPrefix+playerIdent, 1, d).Err()
+}
+
+func (m *RedisModeration) Unmute(ctx context.Context, playerIdent string) error {
+	return m.rdb.Del(ctx, redisMuteKeyPrefix+playerIdent).Err()
+}
+
+func (m *RedisModeration) Ban(ctx context.Context, playerIdent string) error {
+	return m.rdb.Set(ctx, redisBanKeyPrefix+playerIdent, 1, 0).Err()
+}
+
+func (m *RedisModeration) Unban(ctx context.Context, playerIdent string) error {
+	return m.rdb.Del(ctx, redisBanKeyPrefix+playerIdent).Err()
+}
+
+// 内存实现，用于测试和单机
+type MemoryModeration struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	muted  map[string]time.Time
+	banned map[string]bool
+}
+
+func NewMemoryModeration() *MemoryModeration {
+	return &MemoryModeration{now: time.Now, muted: make(map[string]time.Time), banned: make(map[string]bool)}
+}
+
+func (m *MemoryModeration) Status(ctx context.Context, playerIdent string) (time.Time, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until := m.muted[playerIdent]
+	if !until.IsZero() && !until.After(m.now()) {
+		delete(m.muted, playerIdent)
+		until = time.Time{}
+	}
+	return until, m.banned[playerIdent], nil
+}
+
+func (m *MemoryModeration) Mute(ctx context.Context, playerIdent string, d time.Duration) error {
+	if d <= 0 {
+		return ErrInvalidMute
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.muted[playerIdent] = m.now().Add(d)
+	return nil
+}
+
+func (m *MemoryModeration) Unmute(ctx context.Context, playerIdent string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.muted, playerIdent)
+	return nil
+}
+
+func (m *MemoryModeration) Ban(ctx context.Context, playerIdent string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.banned[playerIdent] = true
+	return nil
+}
+
+func (m *MemoryModeration) Unban(ctx context.Context, playerIdent string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.banned, playerIdent)
+	return nil
+}
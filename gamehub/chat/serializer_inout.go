@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// inout 的socket.io事件: 发送 gameService action=chat-send-message {"text":...}，
+// 广播 42["onChatMessage",{...}]，删除 42["onChatMessageDeleted",{"id":...}]
+type InoutSerializer struct{}
+
+func (InoutSerializer) Decode(data interface{}) (*Command, error) {
+	msg, ok := data.(*types.InoutMsgData)
+	if !ok {
+		return nil, nil
+	}
+	switch msg.Action {
+	case "chat-send-message":
+		var p struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(msg.Payload), &p); err != nil {
+			return nil, err
+		}
+		return &Command{Type: CmdSend, ReplyId: msg.MsgId, Text: p.Text}, nil
+	case "chat-get-history":
+		return &Command{Type: CmdHistory, ReplyId: msg.MsgId}, nil
+	}
+	return nil, nil
+}
+
+func (InoutSerializer) Encode(player types.PlayerImp, ev *Event) (*Frame, error) {
+	msgId := types.DefaultMsgId
+	if ev.Reply != nil && ev.Reply.ReplyId != "" {
+		msgId = ev.Reply.ReplyId
+	}
+
+	var data []interface{}
+	switch ev.Type {
+	case EventError:
+		data = []interface{}{map[string]interface{}{"error": map[string]string{"message": ev.Err.Error()}}}
+	case EventSent:
+		data = []interface{}{ev.Message}
+	case EventHistory:
+		messages := ev.Messages
+		if messages == nil {
+			messages = []*Message{}
+		}
+		if ev.Reply != nil {
+			data = []interface{}{messages}
+		} else {
+			data = []interface{}{"onChatHistory", messages}
+		}
+	case EventMessage:
+		data = []interface{}{"onChatMessage", ev.Message}
+	case EventDeleted:
+		data = []interface{}{"onChatMessageDeleted", map[string]string{"id": ev.Message.Id}}
+	default:
+		return nil, nil
+	}
+
+	buff, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Data: []byte(fmt.Sprintf("%s%s", msgId, buff))}, nil
+}
@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"errors"
+
+	"github.com/card-engine/game_common/gamehub/spribe"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/qd2ss/sfs"
+)
+
+// spribe 的sfs扩展消息: 发送 c=sendChatMessage p={message}，
+// 广播 newChatMessage/chatMessageDeleted，进房时 chatHistory
+type SpribeSerializer struct{}
+
+func (SpribeSerializer) Decode(data interface{}) (*Command, error) {
+	obj, ok := data.(sfs.SFSObject)
+	if !ok {
+		return nil, nil
+	}
+	c, _ := obj["c"].(string)
+	switch c {
+	case "sendChatMessage":
+		cmd := &Command{Type: CmdSend, ReplyId: c}
+		if p, ok := obj["p"].(sfs.SFSObject); ok {
+			cmd.Text, _ = p["message"].(string)
+		}
+		return cmd, nil
+	case "getChatHistory":
+		return &Command{Type: CmdHistory, ReplyId: c}, nil
+	}
+	return nil, nil
+}
+
+func spribeMessage(m *Message) sfs.SFSObject {
+	obj := sfs.SFSObject{
+		"id":           m.Id,
+		"player_id":    m.PlayerId,
+		"username":     m.Nickname,
+		"profileImage": m.Avatar,
+		"message":      m.Text,
+		"time":         m.Time,
+	}
+	if m.System {
+		obj["system"] = true
+	}
+	return obj
+}
+
+func spribeErrorCode(err error) int {
+	if errors.Is(err, ErrEmptyMessage) || errors.Is(err, ErrTooLong) || errors.Is(err, ErrLinkNotAllowed) ||
+		errors.Is(err, ErrRateLimited) || errors.Is(err, ErrMuted) || errors.Is(err, ErrBanned) {
+		return spribe.CodeInvalidParameter
+	}
+	return spribe.CodeSystemError
+}
+
+func (SpribeSerializer) Encode(player types.PlayerImp, ev *Event) (*Frame, error) {
+	var cmd string
+	var p sfs.SFSObject
+	switch ev.Type {
+	case EventError:
+		cmd = ev.Reply.ReplyId + "Response"
+		code := spribeErrorCode(ev.Err)
+		p = sfs.SFSObject{
+			"code":    int32(code),
+			"message": spribe.GetErrorMessage(code, spribe.Language(player.GetLang())),
+			"reason":  ev.Err.Error(),
+		}
+	case EventSent:
+		cmd, p = ev.Reply.ReplyId+"Response", spribeMessage(ev.Message)
+	case EventHistory:
+		messages := make(sfs.SFSArray, 0, len(ev.Messages))
+		for _, m := range ev.Messages {
+			messages = append(messages, spribeMessage(m))
+		}
+		cmd = "chatHistory"
+		if ev.Reply != nil {
+			cmd = ev.Reply.ReplyId + "Response"
+		}
+		p = sfs.SFSObject{"messages": messages}
+	case EventMessage:
+		cmd, p = "newChatMessage", spribeMessage(ev.Message)
+	case EventDeleted:
+		cmd, p = "chatMessageDeleted", sfs.SFSObject{"id": ev.Message.Id}
+	default:
+		return nil, nil
+	}
+
+	buff, err := utils.PackCustomData(cmd, p)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Binary: true, Data: buff}, nil
+}
@@ -0,0 +1,120 @@
+// 房间内聊天: 任何房间都可以挂一个Channel，同一个品牌的所有Channel共用一个Hub，
+// Hub负责过滤、禁言/封禁和管理接口，品牌的消息格式由Serializer决定。
+package chat
+
+import (
+	"errors"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+var (
+	ErrEmptyMessage   = errors.New("chat: empty message")
+	ErrTooLong        = errors.New("chat: message too long")
+	ErrLinkNotAllowed = errors.New("chat: links are not allowed")
+	ErrRateLimited    = errors.New("chat: too many messages")
+	ErrMuted          = errors.New("chat: you are muted")
+	ErrBanned         = errors.New("chat: you are banned")
+	ErrSpectator      = errors.New("chat: spectators can not send messages")
+	ErrInvalidMute    = errors.New("chat: mute duration must be positive, use ban for permanent")
+)
+
+type Message struct {
+	Id          string `json:"id"`
+	PlayerIdent string `json:"-"`
+	PlayerId    string `json:"playerId"`
+	Nickname    string `json:"nickname,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
+	Text        string `json:"text"`
+	System      bool   `json:"system,omitempty"` // 管理员公告
+	Time        int64  `json:"time"`             // 毫秒
+}
+
+type CommandType int
+
+const (
+	CmdSend    CommandType = iota + 1 // 发送消息
+	CmdHistory                        // 获取最近的消息
+)
+
+// 玩家发来的聊天指令
+type Command struct {
+	Type    CommandType
+	ReplyId string
+	Text    string
+}
+
+type EventType int
+
+const (
+	EventMessage EventType = iota + 1 // 新消息，广播
+	EventHistory                      // 最近的消息，进房时或请求时发给玩家
+	EventDeleted                      // 消息被管理员删除，广播
+	EventSent                         // 发送成功，回复发送者
+	EventError                        // 发送失败，回复发送者
+)
+
+type Event struct {
+	Type     EventType
+	Message  *Message
+	Messages []*Message
+	Reply    *Command
+	Err      error
+}
+
+type Frame struct {
+	Binary bool
+	Data   []byte
+}
+
+// 品牌消息格式
+type Serializer interface {
+	// 不是聊天消息时返回nil, nil
+	Decode(data interface{}) (*Command, error)
+	Encode(player types.PlayerImp, ev *Event) (*Frame, error)
+}
+
+type Options struct {
+	Serializer Serializer
+	Moderation Moderation
+	Filter     *Filter
+
+	HistorySize int // 进房时回放的消息条数
+	MaxLength   int // 单条消息最大字符数
+	RateLimit   int // RateWindow内最多发送几条
+	RateWindow  time.Duration
+	Logger      log.Logger
+}
+
+const (
+	DefaultHistorySize = 30
+	DefaultMaxLength   = 160
+	DefaultRateLimit   = 5
+	DefaultRateWindow  = 10 * time.Second
+)
+
+func (o *Options) normalize() {
+	if o.HistorySize <= 0 {
+		o.HistorySize = DefaultHistorySize
+	}
+	if o.MaxLength <= 0 {
+		o.MaxLength = DefaultMaxLength
+	}
+	if o.RateLimit <= 0 {
+		o.RateLimit = DefaultRateLimit
+	}
+	if o.RateWindow <= 0 {
+		o.RateWindow = DefaultRateWindow
+	}
+	if o.Moderation == nil {
+		o.Moderation = NewMemoryModeration()
+	}
+	if o.Filter == nil {
+		o.Filter = NewFilter(DefaultBadWords)
+	}
+	if o.Logger == nil {
+		o.Logger = log.GetLogger()
+	}
+}
@@ -0,0 +1,364 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const (
+	DefaultActorQueueSize           = 1024
+	DefaultActorMaxPendingPerPlayer = 64
+	actorControlQueueSize           = 256 // 给不能丢弃的事件预留的队列长度
+)
+
+var (
+	ErrRoomOverloaded = errors.New("room overloaded")
+	ErrRoomStopped    = errors.New("room stopped")
+)
+
+type ActorOptions struct {
+	QueueSize           int // 玩家消息排队的上限，超过之后新消息被丢弃
+	MaxPendingPerPlayer int // 每个玩家排队中的消息上限，超过时返回ErrRoomOverloaded，连接会被断开
+	Logger              log.Logger
+}
+
+func (o *ActorOptions) normalize() {
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultActorQueueSize
+	}
+	if o.MaxPendingPerPlayer <= 0 {
+		o.MaxPendingPerPlayer = DefaultActorMaxPendingPerPlayer
+	}
+	if o.Logger == nil {
+		o.Logger = log.GetLogger()
+	}
+}
+
+type actorEvent struct {
+	playerIdent string // 玩家消息时不为空，计入玩家的排队数
+	fn          func()
+}
+
+// 房间执行器: 每个房间一个协程，进房、消息、断线、定时器和销毁按顺序在这个协程里执行，
+// 被包装的房间不需要自己加锁。
+//
+// 进房、重连这类需要返回结果的调用会等待执行完成，RoomManager调用时持有自己的锁，
+// 所以房间逻辑里不要直接调用player.ExitRoom，使用ExitPlayer。
+type ActorRoom struct {
+	room types.RoomImp
+	opts ActorOptions
+	log  *log.Helper
+
+	// 所有事件按顺序排队，玩家消息超过QueueSize时丢弃，进房、断线、定时器、销毁不会被丢弃
+	events chan actorEvent
+	// 队列满时Post的事件按顺序放在这里，由房间协程取出执行，wake通知有新的事件
+	overflowMu sync.Mutex
+	overflow   []func()
+	wake       chan struct{}
+
+	stopped  chan struct{}
+	disposed atomic.Bool
+	finished bool // 只在房间协程中读写
+
+	playerNum atomic.Int32 // 每个事件执行后更新，GetPlayerNum不需要经过队列
+
+	pendingMu sync.Mutex
+	pending   map[string]int
+}
+
+func NewActorRoom(room types.RoomImp, opts ActorOptions) *ActorRoom {
+	opts.normalize()
+	a := &ActorRoom{
+		room:    room,
+		opts:    opts,
+		log:     log.NewHelper(log.With(opts.Logger, "module", "actor_room")),
+		events:  make(chan actorEvent, opts.QueueSize+actorControlQueueSize),
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+		pending: make(map[string]int),
+	}
+	go a.loop()
+	return a
+}
+
+// 包装创建器，创建的房间都使用执行器
+func ActorRoomCreator(creator types.RoomCreator, opts ActorOptions) types.RoomCreator {
+	return &actorRoomCreator{creator: creator, opts: opts}
+}
+
+type actorRoomCreator struct {
+	creator types.RoomCreator
+	opts    ActorOptions
+}
+
+func (c *actorRoomCreator) CreateRoom(args interface{}) types.RoomImp {
+	return NewActorRoom(c.creator.CreateRoom(args), c.opts)
+}
+
+// 被包装的房间
+func (a *ActorRoom) Room() types.RoomImp {
+	return a.room
+}
+
+// 在房间协程中执行，用于定时器或者异步回调的结果。
+// 可能是在房间协程中调用的，不能阻塞，队列满时放入不限长度的溢出队列
+func (a *ActorRoom) Post(fn func()) error {
+	select {
+	case <-a.stopped:
+		return ErrRoomStopped
+	default:
+	}
+
+	a.overflowMu.Lock()
+	// 溢出队列里还有事件时继续往后排，保证Post的顺序
+	if len(a.overflow) == 0 {
+		select {
+		case a.events <- actorEvent{fn: fn}:
+			a.overflowMu.Unlock()
+			return nil
+		default:
+		}
+	}
+	a.overflow = append(a.overflow, fn)
+	a.overflowMu.Unlock()
+	a.wakeUp()
+	return nil
+}
+
+// 延迟d之后在房间协程中执行
+func (a *ActorRoom) AfterFunc(d time.Duration, fn func()) *time.Timer {
+	return time.AfterFunc(d, func() { a.Post(fn) })
+}
+
+// 在房间逻辑中让玩家退出，房间需要先把玩家从自己的状态中移除。
+// RoomManager.ExitRoom会回调GetPlayerNum和OnDispose，需要在房间协程外执行，
+// 交出去之前先更新人数，否则ExitRoom读到旧的人数，最后一个玩家退出后房间不会销毁
+func (a *ActorRoom) ExitPlayer(player types.PlayerImp, isDisconnect bool) {
+	a.playerNum.Store(a.room.GetPlayerNum())
+	go player.ExitRoom(isDisconnect)
+}
+
+// 等待房间销毁完成
+func (a *ActorRoom) Wait() {
+	<-a.stopped
+}
+
+// ========================================================================================
+// types.RoomImp
+
+func (a *ActorRoom) GetPlayerNum() int32 {
+	return a.playerNum.Load()
+}
+
+func (a *ActorRoom) OnJoin(player types.PlayerImp) error {
+	return a.call(func() error { return a.room.OnJoin(player) })
+}
+
+func (a *ActorRoom) OnSwitch(player types.PlayerImp, args interface{}) error {
+	return a.call(func() error { return a.room.OnSwitch(player, args) })
+}
+
+func (a *ActorRoom) OnReConnect(player types.PlayerImp) error {
+	return a.call(func() error { return a.room.OnReConnect(player) })
+}
+
+// 等待执行完成，RoomManager在OnDisConnect之后马上会用GetPlayerNum判断是否销毁房间(SwitchRoom)
+func (a *ActorRoom) OnDisConnect(player types.PlayerImp) error {
+	return a.call(func() error { return a.room.OnDisConnect(player) })
+}
+
+// 消息排队后立即返回，房间处理消息的错误只记录日志
+func (a *ActorRoom) OnMessage(player types.PlayerImp, data interface{}) error {
+	select {
+	case <-a.stopped:
+		return ErrRoomStopped
+	default:
+	}
+
+	ident := player.GetPlayerIdent()
+	if !a.acquire(ident) {
+		a.log.Warnf("too many pending messages from %s", ident)
+		return ErrRoomOverloaded
+	}
+
+	// 整个房间过载，丢弃这条消息，连接保持
+	if len(a.events) >= a.opts.QueueSize {
+		a.release(ident)
+		a.log.Warnf("queue full, drop message from %s", ident)
+		return nil
+	}
+
+	ev := actorEvent{playerIdent: ident, fn: func() {
+		if err := a.room.OnMessage(player, data); err != nil {
+			a.log.Errorf("OnMessage %s failed: %v", ident, err)
+		}
+	}}
+	select {
+	case a.events <- ev:
+		return nil
+	case <-a.stopped:
+		a.release(ident)
+		return ErrRoomStopped
+	}
+}
+
+// 排队销毁后立即返回，之后的事件都会被丢弃
+func (a *ActorRoom) OnDispose() {
+	if !a.disposed.CompareAndSwap(false, true) {
+		return
+	}
+	a.send(func() {
+		a.finished = true
+		a.room.OnDispose()
+	})
+}
+
+// ========================================================================================
+// 可选的房间能力，被包装的房间没有实现时按不支持处理
+
+func (a *ActorRoom) OnSpectate(player types.PlayerImp) error {
+	spectatable, ok := a.room.(types.SpectatableRoomImp)
+	if !ok {
+		return ErrSpectateNotSupported
+	}
+	return a.call(func() error { return spectatable.OnSpectate(player) })
+}
+
+func (a *ActorRoom) OnSpectatorLeave(player types.PlayerImp) {
+	if spectatable, ok := a.room.(types.SpectatableRoomImp); ok {
+		a.send(func() { spectatable.OnSpectatorLeave(player) })
+	}
+}
+
+func (a *ActorRoom) OnSeatExpired(player types.PlayerImp) {
+	if expirable, ok := a.room.(types.SeatExpirableRoomImp); ok {
+		a.call(func() error {
+			expirable.OnSeatExpired(player)
+			return nil
+		})
+	}
+}
+
+// 等待在房间协程外进行，被包装的房间需要自己保证Drain的并发安全
+func (a *ActorRoom) Drain(ctx context.Context) error {
+	if drainable, ok := a.room.(types.DrainableRoomImp); ok {
+		return drainable.Drain(ctx)
+	}
+	return nil
+}
+
+// ========================================================================================
+
+func (a *ActorRoom) loop() {
+	defer close(a.stopped)
+	for {
+		select {
+		case ev := <-a.events:
+			if ev.playerIdent != "" {
+				a.release(ev.playerIdent)
+			}
+			a.run(ev.fn)
+		case <-a.wake:
+			// 溢出队列里的事件排在队列里已有的事件后面，先把队列执行完
+			if len(a.events) > 0 {
+				a.wakeUp()
+				continue
+			}
+			a.runOverflow()
+		}
+		if a.finished {
+			return
+		}
+	}
+}
+
+// 按顺序执行溢出队列里的事件
+func (a *ActorRoom) runOverflow() {
+	for !a.finished {
+		a.overflowMu.Lock()
+		if len(a.overflow) == 0 {
+			a.overflowMu.Unlock()
+			return
+		}
+		fn := a.overflow[0]
+		a.overflow[0] = nil
+		a.overflow = a.overflow[1:]
+		a.overflowMu.Unlock()
+		a.run(fn)
+	}
+}
+
+func (a *ActorRoom) wakeUp() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *ActorRoom) run(fn func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			a.log.Errorf("room panic: %v", err)
+		}
+		a.playerNum.Store(a.room.GetPlayerNum())
+	}()
+	fn()
+}
+
+func (a *ActorRoom) send(fn func()) error {
+	select {
+	case a.events <- actorEvent{fn: fn}:
+		return nil
+	case <-a.stopped:
+		return ErrRoomStopped
+	}
+}
+
+// 在房间协程中执行并等待结果
+func (a *ActorRoom) call(fn func() error) error {
+	result := make(chan error, 1)
+	if err := a.send(func() { result <- fn() }); err != nil {
+		return err
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-a.stopped:
+		select {
+		case err := <-result:
+			return err
+		default:
+			return ErrRoomStopped
+		}
+	}
+}
+
+func (a *ActorRoom) acquire(playerIdent string) bool {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+	if a.pending[playerIdent] >= a.opts.MaxPendingPerPlayer {
+		return false
+	}
+	a.pending[playerIdent]++
+	return true
+}
+
+func (a *ActorRoom) release(playerIdent string) {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+	if a.pending[playerIdent]--; a.pending[playerIdent] <= 0 {
+		delete(a.pending, playerIdent)
+	}
+}
+
+var (
+	_ types.SpectatableRoomImp   = (*ActorRoom)(nil)
+	_ types.SeatExpirableRoomImp = (*ActorRoom)(nil)
+	_ types.DrainableRoomImp     = (*ActorRoom)(nil)
+)
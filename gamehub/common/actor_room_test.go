@@ -0,0 +1,204 @@
+package common
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// 没有任何锁的房间，只能在执行器中使用
+type counterRoom struct {
+	players  map[string]bool
+	messages int
+	block    chan struct{}
+	disposed bool
+}
+
+func (r *counterRoom) GetPlayerNum() int32 { return int32(len(r.players)) }
+func (r *counterRoom) OnJoin(p types.PlayerImp) error {
+	if len(r.players) >= 2 {
+		return errors.New("full")
+	}
+	r.players[p.GetPlayerIdent()] = true
+	return nil
+}
+func (r *counterRoom) OnSwitch(p types.PlayerImp, args interface{}) error { return r.OnJoin(p) }
+func (r *counterRoom) OnReConnect(p types.PlayerImp) error                { return nil }
+func (r *counterRoom) OnDisConnect(p types.PlayerImp) error {
+	delete(r.players, p.GetPlayerIdent())
+	return nil
+}
+func (r *counterRoom) OnMessage(p types.PlayerImp, data interface{}) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.messages++
+	if data == "panic" {
+		panic("boom")
+	}
+	return nil
+}
+func (r *counterRoom) OnDispose() { r.disposed = true }
+
+func TestActorRoomSerializes(t *testing.T) {
+	inner := &counterRoom{players: map[string]bool{}}
+	a := NewActorRoom(inner, ActorOptions{Logger: log.DefaultLogger})
+
+	p1, p2, p3 := newTestPlayer("p1", "USD"), newTestPlayer("p2", "USD"), newTestPlayer("p3", "USD")
+	if err := a.OnJoin(p1); err != nil {
+		t.Fatal(err)
+	}
+	a.OnJoin(p2)
+	if err := a.OnJoin(p3); err == nil {
+		t.Fatal("room should be full")
+	}
+	if a.GetPlayerNum() != 2 {
+		t.Fatalf("player num %d", a.GetPlayerNum())
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range []types.PlayerImp{p1, p2} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				a.OnMessage(p, i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// panic不会让房间停止
+	a.OnMessage(p1, "panic")
+	timer := make(chan struct{})
+	a.AfterFunc(time.Millisecond, func() { close(timer) })
+	<-timer
+
+	a.OnDisConnect(p1)
+	a.OnDispose()
+	a.Wait()
+	if inner.messages != 101 || !inner.disposed || a.GetPlayerNum() != 1 {
+		t.Fatalf("messages %d, disposed %v, players %d", inner.messages, inner.disposed, a.GetPlayerNum())
+	}
+	if err := a.OnJoin(p3); !errors.Is(err, ErrRoomStopped) {
+		t.Fatalf("expected ErrRoomStopped, got %v", err)
+	}
+	if err := a.OnMessage(p2, 0); !errors.Is(err, ErrRoomStopped) {
+		t.Fatalf("expected ErrRoomStopped, got %v", err)
+	}
+}
+
+func TestActorRoomShedding(t *testing.T) {
+	inner := &counterRoom{players: map[string]bool{}, block: make(chan struct{})}
+	a := NewActorRoom(inner, ActorOptions{QueueSize: 4, MaxPendingPerPlayer: 2, Logger: log.DefaultLogger})
+	p1, p2, p3 := newTestPlayer("p1", "USD"), newTestPlayer("p2", "USD"), newTestPlayer("p3", "USD")
+
+	// 第一条消息被房间协程取走并阻塞
+	a.OnMessage(p1, 0)
+	for len(a.events) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	a.OnMessage(p1, 1)
+	a.OnMessage(p1, 2)
+	if err := a.OnMessage(p1, 3); !errors.Is(err, ErrRoomOverloaded) {
+		t.Fatalf("expected ErrRoomOverloaded, got %v", err)
+	}
+	a.OnMessage(p2, 1)
+	a.OnMessage(p2, 2)
+	// 队列已满，丢弃但不断开
+	if err := a.OnMessage(p3, 1); err != nil {
+		t.Fatalf("expected drop without error, got %v", err)
+	}
+
+	close(inner.block)
+	a.OnDispose()
+	a.Wait()
+	if inner.messages != 5 {
+		t.Fatalf("processed %d messages", inner.messages)
+	}
+}
+
+// 等待执行器销毁
+func waitStopped(t *testing.T, a *ActorRoom) {
+	t.Helper()
+	select {
+	case <-a.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("room should be disposed")
+	}
+}
+
+func TestActorRoomDisposedWhenEmpty(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, ActorRoomCreator(creator, ActorOptions{Logger: log.DefaultLogger}), types.TableMatcherType_RTP, log.DefaultLogger)
+
+	// 切换房间后旧房间空了，需要销毁
+	p1 := newTestPlayer("p1", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	old := p1.GetRoom().(*ActorRoom)
+	if err := rm.SwitchRoom(p1, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitStopped(t, old)
+	if !creator.rooms[0].disposed.Load() {
+		t.Fatal("old room should be disposed after switching")
+	}
+
+	// 房间逻辑让最后一个玩家退出后销毁
+	p2 := newTestPlayer("p2", "USD")
+	rm.OnJoin(p2, "app-98", nil)
+	a := p2.GetRoom().(*ActorRoom)
+	inner := a.Room().(*testRoom)
+	a.Post(func() {
+		delete(inner.players, p2.GetPlayerIdent())
+		a.ExitPlayer(p2, false)
+	})
+	waitStopped(t, a)
+	if !inner.disposed.Load() {
+		t.Fatal("room should be disposed after the last player exits")
+	}
+}
+
+func TestActorRoomPostOverflow(t *testing.T) {
+	inner := &counterRoom{players: map[string]bool{}, block: make(chan struct{})}
+	a := NewActorRoom(inner, ActorOptions{QueueSize: 1, Logger: log.DefaultLogger})
+
+	// 房间协程阻塞在第一条消息上
+	a.OnMessage(newTestPlayer("p1", "USD"), 0)
+	for len(a.events) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 超过队列长度的Post不阻塞、不创建协程，并且按顺序执行
+	goroutines := runtime.NumGoroutine()
+	var order []int
+	for i := 0; i < 1000; i++ {
+		if err := a.Post(func() { order = append(order, i) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := runtime.NumGoroutine(); n > goroutines+10 {
+		t.Fatalf("posting spawned goroutines: %d -> %d", goroutines, n)
+	}
+	done := make(chan struct{})
+	a.Post(func() { close(done) })
+	close(inner.block)
+	<-done
+
+	if len(order) != 1000 {
+		t.Fatalf("ran %d posts", len(order))
+	}
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("post %d ran at %d", v, i)
+		}
+	}
+	a.OnDispose()
+	a.Wait()
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/qd2ss/sfs"
+)
+
+type adminRequest struct {
+	Reason   string `json:"reason"` // 踢人和销毁房间的原因，会发给客户端
+	AppId    string `json:"appId"`  // 广播的过滤条件，为空表示不过滤
+	Currency string `json:"currency"`
+	RoomId   string `json:"roomId"`
+	Message  string `json:"message"` // 维护公告
+}
+
+// 注册管理接口，鉴权由调用方在router上加中间件:
+//
+//	GET  rooms                  所有房间
+//	GET  players/:ident         玩家所在房间和连接状态
+//	POST players/:ident/kick    {"reason"}
+//	POST rooms/:id/dispose      {"reason"}
+//	POST broadcast              {"appId", "currency", "roomId", "message"}
+//	GET  stats                  在线人数和发送队列、限流统计
+//
+// notice在断开玩家之前发送原因，为nil时不通知
+func (r *RoomManager) RegisterAdminRoutes(router fiber.Router, notice func(player types.PlayerImp, reason string)) {
+	withReason := func(reason string) func(types.PlayerImp) {
+		if notice == nil {
+			return nil
+		}
+		return func(player types.PlayerImp) {
+			notice(player, reason)
+		}
+	}
+
+	router.Get("/rooms", func(c *fiber.Ctx) error {
+		return c.JSON(r.Rooms())
+	})
+	router.Get("/players/:ident", func(c *fiber.Ctx) error {
+		state, err := r.PlayerState(c.Params("ident"))
+		if err != nil {
+			return adminError(err)
+		}
+		return c.JSON(state)
+	})
+	router.Post("/players/:ident/kick", r.admin(func(c *fiber.Ctx, req *adminRequest) (interface{}, error) {
+		return nil, r.Kick(c.Params("ident"), withReason(req.Reason))
+	}))
+	router.Post("/rooms/:id/dispose", r.admin(func(c *fiber.Ctx, req *adminRequest) (interface{}, error) {
+		kicked, err := r.DisposeRoom(c.Params("id"), withReason(req.Reason))
+		if err != nil {
+			return nil, err
+		}
+		return fiber.Map{"ok": true, "kicked": kicked}, nil
+	}))
+	router.Post("/broadcast", r.admin(func(c *fiber.Ctx, req *adminRequest) (interface{}, error) {
+		if req.Message == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "message is required")
+		}
+		filters := []BroadcastFilter{}
+		if req.AppId != "" {
+			filters = append(filters, ByAppId(req.AppId))
+		}
+		if req.Currency != "" {
+			filters = append(filters, ByCurrency(req.Currency))
+		}
+		if req.RoomId != "" {
+			room := r.findRoom(req.RoomId)
+			if room == nil {
+				return nil, ErrRoomNotFound
+			}
+			filters = append(filters, ByRoom(room))
+		}
+
+		var data interface{} = map[string]string{"message": req.Message}
+		if r.gameBrand == types.GameBrand_Spribe || r.gameBrand == types.GameBrand_Jdb {
+			data = sfs.SFSObject{"message": req.Message}
+		}
+		result, err := r.Broadcast(And(filters...), &EventPayload{Event: "maintenance", Data: data})
+		if err != nil {
+			return nil, err
+		}
+		return fiber.Map{"sent": result.Sent, "failed": len(result.Failed)}, nil
+	}))
+	router.Get("/stats", func(c *fiber.Ctx) error {
+		online := 0
+		r.players.Range(func(key, value interface{}) bool {
+			online++
+			return true
+		})
+		return c.JSON(fiber.Map{
+			"players":   online,
+			"rooms":     len(r.allRooms()),
+			"draining":  r.IsDraining(),
+			"sendQueue": GetSendQueueMetrics(),
+			"flood":     GetFloodMetrics(),
+		})
+	})
+}
+
+func (r *RoomManager) admin(fn func(c *fiber.Ctx, req *adminRequest) (interface{}, error)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req adminRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+		data, err := fn(c, &req)
+		if err != nil {
+			return adminError(err)
+		}
+		if data == nil {
+			data = fiber.Map{"ok": true}
+		}
+		return c.JSON(data)
+	}
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, ErrPlayerNotFound), errors.Is(err, ErrRoomNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, ErrBrandNotSupported):
+		return fiber.NewError(fiber.StatusNotImplemented, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/qd2ss/sfs"
+)
+
+// 同时发送的协程数，一个玩家写得慢不会挡住其他玩家
+const broadcastWorkers = 64
+
+var ErrBrandNotSupported = errors.New("broadcast: event payload not supported by game brand")
+
+// 编码好的一帧数据
+type Frame struct {
+	Binary bool
+	Data   []byte
+}
+
+// 广播的内容，每次广播按品牌只编码一次
+type Payload interface {
+	Encode(gameBrand types.GameBrand) (*Frame, error)
+}
+
+// 已经编码好的数据原样发送
+func (f *Frame) Encode(types.GameBrand) (*Frame, error) {
+	return f, nil
+}
+
+// 按品牌编码的事件: inout为42["Event",Data]，spribe、jdb为扩展消息，Data需要是sfs.SFSObject
+type EventPayload struct {
+	Event string
+	Data  interface{}
+}
+
+func (e *EventPayload) Encode(gameBrand types.GameBrand) (*Frame, error) {
+	switch gameBrand {
+	case types.GameBrand_Inout:
+		buff, err := json.Marshal([]interface{}{e.Event, e.Data})
+		if err != nil {
+			return nil, err
+		}
+		return &Frame{Data: append([]byte(types.DefaultMsgId), buff...)}, nil
+
+	case types.GameBrand_Spribe, types.GameBrand_Jdb:
+		data, ok := e.Data.(sfs.SFSObject)
+		if !ok && e.Data != nil {
+			return nil, fmt.Errorf("broadcast: %s payload must be sfs.SFSObject, got %T", gameBrand, e.Data)
+		}
+		if data == nil {
+			data = sfs.SFSObject{}
+		}
+		buff, err := utils.PackCustomData(e.Event, data)
+		if err != nil {
+			return nil, err
+		}
+		return &Frame{Binary: true, Data: buff}, nil
+	}
+	return nil, ErrBrandNotSupported
+}
+
+// 挑选接收广播的玩家，nil表示所有玩家
+type BroadcastFilter func(player types.PlayerImp) bool
+
+func ByAppId(appId string) BroadcastFilter {
+	return func(player types.PlayerImp) bool {
+		return player.GetAppId() == appId
+	}
+}
+
+func ByCurrency(currency string) BroadcastFilter {
+	return func(player types.PlayerImp) bool {
+		return player.GetCurrency() == currency
+	}
+}
+
+// 房间里的玩家，包括观战玩家
+func ByRoom(room types.RoomImp) BroadcastFilter {
+	return func(player types.PlayerImp) bool {
+		return player.GetRoom() == room
+	}
+}
+
+// 不包括观战玩家
+func ExcludeSpectators() BroadcastFilter {
+	return func(player types.PlayerImp) bool {
+		return !types.IsSpectator(player)
+	}
+}
+
+// 同时满足所有条件
+func And(filters ...BroadcastFilter) BroadcastFilter {
+	return func(player types.PlayerImp) bool {
+		for _, filter := range filters {
+			if filter != nil && !filter(player) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type SendError struct {
+	PlayerIdent string
+	Err         error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("send to %s: %v", e.PlayerIdent, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+type BroadcastResult struct {
+	Sent   int
+	Failed []*SendError
+}
+
+// 所有发送失败的错误，没有失败时返回nil
+func (r *BroadcastResult) Err() error {
+	errs := make([]error, 0, len(r.Failed))
+	for _, e := range r.Failed {
+		errs = append(errs, e)
+	}
+	return errors.Join(errs...)
+}
+
+// 向所有在线玩家(包括观战玩家)中满足filter的玩家广播
+func (r *RoomManager) Broadcast(filter BroadcastFilter, payload Payload) (*BroadcastResult, error) {
+	var players []types.PlayerImp
+	r.players.Range(func(key, value interface{}) bool {
+		if player, ok := value.(types.PlayerImp); ok && player.IsConnect() && (filter == nil || filter(player)) {
+			players = append(players, player)
+		}
+		return true
+	})
+	return Multicast(r.gameBrand, players, payload)
+}
+
+// 向一个房间的所有在线玩家广播
+func (r *RoomManager) BroadcastRoom(room types.RoomImp, payload Payload) (*BroadcastResult, error) {
+	return r.Broadcast(ByRoom(room), payload)
+}
+
+// 编码一次后并发发送给players，房间可以直接使用自己维护的玩家列表
+func Multicast(gameBrand types.GameBrand, players []types.PlayerImp, payload Payload) (*BroadcastResult, error) {
+	frame, err := payload.Encode(gameBrand)
+	if err != nil {
+		return nil, err
+	}
+	return SendFrame(players, frame), nil
+}
+
+// 并发发送同一帧数据，收集每个玩家的发送错误
+func SendFrame(players []types.PlayerImp, frame *Frame) *BroadcastResult {
+	result := &BroadcastResult{}
+	if len(players) == 0 {
+		return result
+	}
+
+	var mu sync.Mutex
+	jobs := make(chan types.PlayerImp)
+	var wg sync.WaitGroup
+	for i := 0; i < min(broadcastWorkers, len(players)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for player := range jobs {
+				var err error
+				if frame.Binary {
+					err = player.SendBinary(frame.Data)
+				} else {
+					err = player.SendString(string(frame.Data))
+				}
+				mu.Lock()
+				if err != nil {
+					result.Failed = append(result.Failed, &SendError{PlayerIdent: player.GetPlayerIdent(), Err: err})
+				} else {
+					result.Sent++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, player := range players {
+		jobs <- player
+	}
+	close(jobs)
+	wg.Wait()
+	return result
+}
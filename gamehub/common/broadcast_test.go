@@ -0,0 +1,101 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/player"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/qd2ss/sfs"
+)
+
+type recordPlayer struct {
+	*Player
+	mu     sync.Mutex
+	frames []string
+	fail   bool
+}
+
+func newRecordPlayer(brand types.GameBrand, appId, id, currency string) *recordPlayer {
+	return &recordPlayer{Player: NewPlayer(brand, nil, &player.PlayerInfo{AppID: appId, PlayerID: id, Currency: currency}, nil, nil)}
+}
+
+func (p *recordPlayer) IsConnect() bool { return true }
+func (p *recordPlayer) SendString(msg string) error {
+	if p.fail {
+		return errors.New("broken pipe")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.frames = append(p.frames, msg)
+	return nil
+}
+func (p *recordPlayer) SendBinary(data []byte) error { return p.SendString(string(data)) }
+
+func (p *recordPlayer) received() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.frames...)
+}
+
+func TestBroadcast(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Inout, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+
+	usd := newRecordPlayer(types.GameBrand_Inout, "app1", "p1", "USD")
+	eur := newRecordPlayer(types.GameBrand_Inout, "app1", "p2", "EUR")
+	other := newRecordPlayer(types.GameBrand_Inout, "app2", "p3", "USD")
+	broken := newRecordPlayer(types.GameBrand_Inout, "app1", "p4", "USD")
+	broken.fail = true
+	rm.OnJoin(usd, "app1-97", nil)
+	rm.OnJoin(eur, "app1-97", nil)
+	rm.OnJoin(other, "app2-97", nil)
+	rm.OnJoin(broken, "app1-97", nil)
+
+	result, err := rm.Broadcast(And(ByAppId("app1"), ByCurrency("USD")), &EventPayload{Event: "jackpot", Data: map[string]float64{"win": 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sent != 1 || len(result.Failed) != 1 || result.Failed[0].PlayerIdent != "app1-p4" || result.Err() == nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if got := usd.received(); len(got) != 1 || got[0] != `42["jackpot",{"win":100}]` {
+		t.Fatalf("unexpected frames %v", got)
+	}
+	if len(eur.received()) != 0 || len(other.received()) != 0 {
+		t.Fatal("filtered players should not receive")
+	}
+
+	result, err = rm.BroadcastRoom(creator.rooms[1], &Frame{Data: []byte("tick")})
+	if err != nil || result.Sent != 1 || result.Err() != nil {
+		t.Fatalf("unexpected result %+v %v", result, err)
+	}
+	if got := other.received(); len(got) != 1 || got[0] != "tick" {
+		t.Fatalf("unexpected frames %v", got)
+	}
+}
+
+func TestEventPayloadSpribe(t *testing.T) {
+	payload := &EventPayload{Event: "x", Data: sfs.SFSObject{"multiplier": 1.5}}
+	frame, err := payload.Encode(types.GameBrand_Spribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, data, err := utils.Unpack(frame.Data)
+	if err != nil || !frame.Binary {
+		t.Fatalf("unexpected frame %v", err)
+	}
+	if data["c"] != "x" || data["p"].(sfs.SFSObject)["multiplier"] != 1.5 {
+		t.Fatalf("spribe payload should be packed as custom data: %v", data)
+	}
+
+	if _, err := (&EventPayload{Event: "x", Data: 1}).Encode(types.GameBrand_Spribe); err == nil {
+		t.Fatal("spribe payload requires SFSObject")
+	}
+	if _, err := payload.Encode(types.GameBrand_Jili); !errors.Is(err, ErrBrandNotSupported) {
+		t.Fatalf("unexpected err %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package common
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	fasthttp_websocket "github.com/fasthttp/websocket"
+	"github.com/gofiber/contrib/websocket"
+)
+
+var (
+	// 超过限制，这条消息被丢弃，需要回复客户端
+	ErrRateLimited = errors.New("too many requests")
+	// 超过限制的次数太多，需要断开连接
+	ErrFlooding = errors.New("message flooding, disconnect")
+)
+
+// 令牌桶: 每秒补充Rate个，最多Burst个，Rate为0表示不限制
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type FloodOptions struct {
+	Conn RateLimit // 一个连接的所有消息
+	// 按消息限制，inout为socket.io事件名，gameService为gameService:<action>，
+	// spribe、jdb为扩展消息的cmd，jili为Command.Type
+	Actions map[string]RateLimit
+	// ViolationWindow内超过限制MaxViolations次后断开，MaxViolations为0表示不断开
+	MaxViolations   int
+	ViolationWindow time.Duration
+
+	MaxFrameSize int64         // 单条消息的最大字节数，超过时断开，0表示不限制
+	IdleTimeout  time.Duration // 多久没有收到消息断开，0表示不限制
+}
+
+// 推荐的配置
+func DefaultFloodOptions() FloodOptions {
+	return FloodOptions{
+		Conn: RateLimit{Rate: 20, Burst: 40},
+		Actions: map[string]RateLimit{
+			"gameService-latencyTest": {Rate: 1, Burst: 5},
+		},
+		MaxViolations:   20,
+		ViolationWindow: 10 * time.Second,
+		MaxFrameSize:    64 * 1024,
+		IdleTimeout:     90 * time.Second,
+	}
+}
+
+type FloodMetrics struct {
+	RateLimited    int64 // 被丢弃的消息数
+	OversizeFrames int64
+	IdleTimeouts   int64
+	Disconnects    int64 // 因为超过限制次数太多被断开的连接数
+}
+
+var floodMetrics struct {
+	rateLimited    atomic.Int64
+	oversizeFrames atomic.Int64
+	idleTimeouts   atomic.Int64
+	disconnects    atomic.Int64
+}
+
+func GetFloodMetrics() FloodMetrics {
+	return FloodMetrics{
+		RateLimited:    floodMetrics.rateLimited.Load(),
+		OversizeFrames: floodMetrics.oversizeFrames.Load(),
+		IdleTimeouts:   floodMetrics.idleTimeouts.Load(),
+		Disconnects:    floodMetrics.disconnects.Load(),
+	}
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Burst <= 0 {
+		limit.Burst = max(1, int(limit.Rate))
+	}
+	return &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()*b.limit.Rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// 一个连接的消息限流，在连接的读协程中使用，为nil时不限制
+type FloodGuard struct {
+	opts  FloodOptions
+	clock Clock
+
+	mu         sync.Mutex
+	conn       *tokenBucket
+	actions    map[string]*tokenBucket
+	violations *tokenBucket // 每次超过限制消耗一个，用完后断开
+}
+
+func NewFloodGuard(opts FloodOptions) *FloodGuard {
+	return newFloodGuard(opts, SystemClock)
+}
+
+func newFloodGuard(opts FloodOptions, clock Clock) *FloodGuard {
+	now := clock.Now()
+	g := &FloodGuard{opts: opts, clock: clock, actions: make(map[string]*tokenBucket)}
+	if opts.Conn.Rate > 0 {
+		g.conn = newTokenBucket(opts.Conn, now)
+	}
+	if opts.MaxViolations > 0 {
+		window := opts.ViolationWindow
+		if window <= 0 {
+			window = 10 * time.Second
+		}
+		g.violations = newTokenBucket(RateLimit{
+			Rate:  float64(opts.MaxViolations) / window.Seconds(),
+			Burst: opts.MaxViolations,
+		}, now)
+	}
+	return g
+}
+
+// 连接建立后调用，设置最大消息长度
+func (g *FloodGuard) Watch(conn *websocket.Conn) {
+	if g != nil && g.opts.MaxFrameSize > 0 {
+		conn.SetReadLimit(g.opts.MaxFrameSize)
+	}
+}
+
+// 每次ReadMessage之前调用，设置空闲超时
+func (g *FloodGuard) BeforeRead(conn *websocket.Conn) {
+	if g != nil && g.opts.IdleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(g.opts.IdleTimeout))
+	}
+}
+
+// ReadMessage出错时调用，统计超长消息和空闲超时
+func (g *FloodGuard) OnReadError(err error) {
+	if g == nil {
+		return
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, fasthttp_websocket.ErrReadLimit):
+		floodMetrics.oversizeFrames.Add(1)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		floodMetrics.idleTimeouts.Add(1)
+	}
+}
+
+// 收到一条消息时调用，action为空时只检查连接的限制。
+// 返回ErrRateLimited时丢弃消息并回复客户端，返回ErrFlooding时断开连接
+func (g *FloodGuard) Allow(action string) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.clock.Now()
+
+	allowed := g.conn == nil || g.conn.take(now)
+	if allowed && action != "" {
+		if limit, ok := g.opts.Actions[action]; ok && limit.Rate > 0 {
+			bucket, ok := g.actions[action]
+			if !ok {
+				bucket = newTokenBucket(limit, now)
+				g.actions[action] = bucket
+			}
+			allowed = bucket.take(now)
+		}
+	}
+	if allowed {
+		return nil
+	}
+
+	floodMetrics.rateLimited.Add(1)
+	if g.violations != nil && !g.violations.take(now) {
+		floodMetrics.disconnects.Add(1)
+		return ErrFlooding
+	}
+	return ErrRateLimited
+}
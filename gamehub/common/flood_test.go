@@ -0,0 +1,62 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFloodGuard(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	g := newFloodGuard(FloodOptions{
+		Conn:            RateLimit{Rate: 10, Burst: 10},
+		Actions:         map[string]RateLimit{"gameService-latencyTest": {Rate: 1, Burst: 2}},
+		MaxViolations:   3,
+		ViolationWindow: 10 * time.Second,
+	}, clock)
+
+	// 按action的限制
+	for i := 0; i < 2; i++ {
+		if err := g.Allow("gameService-latencyTest"); err != nil {
+			t.Fatalf("latencyTest %d: %v", i, err)
+		}
+	}
+	if err := g.Allow("gameService-latencyTest"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limited, got %v", err)
+	}
+	// 其它消息只受连接的限制
+	if err := g.Allow("gameService:bet"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if err := g.Allow("gameService-latencyTest"); err != nil {
+		t.Fatalf("bucket should refill: %v", err)
+	}
+
+	// 连接的限制，超过次数太多后断开
+	before := GetFloodMetrics()
+	var err error
+	for i := 0; i < 20 && !errors.Is(err, ErrFlooding); i++ {
+		err = g.Allow("")
+	}
+	if !errors.Is(err, ErrFlooding) {
+		t.Fatalf("expected flooding, got %v", err)
+	}
+	after := GetFloodMetrics()
+	if after.Disconnects != before.Disconnects+1 || after.RateLimited != before.RateLimited+3 {
+		t.Fatalf("unexpected metrics %+v -> %+v", before, after)
+	}
+}
+
+func TestFloodGuardDisabled(t *testing.T) {
+	var nilGuard *FloodGuard
+	if err := nilGuard.Allow("bet"); err != nil {
+		t.Fatal(err)
+	}
+	g := NewFloodGuard(FloodOptions{})
+	for i := 0; i < 1000; i++ {
+		if err := g.Allow("bet"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -56,6 +56,9 @@ func (l *NoLobby) OnLogin(player types.PlayerImp) error {
 			return l.roomManager.OnJoin(player, "", &types.SingleRoomArgs{
 				Appid: player.GetPlayerInfo().AppID,
 			})
+
+		case types.TableMatcherType_CUSTOM:
+			return l.roomManager.OnMatch(player)
 		}
 
 		return fmt.Errorf("tableMatcherType not support")
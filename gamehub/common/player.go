@@ -24,6 +24,10 @@ type Player struct {
 	conn      *websocket.Conn
 	mu        sync.Mutex // 新增互斥锁
 
+	sendOpts SendQueueOptions // 发送队列配置，第一次发送时创建队列
+	queue    *SendQueue
+
+	roomMu      sync.RWMutex // 房间可能在自己的协程中读写，与连接的读协程并发
 	room        types.RoomImp
 	roomManager types.RoomManagerImp
 
@@ -45,10 +49,34 @@ func NewPlayer(gameBrand types.GameBrand, conn *websocket.Conn, PlayerInfo *play
 	}
 }
 
-func (p *Player) SetConn(conn *websocket.Conn) {
+// 设置发送队列，需要在第一次发送之前调用
+func (p *Player) SetSendQueueOptions(opts SendQueueOptions) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.sendOpts = opts
+}
+
+// 发送队列的状态，还没有发送过消息时为空
+func (p *Player) SendQueueStats() SendQueueStats {
+	p.mu.Lock()
+	q := p.queue
+	p.mu.Unlock()
+	if q == nil {
+		return SendQueueStats{}
+	}
+	return q.Stats()
+}
+
+// 更换连接时丢弃旧连接还没写出的消息，返回时旧连接的写协程已经退出
+func (p *Player) SetConn(conn *websocket.Conn) {
+	p.mu.Lock()
+	q := p.queue
+	p.queue = nil
 	p.conn = conn
+	p.mu.Unlock()
+	if q != nil {
+		q.Close(false)
+	}
 }
 
 func (p *Player) GetConn() *websocket.Conn {
@@ -57,28 +85,41 @@ func (p *Player) GetConn() *websocket.Conn {
 	return p.conn
 }
 
+// 等待排队的消息写完(最长FlushTimeout)后关闭连接
 func (p *Player) CloseConn() {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.conn != nil {
-		p.conn.Close()
-		p.conn = nil
+	conn, q := p.conn, p.queue
+	p.conn, p.queue = nil, nil
+	p.mu.Unlock()
+	if q != nil {
+		q.Close(true)
+	}
+	if conn != nil {
+		conn.Close()
 	}
 }
 
 func (p *Player) SetRoom(room types.RoomImp) {
+	p.roomMu.Lock()
+	defer p.roomMu.Unlock()
 	p.room = room
 }
 
 func (p *Player) GetRoom() types.RoomImp {
+	p.roomMu.RLock()
+	defer p.roomMu.RUnlock()
 	return p.room
 }
 
 func (p *Player) GetRoomManager() types.RoomManagerImp {
+	p.roomMu.RLock()
+	defer p.roomMu.RUnlock()
 	return p.roomManager
 }
 
 func (p *Player) SetRoomManager(roomManager types.RoomManagerImp) {
+	p.roomMu.Lock()
+	defer p.roomMu.Unlock()
 	p.roomManager = roomManager
 }
 
@@ -90,26 +131,31 @@ func (p *Player) SendBinary(data []byte) error {
 	return p.send(websocket.BinaryMessage, data)
 }
 
-// 直接发送原始文本消息, messageType有websocket.TextMessage和websocket.BinaryMessage
+// 放入发送队列后立即返回，由写协程写出, messageType有websocket.TextMessage和websocket.BinaryMessage
 func (p *Player) send(messageType int, data []byte) error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.conn != nil {
-		if err := p.conn.WriteMessage(messageType, data); err != nil {
-			p.conn.Close()
-		}
+	if p.conn == nil {
+		p.mu.Unlock()
+		return nil
 	}
-	return nil
+	if p.queue == nil {
+		p.queue = newSendQueue(p.conn, p.sendOpts, p.log)
+	}
+	q := p.queue
+	p.mu.Unlock()
+	return q.Push(messageType, data)
 }
 
 func (p *Player) IsConnect() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	return p.conn != nil
 }
 
 // 从房间移出去
 func (p *Player) ExitRoom(isDisconnect bool) error {
-	if p.roomManager != nil {
-		p.roomManager.ExitRoom(p, isDisconnect)
+	if roomManager := p.GetRoomManager(); roomManager != nil {
+		roomManager.ExitRoom(p, isDisconnect)
 	}
 	return nil
 }
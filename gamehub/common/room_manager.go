@@ -3,10 +3,12 @@ package common
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/card-engine/game_common/gamehub/const_val"
 	"github.com/card-engine/game_common/gamehub/event"
+	"github.com/card-engine/game_common/gamehub/fairness"
 	"github.com/card-engine/game_common/gamehub/types"
 	"github.com/card-engine/game_common/sfs/utils"
 	"github.com/go-kratos/kratos/v2/log"
@@ -26,12 +28,32 @@ type RoomManager struct {
 	playerRoomMapMu sync.RWMutex
 
 	tableMatcherType types.TableMatcherType
+	tableMatcher     types.TableMatcher
 
 	players sync.Map // 存储玩家
 
-	log *log.Helper
+	spectators map[types.RoomImp]map[string]types.PlayerImp // 每个房间的观战玩家，由roomMapMu保护
+
+	log    *log.Helper
+	logger log.Logger
 
 	tw *timewheel.TimeWheel //时间轮
+
+	reconnectGrace time.Duration // 断线后保留座位的时间，0表示一直保留
+
+	draining            atomic.Bool // 节点下线排空中
+	unfinishedRoundHook types.UnfinishedRoundHook
+
+	clock        Clock // 房间定时器使用的时钟
+	roomTimers   map[types.RoomImp]*RoomTimers
+	roomTimersMu sync.Mutex
+
+	roomSeq    atomic.Int64 // 房间编号，管理接口使用
+	roomMeta   map[types.RoomImp]*roomMeta
+	roomMetaMu sync.RWMutex
+
+	clientSeeds   fairness.ClientSeedStore // 玩家的客户端种子
+	clientSeedsMu sync.RWMutex
 }
 
 func NewRoomManager(
@@ -43,24 +65,29 @@ func NewRoomManager(
 		gameBrand:        gameBrand,
 		roomCreator:      roomCreator,
 		log:              log.NewHelper(logger),
+		logger:           logger,
 		tableMatcherType: tableMatcherType,
 		roomMap:          make(map[string][]types.RoomImp),
 		playerRoomMap:    make(map[string]types.RoomImp),
+		spectators:       make(map[types.RoomImp]map[string]types.PlayerImp),
+		clock:            SystemClock,
+		roomTimers:       make(map[types.RoomImp]*RoomTimers),
+		roomMeta:         make(map[types.RoomImp]*roomMeta),
+		clientSeeds:      fairness.NewMemoryClientSeedStore(),
 	}
 
+	tw := timewheel.New(1*time.Second, 3600, func(data interface{}) {
+		rm.onTimer(data)
+	})
+
+	// 启动时间轮
+	tw.Start()
+	rm.tw = tw
+
 	//==================================================仅和inout有关系===========================================================
 	// inout需要使用定时器发送心跳
 	if gameBrand == types.GameBrand_Inout {
-		tw := timewheel.New(1*time.Second, 3600, func(data interface{}) {
-			rm.onTimer(data)
-		})
-
-		// 启动时间轮
-		tw.Start()
-
 		tw.AddTimer(time.Duration(const_val.InoutPingTime)*time.Second, const_val.InoutPingTimeWheelKey, &event.PingPongEvent{})
-
-		rm.tw = tw
 	}
 	//============================================================================================================================
 
@@ -68,6 +95,11 @@ func NewRoomManager(
 }
 
 func (r *RoomManager) ExitRoom(player types.PlayerImp, isDisconnect bool) {
+	if types.IsSpectator(player) {
+		r.leaveSpectate(player, isDisconnect)
+		return
+	}
+
 	room := player.GetRoom()
 
 	defer func() {
@@ -91,28 +123,19 @@ func (r *RoomManager) ExitRoom(player types.PlayerImp, isDisconnect bool) {
 		player.CloseConn()
 	}
 
+	// 排空时房间统一由Drain销毁
+	if r.IsDraining() {
+		return
+	}
+
 	// 如果是一次性房间，那么通知room也释放内存
 	if r.tableMatcherType == types.TableMatcherType_SINGLE {
-		room.OnDispose()
+		r.disposeRoom(room)
 	} else {
 		r.roomMapMu.Lock()
-		// 检测到了空房间，则销毁房间
-		if room.GetPlayerNum() <= 0 {
-			for roomType, rooms := range r.roomMap {
-				kept := rooms[:0]
-				for _, one := range rooms {
-					if one != room {
-						kept = append(kept, one)
-					}
-				}
-				if len(kept) == 0 {
-					delete(r.roomMap, roomType)
-				} else {
-					r.roomMap[roomType] = kept
-				}
-			}
-
-			room.OnDispose()
+		// 检测到了空房间，则销毁房间；还有观战玩家时等最后一个观战玩家离开再销毁
+		if room.GetPlayerNum() <= 0 && len(r.spectators[room]) == 0 {
+			r.disposeRoomLocked(room)
 		}
 		r.roomMapMu.Unlock()
 	}
@@ -129,10 +152,13 @@ func (r *RoomManager) TryReConnectGame(player types.PlayerImp) (error, bool) {
 	player.SetRoom(room)
 
 	if ok {
+		// 回来了，取消座位超时
+		r.tw.RemoveTimer(const_val.SeatExpireTimeWheelKeyPrefix + playerIdent)
+
 		if value, ok := r.players.Load(playerIdent); ok {
-			if oldPlayer, ok := value.(*Player); ok && oldPlayer.conn != nil {
+			if oldPlayer, ok := value.(*Player); ok && types.PlayerImp(oldPlayer) != player {
 				// 以防止，旧的客户端没有完全处理干净
-				oldPlayer.conn.Close()
+				oldPlayer.CloseConn()
 			}
 		}
 
@@ -148,6 +174,9 @@ func (r *RoomManager) TryReConnectGame(player types.PlayerImp) (error, bool) {
 
 // 切换房间，可能因为rtp发生了变化，然后需要切换房间。
 func (r *RoomManager) SwitchRoom(player types.PlayerImp, args interface{}) error {
+	if r.IsDraining() {
+		return ErrDraining
+	}
 	// 从旧房间移除（不关闭连接），再进入新房间
 	if oldRoom := player.GetRoom(); oldRoom != nil {
 		_ = oldRoom.OnDisConnect(player)
@@ -157,19 +186,20 @@ func (r *RoomManager) SwitchRoom(player types.PlayerImp, args interface{}) error
 
 	player.SetRoomManager(r)
 
-	roomTypeStr := fmt.Sprintf("%v-%v", player.GetPlayerInfo().AppID, player.GetRtpStr())
-	roomArgs := &types.RtpRoomArgs{
-		Appid:    player.GetPlayerInfo().AppID,
-		Rtp:      player.GetRtpStr(),
-		Currency: player.GetPlayerInfo().Currency,
-	}
-	room := r.roomCreator.CreateRoom(roomArgs)
+	roomTypeStr, roomArgs := r.group(player)
 
 	r.roomMapMu.Lock()
+	// 排空在roomMapMu下设置，这里再检查一次，避免排空开始后又创建房间
+	if r.IsDraining() {
+		r.roomMapMu.Unlock()
+		return ErrDraining
+	}
+	room := r.createRoom(roomArgs)
 	if err := room.OnSwitch(player, args); err == nil {
 		player.SetRoom(room)
 		r.roomMap[roomTypeStr] = append(r.roomMap[roomTypeStr], room)
 	} else {
+		r.releaseRoom(room)
 		r.log.Errorf("switch room create room %s failed, err: %v", roomTypeStr, err)
 		r.roomMapMu.Unlock()
 		return err
@@ -186,11 +216,21 @@ func (r *RoomManager) SwitchRoom(player types.PlayerImp, args interface{}) error
 }
 
 func (r *RoomManager) OnJoin(player types.PlayerImp, roomType string, roomArgs interface{}) error {
+	if r.IsDraining() {
+		return ErrDraining
+	}
 	player.SetRoomManager(r)
 
 	r.roomMapMu.Lock()
+	if r.IsDraining() {
+		r.roomMapMu.Unlock()
+		return ErrDraining
+	}
 	if roomType != "" {
 		if rooms, ok := r.roomMap[roomType]; ok {
+			if r.tableMatcher != nil {
+				rooms = r.tableMatcher.Candidates(player, roomArgs, rooms)
+			}
 			for _, room := range rooms {
 				// 找到了一个可以登陆的房间
 				if err := room.OnJoin(player); err == nil {
@@ -202,10 +242,11 @@ func (r *RoomManager) OnJoin(player types.PlayerImp, roomType string, roomArgs i
 	}
 
 	if player.GetRoom() == nil {
-		room := r.roomCreator.CreateRoom(roomArgs)
+		room := r.createRoom(roomArgs)
 		if err := room.OnJoin(player); err == nil {
 			player.SetRoom(room)
 		} else {
+			r.releaseRoom(room)
 			r.log.Errorf("create room %s failed, err: %v", roomType, err)
 			r.roomMapMu.Unlock()
 			return err
@@ -228,6 +269,12 @@ func (r *RoomManager) OnJoin(player types.PlayerImp, roomType string, roomArgs i
 }
 
 func (r *RoomManager) OnMessage(player types.PlayerImp, msg interface{}) error {
+	// 观战玩家的游戏操作在这里统一拒绝，房间不需要自己判断
+	if types.IsSpectator(player) && !isSpectatorMessage(msg) {
+		r.log.Warnf("spectator %s game action rejected", player.GetPlayerIdent())
+		return nil
+	}
+
 	if r.gameBrand == types.GameBrand_Spribe {
 		msgData, ok := msg.(sfs.SFSObject)
 		if !ok {
@@ -268,16 +315,89 @@ func (r *RoomManager) OnMessage(player types.PlayerImp, msg interface{}) error {
 }
 
 func (r *RoomManager) OnDisConnect(player types.PlayerImp) error {
+	// 观战玩家不保留座位，断线即离开
+	if types.IsSpectator(player) {
+		r.leaveSpectate(player, false)
+		return nil
+	}
 
 	room := player.GetRoom()
-	if room != nil {
-		return room.OnDisConnect(player)
+	if room == nil {
+		return nil
 	}
-	return nil
+	err := room.OnDisConnect(player)
+	r.startSeatTimer(player)
+	return err
+}
+
+// 创建房间，需要定时器的房间注入定时器
+func (r *RoomManager) createRoom(roomArgs interface{}) types.RoomImp {
+	room := r.roomCreator.CreateRoom(roomArgs)
+	actor, isActor := room.(*ActorRoom)
+	inner := room
+	if isActor {
+		inner = actor.Room()
+	}
+	// 定时器回调不能和玩家消息并发执行，没有使用执行器的定时房间在这里包装
+	if timed, ok := inner.(types.TimedRoomImp); ok {
+		if !isActor {
+			actor = NewActorRoom(room, ActorOptions{Logger: r.logger})
+			room = actor
+		}
+		timed.SetTimers(r.newTimers(actor))
+	}
+	r.registerRoom(room, roomArgs)
+	return room
+}
+
+// 取消房间的定时器后销毁房间
+func (r *RoomManager) disposeRoom(room types.RoomImp) {
+	r.releaseRoom(room)
+	room.OnDispose()
+}
+
+// 房间创建失败或者销毁时释放定时器和登记信息
+func (r *RoomManager) releaseRoom(room types.RoomImp) {
+	r.stopTimers(room)
+	r.unregisterRoom(room)
+}
+
+// 从roomMap中移除并销毁房间，房间里的观战玩家会被断开，调用方需持有roomMapMu
+func (r *RoomManager) disposeRoomLocked(room types.RoomImp) {
+	for roomType, rooms := range r.roomMap {
+		kept := rooms[:0]
+		for _, one := range rooms {
+			if one != room {
+				kept = append(kept, one)
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.roomMap, roomType)
+		} else {
+			r.roomMap[roomType] = kept
+		}
+	}
+
+	watchers := r.spectators[room]
+	delete(r.spectators, room)
+	for ident, watcher := range watchers {
+		r.players.Delete(ident)
+		watcher.SetRoom(nil)
+		watcher.SetRoomManager(nil)
+		watcher.CloseConn()
+	}
+
+	r.disposeRoom(room)
 }
 
 // 定时器处理
 func (r *RoomManager) onTimer(data interface{}) {
+	switch ev := data.(type) {
+	case *event.SeatExpireEvent:
+		r.onSeatExpired(ev.PlayerIdent)
+		return
+	}
+
 	if r.gameBrand == types.GameBrand_Inout {
 		r.onInoutTimer(data)
 	}
@@ -294,18 +414,7 @@ func (r *RoomManager) onInoutTimer(data interface{}) {
 }
 
 func (r *RoomManager) broadInoutPing() {
-	players := []types.PlayerImp{}
-
-	r.players.Range(func(key, value interface{}) bool {
-		if player, ok := value.(types.PlayerImp); ok && player.IsConnect() {
-			players = append(players, player)
-		}
-		return true // 继续迭代
-	})
-
-	for _, play := range players {
-		play.SendString("2")
-	}
+	r.Broadcast(nil, &Frame{Data: []byte("2")})
 }
 
 //============================================================================================================================
@@ -0,0 +1,242 @@
+package common
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+var (
+	ErrPlayerNotFound = errors.New("player not found")
+	ErrRoomNotFound   = errors.New("room not found")
+)
+
+type roomMeta struct {
+	id        string
+	args      interface{}
+	createdAt int64
+}
+
+// 管理接口展示的房间信息
+type RoomInfo struct {
+	Id           string      `json:"id"`
+	RoomType     string      `json:"roomType"` // 单人房间为空
+	PlayerNum    int32       `json:"playerNum"`
+	SpectatorNum int         `json:"spectatorNum"`
+	Args         interface{} `json:"args"`
+	CreatedAt    int64       `json:"createdAt"` // 毫秒
+}
+
+// 管理接口展示的玩家状态
+type PlayerState struct {
+	PlayerIdent string          `json:"playerIdent"`
+	RoomId      string          `json:"roomId,omitempty"`
+	Seated      bool            `json:"seated"`    // 占着座位，断线后在重连之前也为true
+	Connected   bool            `json:"connected"` // 连接是否还在
+	Spectator   bool            `json:"spectator"`
+	SendQueue   *SendQueueStats `json:"sendQueue,omitempty"`
+}
+
+func (r *RoomManager) registerRoom(room types.RoomImp, roomArgs interface{}) {
+	r.roomMetaMu.Lock()
+	defer r.roomMetaMu.Unlock()
+	r.roomMeta[room] = &roomMeta{
+		id:        strconv.FormatInt(r.roomSeq.Add(1), 10),
+		args:      roomArgs,
+		createdAt: r.clock.Now().UnixMilli(),
+	}
+}
+
+func (r *RoomManager) unregisterRoom(room types.RoomImp) {
+	r.roomMetaMu.Lock()
+	defer r.roomMetaMu.Unlock()
+	delete(r.roomMeta, room)
+}
+
+func (r *RoomManager) roomId(room types.RoomImp) string {
+	r.roomMetaMu.RLock()
+	defer r.roomMetaMu.RUnlock()
+	if meta, ok := r.roomMeta[room]; ok {
+		return meta.id
+	}
+	return ""
+}
+
+// 所有房间，按编号排序
+func (r *RoomManager) Rooms() []*RoomInfo {
+	roomTypes := make(map[types.RoomImp]string)
+	spectatorNum := make(map[types.RoomImp]int)
+	r.roomMapMu.RLock()
+	for roomType, rooms := range r.roomMap {
+		for _, room := range rooms {
+			roomTypes[room] = roomType
+		}
+	}
+	for room, watchers := range r.spectators {
+		spectatorNum[room] = len(watchers)
+	}
+	r.roomMapMu.RUnlock()
+
+	rooms := r.allRooms()
+	infos := make([]*RoomInfo, 0, len(rooms))
+	r.roomMetaMu.RLock()
+	for _, room := range rooms {
+		info := &RoomInfo{
+			RoomType:     roomTypes[room],
+			PlayerNum:    room.GetPlayerNum(),
+			SpectatorNum: spectatorNum[room],
+		}
+		if meta, ok := r.roomMeta[room]; ok {
+			info.Id, info.Args, info.CreatedAt = meta.id, meta.args, meta.createdAt
+		}
+		infos = append(infos, info)
+	}
+	r.roomMetaMu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		a, _ := strconv.ParseInt(infos[i].Id, 10, 64)
+		b, _ := strconv.ParseInt(infos[j].Id, 10, 64)
+		return a < b
+	})
+	return infos
+}
+
+func (r *RoomManager) findRoom(roomId string) types.RoomImp {
+	r.roomMetaMu.RLock()
+	defer r.roomMetaMu.RUnlock()
+	for room, meta := range r.roomMeta {
+		if meta.id == roomId {
+			return room
+		}
+	}
+	return nil
+}
+
+// 玩家所在的房间和连接状态
+func (r *RoomManager) PlayerState(playerIdent string) (*PlayerState, error) {
+	r.playerRoomMapMu.RLock()
+	room, seated := r.playerRoomMap[playerIdent]
+	r.playerRoomMapMu.RUnlock()
+
+	value, ok := r.players.Load(playerIdent)
+	if !ok && !seated {
+		return nil, ErrPlayerNotFound
+	}
+
+	state := &PlayerState{PlayerIdent: playerIdent, Seated: seated}
+	if player, ok := value.(types.PlayerImp); ok {
+		state.Connected = player.IsConnect()
+		state.Spectator = types.IsSpectator(player)
+		if room == nil {
+			room = player.GetRoom()
+		}
+		if p, ok := player.(interface{ SendQueueStats() SendQueueStats }); ok {
+			stats := p.SendQueueStats()
+			state.SendQueue = &stats
+		}
+	}
+	if room != nil {
+		state.RoomId = r.roomId(room)
+	}
+	return state, nil
+}
+
+// 踢掉玩家: 发送通知后让房间按座位超时处理未结束的下注，然后移出房间并断开连接
+func (r *RoomManager) Kick(playerIdent string, notice func(player types.PlayerImp)) error {
+	r.playerRoomMapMu.RLock()
+	room, seated := r.playerRoomMap[playerIdent]
+	r.playerRoomMapMu.RUnlock()
+
+	value, ok := r.players.Load(playerIdent)
+	player, _ := value.(types.PlayerImp)
+	if !ok || player == nil {
+		if !seated {
+			return ErrPlayerNotFound
+		}
+		// 只剩座位没有玩家对象，直接释放
+		r.playerRoomMapMu.Lock()
+		delete(r.playerRoomMap, playerIdent)
+		r.playerRoomMapMu.Unlock()
+		return nil
+	}
+
+	if notice != nil && player.IsConnect() {
+		notice(player)
+	}
+
+	if types.IsSpectator(player) {
+		r.ExitRoom(player, true)
+		return nil
+	}
+
+	if room == nil {
+		room = player.GetRoom()
+	}
+	if room == nil {
+		r.players.Delete(playerIdent)
+		player.CloseConn()
+		return nil
+	}
+
+	if player.IsConnect() {
+		if err := room.OnDisConnect(player); err != nil {
+			r.log.Errorf("kick %s OnDisConnect failed: %v", playerIdent, err)
+		}
+	}
+	if expirable, ok := room.(types.SeatExpirableRoomImp); ok {
+		expirable.OnSeatExpired(player)
+	}
+	if player.GetRoom() == nil {
+		player.SetRoom(room)
+	}
+	r.ExitRoom(player, true)
+	return nil
+}
+
+// 强制销毁房间，房间里的玩家和观战玩家收到通知后被断开，返回被断开的玩家数量
+func (r *RoomManager) DisposeRoom(roomId string, notice func(player types.PlayerImp)) (int, error) {
+	room := r.findRoom(roomId)
+	if room == nil {
+		return 0, ErrRoomNotFound
+	}
+
+	var players []types.PlayerImp
+	r.playerRoomMapMu.Lock()
+	for ident, one := range r.playerRoomMap {
+		if one != room {
+			continue
+		}
+		delete(r.playerRoomMap, ident)
+		if value, ok := r.players.LoadAndDelete(ident); ok {
+			if player, ok := value.(types.PlayerImp); ok {
+				players = append(players, player)
+			}
+		}
+	}
+	r.playerRoomMapMu.Unlock()
+
+	r.roomMapMu.RLock()
+	for _, watcher := range r.spectators[room] {
+		players = append(players, watcher)
+	}
+	r.roomMapMu.RUnlock()
+
+	for _, player := range players {
+		if notice != nil && player.IsConnect() {
+			notice(player)
+		}
+		if !types.IsSpectator(player) {
+			player.SetRoom(nil)
+			player.SetRoomManager(nil)
+			player.CloseConn()
+		}
+	}
+
+	// 观战玩家在这里断开
+	r.roomMapMu.Lock()
+	r.disposeRoomLocked(room)
+	r.roomMapMu.Unlock()
+	return len(players), nil
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRoomManagerAdmin(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+
+	p1, p2 := newTestPlayer("p1", "USD"), newTestPlayer("p2", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	rm.OnJoin(p2, "app-97", nil)
+	room := creator.rooms[0]
+
+	rooms := rm.Rooms()
+	if len(rooms) != 1 || rooms[0].Id != "1" || rooms[0].PlayerNum != 2 || rooms[0].RoomType != "app-97" {
+		t.Fatalf("unexpected rooms %+v", rooms)
+	}
+	state, err := rm.PlayerState("app-p1")
+	if err != nil || state.RoomId != "1" || !state.Seated || state.SendQueue == nil {
+		t.Fatalf("unexpected state %+v %v", state, err)
+	}
+
+	// 踢人后座位释放，房间还有p2不会销毁
+	if err := rm.Kick("app-p1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if seated(rm, "app-p1") {
+		t.Fatal("kicked player should leave the room")
+	}
+	if _, err := rm.PlayerState("app-p1"); !errors.Is(err, ErrPlayerNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := rm.Kick("app-p1", nil); !errors.Is(err, ErrPlayerNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if room.disposed.Load() {
+		t.Fatal("room should not be disposed")
+	}
+
+	var noticed []string
+	kicked, err := rm.DisposeRoom("1", func(player types.PlayerImp) {
+		noticed = append(noticed, player.GetPlayerIdent())
+	})
+	if err != nil || kicked != 1 {
+		t.Fatalf("dispose %d %v", kicked, err)
+	}
+	if !room.disposed.Load() || seated(rm, "app-p2") || len(rm.Rooms()) != 0 {
+		t.Fatal("room should be disposed and released")
+	}
+	// 测试玩家没有连接，不发送通知
+	if len(noticed) != 0 {
+		t.Fatalf("unexpected notice %v", noticed)
+	}
+	if _, err := rm.DisposeRoom("1", nil); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	rm.OnJoin(newTestPlayer("p1", "USD"), "app-97", nil)
+
+	app := fiber.New()
+	rm.RegisterAdminRoutes(app.Group("/admin"), nil)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/admin/rooms", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("rooms %v %v", resp, err)
+	}
+	var rooms []*RoomInfo
+	if err := json.NewDecoder(resp.Body).Decode(&rooms); err != nil || len(rooms) != 1 {
+		t.Fatalf("decode rooms %v %v", rooms, err)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/admin/players/app-p2", nil))
+	if err != nil || resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("expected 404, got %v %v", resp, err)
+	}
+	resp, err = app.Test(httptest.NewRequest("POST", "/admin/rooms/"+rooms[0].Id+"/dispose", nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("dispose %v %v", resp, err)
+	}
+	if !creator.rooms[0].disposed.Load() {
+		t.Fatal("room should be disposed")
+	}
+}
@@ -0,0 +1,130 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+var ErrDraining = errors.New("server is draining")
+
+// 是否正在排空，排空时不接受新的玩家进入房间，已有座位的玩家仍可以重连
+func (r *RoomManager) IsDraining() bool {
+	return r.draining.Load()
+}
+
+// 设置排空超时后处理未结束对局的钩子
+func (r *RoomManager) SetUnfinishedRoundHook(hook types.UnfinishedRoundHook) {
+	r.roomMapMu.Lock()
+	defer r.roomMapMu.Unlock()
+	r.unfinishedRoundHook = hook
+}
+
+// 节点下线前排空: 停止接受新玩家，等待所有房间结束当前局(最多到ctx结束)，
+// 超时仍未结束的房间交给UnfinishedRoundHook，最后给在线玩家发送重连提示并断开，销毁所有房间。
+// 返回超时未结束的房间数
+func (r *RoomManager) Drain(ctx context.Context, hint func(player types.PlayerImp)) int {
+	// 在roomMapMu下设置排空，创建房间的地方持有同一把锁并再次检查，之后不会再有新房间
+	r.roomMapMu.Lock()
+	r.draining.Store(true)
+	hook := r.unfinishedRoundHook
+	r.roomMapMu.Unlock()
+	rooms := r.allRooms()
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		unfinished []types.RoomImp
+	)
+	for _, room := range rooms {
+		drainable, ok := room.(types.DrainableRoomImp)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := drainable.Drain(ctx); err != nil {
+				mu.Lock()
+				unfinished = append(unfinished, room)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, room := range unfinished {
+		r.log.Warnf("room %p did not finish its round before drain deadline", room)
+		if hook != nil {
+			hook(room)
+		}
+	}
+
+	// 发送重连提示并断开
+	r.players.Range(func(key, value interface{}) bool {
+		if player, ok := value.(types.PlayerImp); ok && player.IsConnect() {
+			if hint != nil && !types.IsSpectator(player) {
+				hint(player)
+			}
+			player.CloseConn()
+		}
+		return true
+	})
+
+	r.roomMapMu.Lock()
+	for room := range r.spectators {
+		delete(r.spectators, room)
+	}
+	r.roomMap = make(map[string][]types.RoomImp)
+	r.roomMapMu.Unlock()
+
+	r.playerRoomMapMu.Lock()
+	r.playerRoomMap = make(map[string]types.RoomImp)
+	r.playerRoomMapMu.Unlock()
+
+	for _, room := range rooms {
+		r.disposeRoom(room)
+	}
+	return len(unfinished)
+}
+
+// 所有的房间，包括没有登记在roomMap中的单人房间，以及刚创建还没写入playerRoomMap的房间
+func (r *RoomManager) allRooms() []types.RoomImp {
+	seen := make(map[types.RoomImp]struct{})
+	var rooms []types.RoomImp
+	add := func(room types.RoomImp) {
+		if room == nil {
+			return
+		}
+		if _, ok := seen[room]; !ok {
+			seen[room] = struct{}{}
+			rooms = append(rooms, room)
+		}
+	}
+
+	r.roomMapMu.RLock()
+	for _, list := range r.roomMap {
+		for _, room := range list {
+			add(room)
+		}
+	}
+	for room := range r.spectators {
+		add(room)
+	}
+	r.roomMapMu.RUnlock()
+
+	r.playerRoomMapMu.RLock()
+	for _, room := range r.playerRoomMap {
+		add(room)
+	}
+	r.playerRoomMapMu.RUnlock()
+
+	r.roomMetaMu.RLock()
+	for room := range r.roomMeta {
+		add(room)
+	}
+	r.roomMetaMu.RUnlock()
+	return rooms
+}
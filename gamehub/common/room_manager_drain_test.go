@@ -0,0 +1,111 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type drainRoom struct {
+	*testRoom
+	finish chan struct{}
+}
+
+func (r *drainRoom) Drain(ctx context.Context) error {
+	select {
+	case <-r.finish:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type drainCreator struct {
+	rooms []*drainRoom
+}
+
+func (c *drainCreator) CreateRoom(args interface{}) types.RoomImp {
+	room := &drainRoom{testRoom: &testRoom{id: len(c.rooms), players: map[string]bool{}}, finish: make(chan struct{})}
+	c.rooms = append(c.rooms, room)
+	return room
+}
+
+func TestDrain(t *testing.T) {
+	creator := &drainCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	var unfinished []types.RoomImp
+	rm.SetUnfinishedRoundHook(func(room types.RoomImp) { unfinished = append(unfinished, room) })
+
+	rm.OnJoin(newTestPlayer("p1", "USD"), "app-97", nil)
+	rm.OnJoin(newTestPlayer("p2", "USD"), "app-98", nil)
+	// 第一个房间按时结束，第二个超时
+	close(creator.rooms[0].finish)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if n := rm.Drain(ctx, nil); n != 1 {
+		t.Fatalf("expected 1 unfinished room, got %d", n)
+	}
+	if len(unfinished) != 1 || unfinished[0] != creator.rooms[1] {
+		t.Fatalf("unexpected unfinished rooms %v", unfinished)
+	}
+	if !creator.rooms[0].disposed.Load() || !creator.rooms[1].disposed.Load() {
+		t.Fatal("rooms should be disposed")
+	}
+	if err := rm.OnJoin(newTestPlayer("p3", "USD"), "app-97", nil); !errors.Is(err, ErrDraining) {
+		t.Fatalf("expected ErrDraining, got %v", err)
+	}
+	if seated(rm, "app-p1") {
+		t.Fatal("seats should be released")
+	}
+}
+
+// 排空开始时正在创建的房间也要被销毁，不能在快照之后加入
+func TestDrainDuringSwitch(t *testing.T) {
+	var entered, release chan struct{}
+	var room *drainRoom
+	creator := creatorFunc(func(args interface{}) types.RoomImp {
+		if entered != nil {
+			close(entered)
+			<-release
+		}
+		room = &drainRoom{testRoom: &testRoom{players: map[string]bool{}}, finish: make(chan struct{})}
+		close(room.finish)
+		return room
+	})
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	p1 := newTestPlayer("p1", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+
+	entered, release = make(chan struct{}), make(chan struct{})
+	switched := make(chan error, 1)
+	go func() { switched <- rm.SwitchRoom(p1, nil) }()
+	<-entered
+
+	drained := make(chan struct{})
+	go func() {
+		rm.Drain(context.Background(), nil)
+		close(drained)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	if err := <-switched; err != nil {
+		t.Fatal(err)
+	}
+	<-drained
+	if !room.disposed.Load() {
+		t.Fatal("room created while draining should be disposed")
+	}
+	if err := rm.SwitchRoom(p1, nil); !errors.Is(err, ErrDraining) {
+		t.Fatalf("expected ErrDraining, got %v", err)
+	}
+}
+
+type creatorFunc func(args interface{}) types.RoomImp
+
+func (f creatorFunc) CreateRoom(args interface{}) types.RoomImp { return f(args) }
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"context"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// 设置玩家客户端种子的存储，router处理玩家设置种子的指令，房间计算结果时读取，
+// 默认是内存版本，多节点部署时需要设置为Redis版本
+func (r *RoomManager) SetClientSeeds(store fairness.ClientSeedStore) {
+	r.clientSeedsMu.Lock()
+	defer r.clientSeedsMu.Unlock()
+	r.clientSeeds = store
+}
+
+func (r *RoomManager) ClientSeeds() fairness.ClientSeedStore {
+	r.clientSeedsMu.RLock()
+	defer r.clientSeedsMu.RUnlock()
+	return r.clientSeeds
+}
+
+// 按玩家顺序取出客户端种子，房间用来计算一局的结果(fairness.Round.Outcome)
+func (r *RoomManager) ClientSeedsOf(ctx context.Context, players []types.PlayerImp) ([]string, error) {
+	idents := make([]string, 0, len(players))
+	for _, player := range players {
+		idents = append(idents, player.GetPlayerIdent())
+	}
+	return fairness.ClientSeedsOf(ctx, r.ClientSeeds(), idents)
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// 玩家设置的客户端种子(router写入roomManager.ClientSeeds())会参与房间计算的结果
+func TestRoomManagerClientSeedsOutcome(t *testing.T) {
+	ctx := context.Background()
+	rm := NewRoomManager(types.GameBrand_Spribe, &testCreator{}, types.TableMatcherType_RTP, log.DefaultLogger)
+	p1, p2 := newTestPlayer("p1", "USD"), newTestPlayer("p2", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	rm.OnJoin(p2, "app-97", nil)
+	players := []types.PlayerImp{p1, p2}
+
+	// 每次用新的engine，同一个位置的ServerSeed相同，结果只取决于客户端种子
+	outcome := func(seed string) fairness.Outcome {
+		t.Helper()
+		if err := rm.ClientSeeds().Set(ctx, p1.GetPlayerIdent(), seed); err != nil {
+			t.Fatal(err)
+		}
+		engine, err := fairness.NewEngine("aviator", fairness.NewMemoryChainStore(), fairness.ChainConfig{
+			Secret: []byte("0123456789abcdef-test"),
+			Length: 10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		round, err := engine.NextRound(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seeds, err := rm.ClientSeedsOf(ctx, players)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := round.Outcome(seeds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fairness.VerifyRound(round.Reveal()); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	a, b := outcome("seed-a"), outcome("seed-b")
+	if a.ClientSeeds[0] != "seed-a" || b.ClientSeeds[0] != "seed-b" {
+		t.Fatalf("unexpected client seeds %v %v", a.ClientSeeds, b.ClientSeeds)
+	}
+	// 没有设置种子的玩家使用自动生成的种子，两局相同
+	if a.ClientSeeds[1] == "" || a.ClientSeeds[1] != b.ClientSeeds[1] {
+		t.Fatalf("generated seed should be kept: %v %v", a.ClientSeeds, b.ClientSeeds)
+	}
+	if a.Hash == b.Hash || a.Float == b.Float {
+		t.Fatal("changing the client seed should change the outcome")
+	}
+	if again := outcome("seed-a"); again.Hash != a.Hash {
+		t.Fatal("same seeds should give the same outcome")
+	}
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/const_val"
+	"github.com/card-engine/game_common/gamehub/event"
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// 设置断线后保留座位的时间，0表示一直保留直到房间自己调用ExitRoom
+func (r *RoomManager) SetReconnectGrace(grace time.Duration) {
+	r.playerRoomMapMu.Lock()
+	defer r.playerRoomMapMu.Unlock()
+	r.reconnectGrace = grace
+}
+
+// 断线后开始计时，玩家在重连前超时则释放座位
+func (r *RoomManager) startSeatTimer(player types.PlayerImp) {
+	playerIdent := player.GetPlayerIdent()
+
+	r.playerRoomMapMu.RLock()
+	grace := r.reconnectGrace
+	_, seated := r.playerRoomMap[playerIdent]
+	r.playerRoomMapMu.RUnlock()
+
+	// 房间在OnDisConnect中已经让玩家退出了，或者正在排空
+	if grace <= 0 || !seated || r.IsDraining() {
+		return
+	}
+
+	key := const_val.SeatExpireTimeWheelKeyPrefix + playerIdent
+	r.tw.RemoveTimer(key)
+	r.tw.AddTimer(grace, key, &event.SeatExpireEvent{PlayerIdent: playerIdent})
+}
+
+func (r *RoomManager) onSeatExpired(playerIdent string) {
+	r.playerRoomMapMu.RLock()
+	room, ok := r.playerRoomMap[playerIdent]
+	r.playerRoomMapMu.RUnlock()
+	if !ok || room == nil {
+		return
+	}
+
+	value, ok := r.players.Load(playerIdent)
+	if !ok {
+		return
+	}
+	player, ok := value.(types.PlayerImp)
+	// 计时器触发的同时玩家重连回来了
+	if !ok || player.IsConnect() {
+		return
+	}
+
+	r.log.Infof("seat of %s expired", playerIdent)
+	if expirable, ok := room.(types.SeatExpirableRoomImp); ok {
+		expirable.OnSeatExpired(player)
+	}
+
+	if player.GetRoom() == nil {
+		player.SetRoom(room)
+	}
+	r.ExitRoom(player, false)
+}
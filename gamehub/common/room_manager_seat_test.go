@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func TestSeatExpired(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	rm.SetReconnectGrace(time.Second)
+
+	p1, p2 := newTestPlayer("p1", "USD"), newTestPlayer("p2", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	rm.OnJoin(p2, "app-97", nil)
+	room := creator.rooms[0]
+	room.expired = make(chan string, 2)
+
+	// p2断线后重连，计时取消
+	rm.OnDisConnect(p2)
+	if err, ok := rm.TryReConnectGame(p2); err != nil || !ok {
+		t.Fatalf("reconnect failed %v %v", err, ok)
+	}
+
+	rm.OnDisConnect(p1)
+	select {
+	case ident := <-room.expired:
+		if ident != "app-p1" {
+			t.Fatalf("unexpected expired %s", ident)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("seat did not expire")
+	}
+
+	// 座位释放后不能再重连，p2仍在房间，房间不会销毁
+	deadline := time.Now().Add(time.Second)
+	for seated(rm, "app-p1") {
+		if time.Now().After(deadline) {
+			t.Fatal("expired seat should be released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !seated(rm, "app-p2") {
+		t.Fatal("p2 should keep the seat")
+	}
+	if room.disposed.Load() {
+		t.Fatal("room should not be disposed")
+	}
+	select {
+	case ident := <-room.expired:
+		t.Fatalf("unexpected expired %s", ident)
+	default:
+	}
+}
+
+// 默认不开启座位超时，断线后一直保留座位
+func TestSeatKeptByDefault(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	if rm.reconnectGrace != 0 {
+		t.Fatalf("reconnect grace should be off by default, got %v", rm.reconnectGrace)
+	}
+
+	p1 := newTestPlayer("p1", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	creator.rooms[0].expired = make(chan string, 1)
+	rm.OnDisConnect(p1)
+	if !seated(rm, "app-p1") || creator.rooms[0].disposed.Load() {
+		t.Fatal("disconnected player should keep the seat")
+	}
+	if err, ok := rm.TryReConnectGame(p1); err != nil || !ok {
+		t.Fatalf("reconnect failed %v %v", err, ok)
+	}
+}
+
+func seated(rm *RoomManager, playerIdent string) bool {
+	rm.playerRoomMapMu.RLock()
+	defer rm.playerRoomMapMu.RUnlock()
+	_, ok := rm.playerRoomMap[playerIdent]
+	return ok
+}
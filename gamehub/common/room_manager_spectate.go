@@ -0,0 +1,135 @@
+package common
+
+import (
+	"errors"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/qd2ss/sfs"
+)
+
+var ErrSpectateNotSupported = errors.New("room does not support spectators")
+
+// 观战玩家只能发送的指令: 心跳、查询状态和历史，下注、提现等游戏操作都会被拒绝
+var spectatorCommands = map[string]bool{
+	// inout的事件名或gameService的action
+	"gameService-latencyTest":         true,
+	"get-game-state":                  true,
+	"gameService-get-my-bets-history": true,
+	"chat-get-history":                true,
+	// spribe扩展消息的c
+	"PING_REQUEST":           true,
+	"currentBetsInfoHandler": true,
+	"getMyBetsHistory":       true,
+	"getChatHistory":         true,
+}
+
+// 观战玩家是否可以发送这个指令
+func IsSpectatorCommand(cmd string) bool {
+	return spectatorCommands[cmd]
+}
+
+func isSpectatorMessage(msg interface{}) bool {
+	switch m := msg.(type) {
+	case sfs.SFSObject:
+		cmd, _ := m["c"].(string)
+		return IsSpectatorCommand(cmd)
+	case *types.InoutMsgData:
+		return IsSpectatorCommand(m.Action)
+	}
+	return false
+}
+
+// 观战玩家进入房间，优先进入已有的房间，没有则创建一个。
+// 观战玩家不计入房间人数，但房间里只剩观战玩家时不会销毁，最后一个观战玩家离开时才销毁。
+func (r *RoomManager) Spectate(player types.PlayerImp, roomType string, roomArgs interface{}) error {
+	if r.IsDraining() {
+		return ErrDraining
+	}
+	r.roomMapMu.Lock()
+	defer r.roomMapMu.Unlock()
+	if r.IsDraining() {
+		return ErrDraining
+	}
+
+	var room types.SpectatableRoomImp
+	for _, one := range r.roomMap[roomType] {
+		if spectatable, ok := one.(types.SpectatableRoomImp); ok {
+			room = spectatable
+			break
+		}
+	}
+
+	created := false
+	if room == nil {
+		one := r.createRoom(roomArgs)
+		spectatable, ok := one.(types.SpectatableRoomImp)
+		if !ok {
+			r.disposeRoom(one)
+			return ErrSpectateNotSupported
+		}
+		room = spectatable
+		created = true
+	}
+
+	if err := room.OnSpectate(player); err != nil {
+		r.log.Errorf("spectate room %s failed, err: %v", roomType, err)
+		if created {
+			r.disposeRoom(room)
+		}
+		return err
+	}
+	if created && roomType != "" {
+		r.roomMap[roomType] = append(r.roomMap[roomType], room)
+	}
+
+	player.SetRoom(room)
+	player.SetRoomManager(r)
+
+	if r.spectators[room] == nil {
+		r.spectators[room] = make(map[string]types.PlayerImp)
+	}
+	r.spectators[room][player.GetPlayerIdent()] = player
+	// 存入players以便接收inout心跳
+	r.players.Store(player.GetPlayerIdent(), player)
+	return nil
+}
+
+// 房间当前的观战人数
+func (r *RoomManager) SpectatorNum(room types.RoomImp) int {
+	r.roomMapMu.RLock()
+	defer r.roomMapMu.RUnlock()
+	return len(r.spectators[room])
+}
+
+func (r *RoomManager) leaveSpectate(player types.PlayerImp, isDisconnect bool) {
+	room := player.GetRoom()
+	player.SetRoom(nil)
+	player.SetRoomManager(nil)
+	r.players.Delete(player.GetPlayerIdent())
+
+	if isDisconnect {
+		player.CloseConn()
+	}
+	if room == nil {
+		return
+	}
+
+	r.roomMapMu.Lock()
+	defer r.roomMapMu.Unlock()
+
+	watchers, ok := r.spectators[room]
+	if !ok {
+		return
+	}
+	if _, ok := watchers[player.GetPlayerIdent()]; !ok {
+		return
+	}
+	delete(watchers, player.GetPlayerIdent())
+	if spectatable, ok := room.(types.SpectatableRoomImp); ok {
+		spectatable.OnSpectatorLeave(player)
+	}
+
+	if len(watchers) == 0 && room.GetPlayerNum() <= 0 && !r.IsDraining() {
+		r.disposeRoomLocked(room)
+	}
+}
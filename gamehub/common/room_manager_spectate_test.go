@@ -0,0 +1,156 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/qd2ss/sfs"
+)
+
+type spectateRoom struct {
+	*testRoom
+	watchers map[string]bool
+	left     []string
+	messages []interface{}
+}
+
+func (r *spectateRoom) OnMessage(p types.PlayerImp, data interface{}) error {
+	r.messages = append(r.messages, data)
+	return nil
+}
+
+func (r *spectateRoom) OnSpectate(p types.PlayerImp) error {
+	r.watchers[p.GetPlayerIdent()] = true
+	return nil
+}
+
+func (r *spectateRoom) OnSpectatorLeave(p types.PlayerImp) {
+	delete(r.watchers, p.GetPlayerIdent())
+	r.left = append(r.left, p.GetPlayerIdent())
+}
+
+type spectateCreator struct {
+	rooms []*spectateRoom
+}
+
+func (c *spectateCreator) CreateRoom(args interface{}) types.RoomImp {
+	room := &spectateRoom{testRoom: &testRoom{id: len(c.rooms), args: args, players: map[string]bool{}}, watchers: map[string]bool{}}
+	c.rooms = append(c.rooms, room)
+	return room
+}
+
+type testWatcher struct {
+	*testPlayer
+}
+
+func (p *testWatcher) IsSpectator() bool { return true }
+
+func TestSpectate(t *testing.T) {
+	creator := &spectateCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+
+	p1 := newTestPlayer("p1", "USD")
+	w1 := &testWatcher{newTestPlayer("w1", "USD")}
+	rm.OnJoin(p1, "app-97", nil)
+	if err := rm.Spectate(w1, "app-97", nil); err != nil {
+		t.Fatal(err)
+	}
+	room := creator.rooms[0]
+	if len(creator.rooms) != 1 || w1.GetRoom() != types.RoomImp(room) || !room.watchers["app-w1"] {
+		t.Fatal("spectator should join the existing room")
+	}
+	if rm.SpectatorNum(room) != 1 || room.GetPlayerNum() != 1 {
+		t.Fatalf("spectators %d, players %d", rm.SpectatorNum(room), room.GetPlayerNum())
+	}
+
+	// 最后一个玩家离开，还有观战玩家，房间保留
+	room.OnDisConnect(p1)
+	rm.ExitRoom(p1, true)
+	if room.disposed.Load() || len(rm.Rooms()) != 1 || w1.GetRoom() == nil {
+		t.Fatal("room with spectators should not be disposed")
+	}
+
+	// 最后一个观战玩家离开后销毁
+	rm.ExitRoom(w1, true)
+	if len(room.left) != 1 || room.left[0] != "app-w1" || w1.GetRoom() != nil {
+		t.Fatalf("spectator should leave the room, left %v", room.left)
+	}
+	if !room.disposed.Load() || len(rm.Rooms()) != 0 || rm.SpectatorNum(room) != 0 {
+		t.Fatal("room should be disposed after the last spectator leaves")
+	}
+	if _, ok := rm.players.Load("app-w1"); ok {
+		t.Fatal("spectator should be removed from players")
+	}
+}
+
+func TestSpectateCreatesRoom(t *testing.T) {
+	creator := &spectateCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+
+	w1, w2 := &testWatcher{newTestPlayer("w1", "USD")}, &testWatcher{newTestPlayer("w2", "USD")}
+	rm.Spectate(w1, "app-97", nil)
+	rm.Spectate(w2, "app-97", nil)
+	if len(creator.rooms) != 1 || rm.SpectatorNum(creator.rooms[0]) != 2 {
+		t.Fatal("spectators should share the created room")
+	}
+	room := creator.rooms[0]
+
+	rm.ExitRoom(w1, true)
+	if room.disposed.Load() {
+		t.Fatal("room should wait for the last spectator")
+	}
+	// 玩家进入观战中的房间后，观战玩家离开不会销毁
+	p1 := newTestPlayer("p1", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	rm.ExitRoom(w2, true)
+	if room.disposed.Load() || len(rm.Rooms()) != 1 {
+		t.Fatal("room with players should not be disposed")
+	}
+
+	// 房间不支持观战时不留下房间
+	rm2 := NewRoomManager(types.GameBrand_Spribe, &testCreator{}, types.TableMatcherType_RTP, log.DefaultLogger)
+	if err := rm2.Spectate(w1, "app-97", nil); !errors.Is(err, ErrSpectateNotSupported) {
+		t.Fatalf("expected ErrSpectateNotSupported, got %v", err)
+	}
+	if len(rm2.Rooms()) != 0 {
+		t.Fatal("unsupported room should be released")
+	}
+}
+
+// 观战玩家只有查询状态和历史的指令会交给房间
+func TestSpectatorGameActionsRejected(t *testing.T) {
+	creator := &spectateCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	w1 := &testWatcher{newTestPlayer("w1", "USD")}
+	rm.Spectate(w1, "app-97", nil)
+	room := creator.rooms[0]
+
+	for _, c := range []string{"betHandler", "cashOutHandler", "currentBetsInfoHandler", "getMyBetsHistory"} {
+		if err := rm.OnMessage(w1, sfs.SFSObject{"c": c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(room.messages) != 2 || room.messages[0].(sfs.SFSObject)["c"] != "currentBetsInfoHandler" {
+		t.Fatalf("only queries should reach the room: %v", room.messages)
+	}
+
+	// 玩家的消息不受影响
+	p1 := newTestPlayer("p1", "USD")
+	rm.OnJoin(p1, "app-97", nil)
+	rm.OnMessage(p1, sfs.SFSObject{"c": "betHandler"})
+	if len(room.messages) != 3 {
+		t.Fatal("player bet should reach the room")
+	}
+
+	inout := NewRoomManager(types.GameBrand_Inout, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	w2 := &testWatcher{newTestPlayer("w2", "USD")}
+	inout.Spectate(w2, "app-97", nil)
+	room = creator.rooms[1]
+	inout.OnMessage(w2, &types.InoutMsgData{Action: "bet"})
+	inout.OnMessage(w2, &types.InoutMsgData{Action: "get-game-state"})
+	if len(room.messages) != 1 || room.messages[0].(*types.InoutMsgData).Action != "get-game-state" {
+		t.Fatalf("only queries should reach the room: %v", room.messages)
+	}
+}
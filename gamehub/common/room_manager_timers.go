@@ -0,0 +1,39 @@
+package common
+
+import (
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// 设置房间定时器使用的时钟，测试时使用FakeClock，需要在创建房间之前设置
+func (r *RoomManager) SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	r.clock = clock
+}
+
+// 房间的定时器，只有RoomManager创建的定时房间才有，其它房间或者房间销毁后返回nil
+func (r *RoomManager) Timers(room types.RoomImp) *RoomTimers {
+	r.roomTimersMu.Lock()
+	defer r.roomTimersMu.Unlock()
+	return r.roomTimers[room]
+}
+
+// 创建房间时登记定时器，回调投递到房间执行器，和房间的其它事件按顺序执行
+func (r *RoomManager) newTimers(room *ActorRoom) *RoomTimers {
+	r.roomTimersMu.Lock()
+	defer r.roomTimersMu.Unlock()
+	timers := NewRoomTimers(r.clock, room.Post)
+	r.roomTimers[room] = timers
+	return timers
+}
+
+func (r *RoomManager) stopTimers(room types.RoomImp) {
+	r.roomTimersMu.Lock()
+	timers, ok := r.roomTimers[room]
+	delete(r.roomTimers, room)
+	r.roomTimersMu.Unlock()
+	if ok {
+		timers.Stop()
+	}
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// 一个房间的定时器，key在房间内唯一，同一个key再次设置会替换旧的定时器。
+// 到期后通过post投递，ActorRoom的房间在房间协程中执行，其它房间在定时器协程中执行。
+// 已经投递但还没执行的定时器被取消或者替换后不会再执行。
+type RoomTimers struct {
+	clock Clock
+	post  func(fn func()) error
+
+	mu      sync.Mutex
+	seq     uint64
+	entries map[string]*roomTimer
+	stopped bool
+}
+
+type roomTimer struct {
+	id    uint64
+	timer ClockTimer
+}
+
+func NewRoomTimers(clock Clock, post func(fn func()) error) *RoomTimers {
+	if clock == nil {
+		clock = SystemClock
+	}
+	if post == nil {
+		post = func(fn func()) error {
+			fn()
+			return nil
+		}
+	}
+	return &RoomTimers{
+		clock:   clock,
+		post:    post,
+		entries: make(map[string]*roomTimer),
+	}
+}
+
+func (t *RoomTimers) Now() time.Time {
+	return t.clock.Now()
+}
+
+// d之后执行一次fn
+func (t *RoomTimers) Schedule(key string, d time.Duration, fn func()) {
+	t.add(key, d, fn, false)
+}
+
+// 每隔interval执行一次fn，直到取消
+func (t *RoomTimers) Repeat(key string, interval time.Duration, fn func()) {
+	t.add(key, interval, fn, true)
+}
+
+// 取消定时器，返回是否存在
+func (t *RoomTimers) Cancel(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.entries[key]
+	if !ok {
+		return false
+	}
+	entry.timer.Stop()
+	delete(t.entries, key)
+	return true
+}
+
+// 定时器是否还在等待
+func (t *RoomTimers) Has(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.entries[key]
+	return ok
+}
+
+// 房间销毁时取消所有定时器，之后设置的定时器会被忽略
+func (t *RoomTimers) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	for key, entry := range t.entries {
+		entry.timer.Stop()
+		delete(t.entries, key)
+	}
+}
+
+func (t *RoomTimers) add(key string, d time.Duration, fn func(), repeat bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	if old, ok := t.entries[key]; ok {
+		old.timer.Stop()
+	}
+	t.seq++
+	entry := &roomTimer{id: t.seq}
+	entry.timer = t.clock.AfterFunc(d, t.fire(key, entry, d, fn, repeat))
+	t.entries[key] = entry
+}
+
+func (t *RoomTimers) fire(key string, entry *roomTimer, d time.Duration, fn func(), repeat bool) func() {
+	var onFire func()
+	onFire = func() {
+		t.mu.Lock()
+		if t.entries[key] != entry {
+			t.mu.Unlock()
+			return
+		}
+		// 重复的定时器按固定间隔继续，不受执行耗时影响
+		if repeat {
+			entry.timer = t.clock.AfterFunc(d, onFire)
+		}
+		t.mu.Unlock()
+
+		t.post(func() {
+			t.mu.Lock()
+			current := t.entries[key] == entry
+			if current && !repeat {
+				delete(t.entries, key)
+			}
+			t.mu.Unlock()
+			if current {
+				fn()
+			}
+		})
+	}
+	return onFire
+}
+
+var _ types.RoomTimersImp = (*RoomTimers)(nil)
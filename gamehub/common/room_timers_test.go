@@ -0,0 +1,163 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func TestRoomTimers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	timers := NewRoomTimers(clock, nil)
+
+	var fired []string
+	timers.Schedule("bet", 5*time.Second, func() { fired = append(fired, "bet") })
+	timers.Schedule("fly", 3*time.Second, func() { fired = append(fired, "fly") })
+	// 同一个key替换旧的定时器
+	timers.Schedule("fly", 8*time.Second, func() { fired = append(fired, "fly2") })
+	timers.Repeat("tick", 2*time.Second, func() { fired = append(fired, "tick") })
+	timers.Schedule("cancel", time.Second, func() { fired = append(fired, "cancel") })
+	if !timers.Cancel("cancel") || timers.Cancel("cancel") {
+		t.Fatal("cancel should report the pending timer once")
+	}
+
+	clock.Advance(6 * time.Second)
+	want := []string{"tick", "tick", "bet", "tick"}
+	if len(fired) != len(want) {
+		t.Fatalf("fired %v, want %v", fired, want)
+	}
+	for i := range want {
+		if fired[i] != want[i] {
+			t.Fatalf("fired %v, want %v", fired, want)
+		}
+	}
+	if timers.Has("bet") || !timers.Has("tick") || !timers.Has("fly") {
+		t.Fatal("only one-shot timers are removed after firing")
+	}
+
+	timers.Stop()
+	timers.Schedule("late", time.Second, func() { fired = append(fired, "late") })
+	clock.Advance(time.Minute)
+	if len(fired) != len(want) || clock.Pending() != 0 {
+		t.Fatalf("timers should not fire after stop: %v", fired)
+	}
+}
+
+type timedRoom struct {
+	*testRoom
+	timers types.RoomTimersImp
+}
+
+func (r *timedRoom) SetTimers(timers types.RoomTimersImp) {
+	r.timers = timers
+}
+
+type timedCreator struct {
+	rooms []*timedRoom
+}
+
+func (c *timedCreator) CreateRoom(args interface{}) types.RoomImp {
+	room := &timedRoom{testRoom: &testRoom{players: map[string]bool{}}}
+	c.rooms = append(c.rooms, room)
+	return room
+}
+
+func TestRoomManagerTimers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	creator := &timedCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_RTP, log.DefaultLogger)
+	rm.SetClock(clock)
+
+	p := newTestPlayer("p1", "USD")
+	if err := rm.OnJoin(p, "app-97", nil); err != nil {
+		t.Fatal(err)
+	}
+	room := creator.rooms[0]
+	if room.timers == nil {
+		t.Fatal("timers should be injected")
+	}
+	// 没有使用执行器的定时房间被包装，定时器回调和玩家消息在同一个协程中执行
+	actor, ok := p.GetRoom().(*ActorRoom)
+	if !ok || actor.Room() != room {
+		t.Fatalf("timed room should be wrapped, got %T", p.GetRoom())
+	}
+	if rm.Timers(actor) == nil {
+		t.Fatal("timers should be registered for the wrapped room")
+	}
+
+	ticks := make(chan struct{}, 3)
+	room.timers.Repeat("phase", time.Second, func() { ticks <- struct{}{} })
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		select {
+		case <-ticks:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timer not delivered")
+		}
+	}
+
+	// 最后一个玩家退出，房间销毁，定时器随之取消
+	actor.OnDisConnect(p)
+	rm.ExitRoom(p, false)
+	actor.Wait()
+	if !room.disposed.Load() {
+		t.Fatal("room should be disposed")
+	}
+	if rm.Timers(actor) != nil {
+		t.Fatal("timers should be removed on dispose")
+	}
+	clock.Advance(3 * time.Second)
+	if len(ticks) != 0 || clock.Pending() != 0 {
+		t.Fatal("timers should be cancelled on dispose")
+	}
+}
+
+// 不是RoomManager创建的房间没有定时器，也不会登记
+func TestTimersUnknownRoom(t *testing.T) {
+	rm := NewRoomManager(types.GameBrand_Spribe, &testCreator{}, types.TableMatcherType_RTP, log.DefaultLogger)
+	room := &testRoom{players: map[string]bool{}}
+	if rm.Timers(room) != nil {
+		t.Fatal("unknown room should have no timers")
+	}
+	rm.roomTimersMu.Lock()
+	defer rm.roomTimersMu.Unlock()
+	if len(rm.roomTimers) != 0 {
+		t.Fatal("lookup should not register timers")
+	}
+}
+
+func TestActorRoomTimers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	inner := &counterRoom{}
+	a := NewActorRoom(inner, ActorOptions{})
+	timers := NewRoomTimers(clock, a.Post)
+
+	done := make(chan struct{})
+	timers.Schedule("settle", time.Second, func() {
+		// 在房间协程中执行，可以直接修改房间状态
+		inner.messages++
+		close(done)
+	})
+	clock.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer not delivered")
+	}
+
+	// 已经投递但还没执行的定时器被取消后不再执行
+	block := make(chan struct{})
+	a.Post(func() { <-block })
+	timers.Schedule("settle", time.Second, func() { inner.messages++ })
+	clock.Advance(time.Second)
+	timers.Cancel("settle")
+	close(block)
+
+	a.OnDispose()
+	a.Wait()
+	if inner.messages != 1 {
+		t.Fatalf("processed %d", inner.messages)
+	}
+}
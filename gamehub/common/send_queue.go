@@ -0,0 +1,252 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	DefaultSendQueueSize    = 256
+	DefaultSendWriteTimeout = 10 * time.Second
+	DefaultSendFlushTimeout = 2 * time.Second
+)
+
+var (
+	ErrSlowConsumer = errors.New("send queue overflow, slow consumer disconnected")
+	ErrConnClosed   = errors.New("connection closed")
+)
+
+// 发送队列满了之后的处理方式
+type OverflowPolicy int
+
+const (
+	OverflowDropOldest OverflowPolicy = iota // 丢弃最早的消息，连接保持
+	OverflowDisconnect                       // 断开跟不上的客户端
+)
+
+type SendQueueOptions struct {
+	Size         int            // 每个连接排队的消息上限
+	Overflow     OverflowPolicy // 队列满了之后的处理方式
+	WriteTimeout time.Duration  // 单条消息的写超时，超时后断开连接
+	FlushTimeout time.Duration  // CloseConn时等待队列写完的最长时间
+}
+
+func (o *SendQueueOptions) normalize() {
+	if o.Size <= 0 {
+		o.Size = DefaultSendQueueSize
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = DefaultSendWriteTimeout
+	}
+	if o.FlushTimeout <= 0 {
+		o.FlushTimeout = DefaultSendFlushTimeout
+	}
+}
+
+// 单个连接的发送队列状态
+type SendQueueStats struct {
+	Depth   int   // 排队中的消息数
+	Written int64 // 已经写出的消息数
+	Dropped int64 // 因为队列满了丢弃的消息数
+}
+
+// 所有连接的发送队列汇总
+type SendQueueMetrics struct {
+	Depth         int64 // 所有连接排队中的消息数
+	Dropped       int64
+	SlowConsumers int64 // 因为队列满了或者写超时被断开的连接数
+}
+
+var sendQueueMetrics struct {
+	depth         atomic.Int64
+	dropped       atomic.Int64
+	slowConsumers atomic.Int64
+}
+
+func GetSendQueueMetrics() SendQueueMetrics {
+	return SendQueueMetrics{
+		Depth:         sendQueueMetrics.depth.Load(),
+		Dropped:       sendQueueMetrics.dropped.Load(),
+		SlowConsumers: sendQueueMetrics.slowConsumers.Load(),
+	}
+}
+
+// *websocket.Conn
+type frameWriter interface {
+	WriteMessage(messageType int, data []byte) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+type outFrame struct {
+	messageType int
+	data        []byte
+}
+
+// 一个连接的发送队列，由一个写协程按顺序写出，Player和观战玩家共用
+type SendQueue struct {
+	conn frameWriter
+	opts SendQueueOptions
+	log  *log.Helper
+
+	mu      sync.Mutex
+	frames  []outFrame
+	closing bool  // 不再接收新消息，写完已经排队的消息后退出
+	err     error // 写失败或者被断开之后的错误
+
+	written atomic.Int64
+	dropped atomic.Int64
+
+	notify chan struct{}
+	done   chan struct{}
+}
+
+// 创建发送队列，logger为nil时使用默认的logger
+func NewSendQueue(conn *websocket.Conn, opts SendQueueOptions, logger *log.Helper) *SendQueue {
+	return newSendQueue(conn, opts, logger)
+}
+
+func newSendQueue(conn frameWriter, opts SendQueueOptions, logger *log.Helper) *SendQueue {
+	opts.normalize()
+	if logger == nil {
+		logger = log.NewHelper(log.GetLogger())
+	}
+	q := &SendQueue{
+		conn:   conn,
+		opts:   opts,
+		log:    logger,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go q.writeLoop()
+	return q
+}
+
+// 放入队列后立即返回，写失败或者被断开之后返回对应的错误
+func (q *SendQueue) Push(messageType int, data []byte) error {
+	q.mu.Lock()
+	if q.err != nil {
+		err := q.err
+		q.mu.Unlock()
+		return err
+	}
+	if q.closing {
+		q.mu.Unlock()
+		return ErrConnClosed
+	}
+	if len(q.frames) >= q.opts.Size {
+		if q.opts.Overflow == OverflowDisconnect {
+			q.failLocked(ErrSlowConsumer)
+			q.mu.Unlock()
+			q.log.Warnf("send queue overflow, disconnect slow consumer")
+			return ErrSlowConsumer
+		}
+		q.frames[0] = outFrame{}
+		q.frames = q.frames[1:]
+		q.dropped.Add(1)
+		sendQueueMetrics.dropped.Add(1)
+		sendQueueMetrics.depth.Add(-1)
+	}
+	q.frames = append(q.frames, outFrame{messageType: messageType, data: data})
+	sendQueueMetrics.depth.Add(1)
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *SendQueue) writeLoop() {
+	defer close(q.done)
+	for {
+		q.mu.Lock()
+		if q.err != nil || (len(q.frames) == 0 && q.closing) {
+			q.mu.Unlock()
+			return
+		}
+		if len(q.frames) == 0 {
+			q.mu.Unlock()
+			<-q.notify
+			continue
+		}
+		frame := q.frames[0]
+		q.frames[0] = outFrame{}
+		q.frames = q.frames[1:]
+		sendQueueMetrics.depth.Add(-1)
+		q.mu.Unlock()
+
+		q.conn.SetWriteDeadline(time.Now().Add(q.opts.WriteTimeout))
+		if err := q.conn.WriteMessage(frame.messageType, frame.data); err != nil {
+			q.mu.Lock()
+			if q.err == nil && !q.closing {
+				// 写超时也算跟不上的客户端
+				var timeout interface{ Timeout() bool }
+				if errors.As(err, &timeout) && timeout.Timeout() {
+					sendQueueMetrics.slowConsumers.Add(1)
+				}
+			}
+			q.failLocked(err)
+			q.mu.Unlock()
+			return
+		}
+		q.written.Add(1)
+	}
+}
+
+// 丢弃排队中的消息并关闭连接，连接的读协程随后会收到错误
+func (q *SendQueue) failLocked(err error) {
+	if q.err != nil {
+		return
+	}
+	if errors.Is(err, ErrSlowConsumer) {
+		sendQueueMetrics.slowConsumers.Add(1)
+	}
+	q.err = err
+	sendQueueMetrics.depth.Add(-int64(len(q.frames)))
+	q.frames = nil
+	q.conn.Close()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 关闭队列，flush为true时先等待已经排队的消息写完(最长FlushTimeout)。
+// 返回时写协程已经退出，连接可以安全释放
+func (q *SendQueue) Close(flush bool) {
+	q.mu.Lock()
+	q.closing = true
+	if !flush {
+		q.failLocked(ErrConnClosed)
+	}
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	if flush {
+		select {
+		case <-q.done:
+		case <-time.After(q.opts.FlushTimeout):
+			q.mu.Lock()
+			q.failLocked(ErrConnClosed)
+			q.mu.Unlock()
+		}
+	}
+	<-q.done
+}
+
+func (q *SendQueue) Stats() SendQueueStats {
+	q.mu.Lock()
+	depth := len(q.frames)
+	q.mu.Unlock()
+	return SendQueueStats{Depth: depth, Written: q.written.Load(), Dropped: q.dropped.Load()}
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeConn struct {
+	mu      sync.Mutex
+	written []string
+	block   chan struct{} // 不为空时写操作等待，模拟慢客户端
+	closed  bool
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	if c.block != nil {
+		<-c.block
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errors.New("use of closed connection")
+	}
+	c.written = append(c.written, string(data))
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *fakeConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) result() ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.written...), c.closed
+}
+
+func TestSendQueueDropOldest(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	q := newSendQueue(conn, SendQueueOptions{Size: 2}, nil)
+
+	// 第一条被写协程取走并阻塞，之后队列里最多保留两条
+	q.Push(1, []byte("a"))
+	for q.Stats().Depth != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, msg := range []string{"b", "c", "d"} {
+		if err := q.Push(1, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := q.Stats(); stats.Depth != 2 || stats.Dropped != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	close(conn.block)
+	q.Close(true)
+	written, _ := conn.result()
+	if len(written) != 3 || written[0] != "a" || written[1] != "c" || written[2] != "d" {
+		t.Fatalf("unexpected written %v", written)
+	}
+	if err := q.Push(1, []byte("e")); !errors.Is(err, ErrConnClosed) {
+		t.Fatalf("push after close: %v", err)
+	}
+}
+
+func TestSendQueueDisconnectSlowConsumer(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	q := newSendQueue(conn, SendQueueOptions{Size: 1, Overflow: OverflowDisconnect}, nil)
+
+	q.Push(1, []byte("a"))
+	for q.Stats().Depth != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	q.Push(1, []byte("b"))
+	before := GetSendQueueMetrics().SlowConsumers
+	if err := q.Push(1, []byte("c")); !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("expected slow consumer, got %v", err)
+	}
+	if _, closed := conn.result(); !closed {
+		t.Fatal("slow consumer should be disconnected")
+	}
+	if GetSendQueueMetrics().SlowConsumers != before+1 {
+		t.Fatal("slow consumer should be counted")
+	}
+
+	close(conn.block)
+	q.Close(true)
+	if written, _ := conn.result(); len(written) != 0 {
+		t.Fatalf("nothing should be written after disconnect: %v", written)
+	}
+}
+
+func TestSendQueueFlushTimeout(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	q := newSendQueue(conn, SendQueueOptions{FlushTimeout: 50 * time.Millisecond}, nil)
+	q.Push(1, []byte("a"))
+	q.Push(1, []byte("b"))
+
+	// 写一直阻塞，超时后关闭连接，写协程随之退出
+	go func() {
+		for {
+			if _, closed := conn.result(); closed {
+				close(conn.block)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	start := time.Now()
+	q.Close(true)
+	if time.Since(start) > time.Second {
+		t.Fatal("flush should give up after FlushTimeout")
+	}
+}
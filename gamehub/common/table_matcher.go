@@ -0,0 +1,123 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+var ErrNoTableMatcher = errors.New("table matcher not set")
+
+// 设置配桌算法，需要在玩家登陆之前调用
+func (r *RoomManager) SetTableMatcher(matcher types.TableMatcher) {
+	r.roomMapMu.Lock()
+	defer r.roomMapMu.Unlock()
+	r.tableMatcher = matcher
+}
+
+// 使用TableMatcher为玩家配桌
+func (r *RoomManager) OnMatch(player types.PlayerImp) error {
+	r.roomMapMu.RLock()
+	matcher := r.tableMatcher
+	r.roomMapMu.RUnlock()
+	if matcher == nil {
+		return ErrNoTableMatcher
+	}
+	roomType, roomArgs := matcher.Group(player)
+	return r.OnJoin(player, roomType, roomArgs)
+}
+
+// 玩家所在的房间分组，没有设置TableMatcher时按商户和rtp分组
+func (r *RoomManager) group(player types.PlayerImp) (string, interface{}) {
+	r.roomMapMu.RLock()
+	matcher := r.tableMatcher
+	r.roomMapMu.RUnlock()
+	if matcher != nil {
+		return matcher.Group(player)
+	}
+	return RtpGroup(player)
+}
+
+// 按商户和rtp分组，与TableMatcherType_RTP相同
+func RtpGroup(player types.PlayerImp) (string, interface{}) {
+	info := player.GetPlayerInfo()
+	return fmt.Sprintf("%v-%v", info.AppID, player.GetRtpStr()), &types.RtpRoomArgs{
+		Appid:    info.AppID,
+		Rtp:      player.GetRtpStr(),
+		Currency: info.Currency,
+	}
+}
+
+// 优先进入人多的房间，房间坐满后再开新房间
+type FillFirstMatcher struct{}
+
+func (FillFirstMatcher) Group(player types.PlayerImp) (string, interface{}) {
+	return RtpGroup(player)
+}
+
+func (FillFirstMatcher) Candidates(player types.PlayerImp, roomArgs interface{}, rooms []types.RoomImp) []types.RoomImp {
+	return sortByPlayerNum(rooms, true)
+}
+
+// 优先进入人少的房间，让各个房间的人数尽量平均
+type LeastLoadedMatcher struct{}
+
+func (LeastLoadedMatcher) Group(player types.PlayerImp) (string, interface{}) {
+	return RtpGroup(player)
+}
+
+func (LeastLoadedMatcher) Candidates(player types.PlayerImp, roomArgs interface{}, rooms []types.RoomImp) []types.RoomImp {
+	return sortByPlayerNum(rooms, false)
+}
+
+// 限制每个房间的人数，满员的房间不再匹配
+type CapacityMatcher struct {
+	types.TableMatcher
+	Capacity int32
+}
+
+func NewCapacityMatcher(matcher types.TableMatcher, capacity int32) *CapacityMatcher {
+	return &CapacityMatcher{TableMatcher: matcher, Capacity: capacity}
+}
+
+func (m *CapacityMatcher) Candidates(player types.PlayerImp, roomArgs interface{}, rooms []types.RoomImp) []types.RoomImp {
+	candidates := m.TableMatcher.Candidates(player, roomArgs, rooms)
+	kept := make([]types.RoomImp, 0, len(candidates))
+	for _, room := range candidates {
+		if room.GetPlayerNum() < m.Capacity {
+			kept = append(kept, room)
+		}
+	}
+	return kept
+}
+
+// 不同币种的玩家分到不同的房间
+type CurrencyMatcher struct {
+	types.TableMatcher
+}
+
+func NewCurrencyMatcher(matcher types.TableMatcher) *CurrencyMatcher {
+	return &CurrencyMatcher{TableMatcher: matcher}
+}
+
+func (m *CurrencyMatcher) Group(player types.PlayerImp) (string, interface{}) {
+	roomType, roomArgs := m.TableMatcher.Group(player)
+	return fmt.Sprintf("%v-%v", roomType, player.GetCurrency()), roomArgs
+}
+
+func sortByPlayerNum(rooms []types.RoomImp, desc bool) []types.RoomImp {
+	nums := make(map[types.RoomImp]int32, len(rooms))
+	for _, room := range rooms {
+		nums[room] = room.GetPlayerNum()
+	}
+	sorted := append([]types.RoomImp(nil), rooms...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if desc {
+			return nums[sorted[i]] > nums[sorted[j]]
+		}
+		return nums[sorted[i]] < nums[sorted[j]]
+	})
+	return sorted
+}
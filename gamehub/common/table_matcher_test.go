@@ -0,0 +1,153 @@
+package common
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/player"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type testPlayer struct {
+	*Player
+}
+
+func (p *testPlayer) GetRtpStr() string { return "97" }
+
+func newTestPlayer(id, currency string) *testPlayer {
+	return &testPlayer{NewPlayer(types.GameBrand_Spribe, nil, &player.PlayerInfo{AppID: "app", PlayerID: id, Currency: currency}, nil, nil)}
+}
+
+type testRoom struct {
+	id      int
+	args    interface{}
+	players map[string]bool
+	full    bool // 拒绝玩家进入
+
+	expired  chan string
+	disposed atomic.Bool
+}
+
+func (r *testRoom) GetPlayerNum() int32 { return int32(len(r.players)) }
+func (r *testRoom) OnJoin(p types.PlayerImp) error {
+	if r.full {
+		return errors.New("full")
+	}
+	r.players[p.GetPlayerIdent()] = true
+	return nil
+}
+func (r *testRoom) OnSwitch(p types.PlayerImp, args interface{}) error { return r.OnJoin(p) }
+func (r *testRoom) OnReConnect(p types.PlayerImp) error                { return r.OnJoin(p) }
+func (r *testRoom) OnDisConnect(p types.PlayerImp) error {
+	delete(r.players, p.GetPlayerIdent())
+	return nil
+}
+func (r *testRoom) OnMessage(p types.PlayerImp, data interface{}) error { return nil }
+func (r *testRoom) OnDispose()                                          { r.disposed.Store(true) }
+func (r *testRoom) OnSeatExpired(p types.PlayerImp) {
+	if r.expired != nil {
+		r.expired <- p.GetPlayerIdent()
+	}
+}
+
+type testCreator struct {
+	rooms []*testRoom
+}
+
+func (c *testCreator) CreateRoom(args interface{}) types.RoomImp {
+	room := &testRoom{id: len(c.rooms), args: args, players: map[string]bool{}}
+	c.rooms = append(c.rooms, room)
+	return room
+}
+
+func rooms(num ...int) []types.RoomImp {
+	var result []types.RoomImp
+	for i, n := range num {
+		room := &testRoom{id: i, players: map[string]bool{}}
+		for j := 0; j < n; j++ {
+			room.players[string(rune('a'+j))] = true
+		}
+		result = append(result, room)
+	}
+	return result
+}
+
+func ids(rooms []types.RoomImp) []int {
+	var result []int
+	for _, room := range rooms {
+		result = append(result, room.(*testRoom).id)
+	}
+	return result
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatcherCandidates(t *testing.T) {
+	p := newTestPlayer("p1", "USD")
+	all := rooms(2, 5, 0, 5)
+
+	if got := ids(FillFirstMatcher{}.Candidates(p, nil, all)); !equal(got, []int{1, 3, 0, 2}) {
+		t.Fatalf("fill first %v", got)
+	}
+	if got := ids(LeastLoadedMatcher{}.Candidates(p, nil, all)); !equal(got, []int{2, 0, 1, 3}) {
+		t.Fatalf("least loaded %v", got)
+	}
+	if got := ids(NewCapacityMatcher(FillFirstMatcher{}, 5).Candidates(p, nil, all)); !equal(got, []int{0, 2}) {
+		t.Fatalf("capacity %v", got)
+	}
+
+	roomType, args := NewCurrencyMatcher(FillFirstMatcher{}).Group(p)
+	if roomType != "app-97-USD" || args.(*types.RtpRoomArgs).Currency != "USD" {
+		t.Fatalf("currency group %s %+v", roomType, args)
+	}
+}
+
+func TestRoomManagerOnMatch(t *testing.T) {
+	creator := &testCreator{}
+	rm := NewRoomManager(types.GameBrand_Spribe, creator, types.TableMatcherType_CUSTOM, log.DefaultLogger)
+	if err := rm.OnMatch(newTestPlayer("p0", "USD")); !errors.Is(err, ErrNoTableMatcher) {
+		t.Fatalf("expected ErrNoTableMatcher, got %v", err)
+	}
+	rm.SetTableMatcher(NewCapacityMatcher(NewCurrencyMatcher(FillFirstMatcher{}), 2))
+
+	for _, p := range []*testPlayer{
+		newTestPlayer("p1", "USD"),
+		newTestPlayer("p2", "USD"),
+		newTestPlayer("p3", "USD"), // 第一个房间满了
+		newTestPlayer("p4", "EUR"), // 不同币种不同房间
+	} {
+		if err := rm.OnMatch(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(creator.rooms) != 3 {
+		t.Fatalf("created %d rooms", len(creator.rooms))
+	}
+	if creator.rooms[0].GetPlayerNum() != 2 || creator.rooms[1].GetPlayerNum() != 1 || creator.rooms[2].GetPlayerNum() != 1 {
+		t.Fatalf("unexpected seating %v %v %v", creator.rooms[0].players, creator.rooms[1].players, creator.rooms[2].players)
+	}
+	if !creator.rooms[2].players["app-p4"] {
+		t.Fatalf("EUR player seated at %v", creator.rooms[2].players)
+	}
+
+	// 房间拒绝时尝试下一个，都拒绝则新建
+	creator.rooms[1].full = true
+	if err := rm.OnMatch(newTestPlayer("p5", "USD")); err != nil {
+		t.Fatal(err)
+	}
+	if len(creator.rooms) != 4 || creator.rooms[3].GetPlayerNum() != 1 {
+		t.Fatalf("expected a new room, got %d rooms", len(creator.rooms))
+	}
+}
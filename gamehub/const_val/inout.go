@@ -3,3 +3,8 @@ package const_val
 const InoutPingTime = 25
 
 const InoutPingTimeWheelKey = "PingTimeWheelKey"
+
+// 开启座位超时(RoomManager.SetReconnectGrace)时推荐的秒数，默认不开启，断线后一直保留座位
+const ReconnectGraceTime = 120
+
+const SeatExpireTimeWheelKeyPrefix = "SeatExpire:"
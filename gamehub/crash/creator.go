@@ -0,0 +1,34 @@
+package crash
+
+import (
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// 按房间参数生成房间配置，RtpRoomArgs下每个商户/rtp/币种一个房间
+type OptionsFunc func(args interface{}) Options
+
+type RoomCreator struct {
+	options OptionsFunc
+}
+
+func NewRoomCreator(options OptionsFunc) *RoomCreator {
+	return &RoomCreator{options: options}
+}
+
+func (c *RoomCreator) CreateRoom(args interface{}) types.RoomImp {
+	opts := c.options(args)
+	if a, ok := args.(*types.RtpRoomArgs); ok {
+		if opts.AppId == "" {
+			opts.AppId = a.Appid
+		}
+		if opts.Rtp == "" {
+			opts.Rtp = a.Rtp
+		}
+		if opts.Currency == "" {
+			opts.Currency = a.Currency
+		}
+	}
+	return NewRoom(opts).Start()
+}
+
+var _ types.RoomCreator = (*RoomCreator)(nil)
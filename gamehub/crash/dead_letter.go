@@ -0,0 +1,81 @@
+package crash
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	FailedWin    = "win"
+	FailedRefund = "refund"
+	FailedSettle = "settle"
+
+	RedisDeadLetterKey = "crash:dead_letter"
+)
+
+// 重试用尽的钱包调用，保存后由补单任务或人工重新发起，派奖和退款不能丢
+type FailedCall struct {
+	Kind        string            `json:"kind"` // FailedWin、FailedRefund、FailedSettle
+	GameId      string            `json:"gameId"`
+	AppId       string            `json:"appId,omitempty"`
+	Win         *v1.WinRequest    `json:"win,omitempty"`
+	Refund      *v1.RefundRequest `json:"refund,omitempty"`
+	RoundId     string            `json:"roundId,omitempty"` // 以下为FailedSettle使用
+	BetTotal    float64           `json:"betTotal,omitempty"`
+	ProfitTotal float64           `json:"profitTotal,omitempty"`
+	Err         string            `json:"err"`
+}
+
+// 保存重试用尽的钱包调用，房间必须设置
+type DeadLetter interface {
+	Save(ctx context.Context, call *FailedCall) error
+}
+
+// 追加到redis的list，补单任务从另一端取出处理
+type RedisDeadLetter struct {
+	rdb *redis.Client
+	key string
+}
+
+// key为空时使用RedisDeadLetterKey
+func NewRedisDeadLetter(rdb *redis.Client, key string) *RedisDeadLetter {
+	if key == "" {
+		key = RedisDeadLetterKey
+	}
+	return &RedisDeadLetter{rdb: rdb, key: key}
+}
+
+func (d *RedisDeadLetter) Save(ctx context.Context, call *FailedCall) error {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+	return d.rdb.RPush(ctx, d.key, data).Err()
+}
+
+// 内存版本，仅用于测试和本地调试
+type MemoryDeadLetter struct {
+	mu    sync.Mutex
+	calls []*FailedCall
+}
+
+func NewMemoryDeadLetter() *MemoryDeadLetter {
+	return &MemoryDeadLetter{}
+}
+
+func (d *MemoryDeadLetter) Save(ctx context.Context, call *FailedCall) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls = append(d.calls, call)
+	return nil
+}
+
+func (d *MemoryDeadLetter) Calls() []*FailedCall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*FailedCall(nil), d.calls...)
+}
@@ -0,0 +1,59 @@
+package crash
+
+import (
+	"context"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/utils"
+)
+
+// 参与计算坠机倍数的客户端种子个数，取本局最先下注的几个真实玩家
+const DefaultFairClientSeeds = 3
+
+// 可证明公平的坠机倍数来源: 开局时从种子链取一局并公布ServerSeed的承诺，
+// 停止下注后加入最先下注的玩家的客户端种子算出 坠机倍数 = CrashCurve.CrashAt(Outcome.Float)，
+// 坠机后公开ServerSeed，玩家可以用fairness.VerifyHandler复算
+type FairCrashSource struct {
+	engine      *fairness.Engine
+	clientSeeds fairness.ClientSeedStore
+	rtp         float64
+	maxSeeds    int
+}
+
+// clientSeeds一般为RoomManager.ClientSeeds()，与玩家设置种子的指令共用
+func NewFairCrashSource(engine *fairness.Engine, clientSeeds fairness.ClientSeedStore, rtp float64) *FairCrashSource {
+	return &FairCrashSource{engine: engine, clientSeeds: clientSeeds, rtp: rtp, maxSeeds: DefaultFairClientSeeds}
+}
+
+// 设置参与计算的客户端种子个数
+func (s *FairCrashSource) SetMaxClientSeeds(n int) *FairCrashSource {
+	if n > 0 {
+		s.maxSeeds = n
+	}
+	return s
+}
+
+func (s *FairCrashSource) NextRound(ctx context.Context) (*fairness.Round, error) {
+	return s.engine.NextRound(ctx)
+}
+
+// 停止下注后用玩家的客户端种子算出坠机倍数，返回实际使用的种子，与playerIdents的前几个一一对应
+func (s *FairCrashSource) CrashPoint(ctx context.Context, round *fairness.Round, playerIdents []string) (float64, []string, error) {
+	if len(playerIdents) > s.maxSeeds {
+		playerIdents = playerIdents[:s.maxSeeds]
+	}
+	seeds, err := fairness.ClientSeedsOf(ctx, s.clientSeeds, playerIdents)
+	if err != nil {
+		return 0, nil, err
+	}
+	outcome, err := round.Outcome(seeds)
+	if err != nil {
+		return 0, nil, err
+	}
+	return FairCrashPoint(s.rtp, outcome), seeds, nil
+}
+
+// 由公开的结果复算坠机倍数
+func FairCrashPoint(rtp float64, outcome fairness.Outcome) float64 {
+	return utils.GetCrashCurve(rtp).CrashAt(outcome.Float)
+}
@@ -0,0 +1,153 @@
+package crash
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 记录发出的事件
+type captureSerializer struct {
+	InoutSerializer
+
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (s *captureSerializer) Encode(player types.PlayerImp, ev *Event) (*Frame, error) {
+	s.mu.Lock()
+	s.events = append(s.events, ev)
+	s.mu.Unlock()
+	return s.InoutSerializer.Encode(player, ev)
+}
+
+func (s *captureSerializer) phase(phase Phase) *Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ev := range s.events {
+		if ev.Type == EventPhase && ev.Phase == phase {
+			return ev
+		}
+	}
+	return nil
+}
+
+// 用同样的种子链跑一局，p1的客户端种子为seed
+func playFairRound(t *testing.T, seed string) (*Event, *Event) {
+	t.Helper()
+	ctx := context.Background()
+	engine, err := fairness.NewEngine("aviator", fairness.NewMemoryChainStore(), fairness.ChainConfig{
+		Secret: []byte("0123456789abcdef-test"),
+		Length: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := fairness.NewMemoryClientSeedStore()
+	if err := seeds.Set(ctx, "app-p1", seed); err != nil {
+		t.Fatal(err)
+	}
+	if err := seeds.Set(ctx, "app-p2", "seed-p2"); err != nil {
+		t.Fatal(err)
+	}
+
+	serializer := &captureSerializer{}
+	clock := &testClock{t: time.Unix(1700000000, 0)}
+	r := NewRoom(Options{
+		GameBrand:   types.GameBrand_Inout,
+		GameId:      "aviator",
+		Wallet:      newTestWallet(),
+		Fairness:    NewFairCrashSource(engine, seeds, 97).SetMaxClientSeeds(2),
+		Serializer:  serializer,
+		DeadLetter:  NewMemoryDeadLetter(),
+		NextRoundId: func(ctx context.Context) (string, error) { return "r1", nil },
+	})
+	r.now = clock.now
+	t.Cleanup(r.OnDispose)
+
+	p1, p2, p3 := &testPlayer{id: "p1"}, &testPlayer{id: "p2"}, &testPlayer{id: "p3"}
+	r.OnJoin(p1)
+	r.OnJoin(p2)
+	r.OnJoin(p3)
+	if err := r.startBetting(); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个玩家的第二笔和超出个数的玩家不参与计算
+	r.Bet(p1, &Command{Type: CmdBet, Amount: 10})
+	r.Bet(p1, &Command{Type: CmdBet, Index: 1, Amount: 10})
+	r.Bet(p2, &Command{Type: CmdBet, Amount: 10})
+	r.Bet(p3, &Command{Type: CmdBet, Amount: 10})
+	r.startFlying()
+	clock.t = clock.t.Add(time.Hour)
+	if !r.tick(clock.t) {
+		t.Fatal("should crash")
+	}
+	r.wg.Wait()
+	return serializer.phase(PhaseBetting), serializer.phase(PhaseCrashed)
+}
+
+func TestRoomFairCrash(t *testing.T) {
+	betting, crashed := playFairRound(t, "seed-a")
+	if betting == nil || crashed == nil || crashed.Fairness == nil {
+		t.Fatal("missing fairness events")
+	}
+	rec := crashed.Fairness
+	if betting.HashedServerSeed == "" || betting.HashedServerSeed != rec.HashedServerSeed {
+		t.Fatalf("commitment %q does not match revealed round %q", betting.HashedServerSeed, rec.HashedServerSeed)
+	}
+	if len(rec.Players) != 2 || rec.Players[0].Seed != "seed-a" || rec.Players[1].Seed != "seed-p2" || rec.RoundId != "r1" {
+		t.Fatalf("unexpected players %+v", rec.Players)
+	}
+
+	// 公开的种子可以通过校验，并且复算出同样的坠机倍数
+	outcome, err := fairness.VerifyRound(fairness.RevealedRound{
+		GameId:           rec.GameId,
+		ChainId:          rec.ChainId,
+		Nonce:            rec.Nonce,
+		ServerSeed:       rec.ServerSeed,
+		HashedServerSeed: rec.HashedServerSeed,
+		ChainCommitment:  rec.ChainCommitment,
+		ClientSeeds:      rec.ClientSeeds(),
+		Outcome:          &rec.Outcome,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crash := FairCrashPoint(97, outcome); crash != crashed.Multiplier || crash != rec.Result {
+		t.Fatalf("recomputed crash %v, room crashed at %v", crash, crashed.Multiplier)
+	}
+
+	app := fiber.New()
+	app.Get("/fairness/verify", fairness.VerifyHandler)
+	query := "/fairness/verify?server_seed=" + rec.ServerSeed + "&client_seeds=" + strings.Join(rec.ClientSeeds(), ",") +
+		"&nonce=" + strconv.FormatInt(rec.Nonce, 10) + "&hashed_server_seed=" + rec.HashedServerSeed
+	resp, err := app.Test(httptest.NewRequest("GET", query, nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("verify %v %v", resp, err)
+	}
+	var rsp struct {
+		fairness.Outcome
+		CommitmentValid *bool `json:"commitment_valid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.CommitmentValid == nil || !*rsp.CommitmentValid || FairCrashPoint(97, rsp.Outcome) != crashed.Multiplier {
+		t.Fatalf("verify endpoint does not reproduce the crash: %+v", rsp)
+	}
+
+	// 玩家更换客户端种子后结果不同
+	_, other := playFairRound(t, "seed-b")
+	if other.Fairness.ServerSeed != rec.ServerSeed || other.Fairness.Outcome.Hash == rec.Outcome.Hash {
+		t.Fatal("changing the client seed should change the outcome")
+	}
+}
@@ -0,0 +1,1149 @@
+package crash
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/feed"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// 钱包调用失败后的重试间隔，派奖和退款必须最终成功，重试用尽后交给Options.DeadLetter
+var walletRetryDelays = []time.Duration{time.Second, 3 * time.Second, 10 * time.Second}
+
+type bet struct {
+	player  types.PlayerImp // 重连时会被替换，只能在mu保护下读写
+	ident   string
+	appId   string
+	bot     bool
+	roundId string
+	txId    string
+	view    BetView
+}
+
+// 实现types.RoomImp，所有状态由mu保护，钱包调用都在锁外进行
+type Room struct {
+	opts Options
+	log  *log.Helper
+	now  func() time.Time
+
+	mu         sync.Mutex
+	players    map[string]types.PlayerImp
+	spectators map[string]types.PlayerImp // 观战玩家，只接收广播，不计入人数
+	phase      Phase
+	roundId    string
+	preRoundId string
+	phaseAt    time.Time // 当前阶段开始的时间
+	crashPoint float64
+	lastTick   float64
+	bets       map[string][]*bet // 玩家唯一标识 -> 下注框
+	history    []float64
+	live       *feed.Live
+
+	// 可证明公平时本局的种子和参与计算的下注(每个玩家最先的一笔)
+	fairRound   *fairness.Round
+	seedBets    []*bet
+	fairPlayers []fairness.PlayerSeed
+
+	closed   bool
+	roundSeq atomic.Int64
+	started  atomic.Bool
+	stop     chan struct{}
+	stopOnce sync.Once
+	// 排空: drain关闭后不再开始新的一局，局循环停下时关闭parked
+	drain     chan struct{}
+	drainOnce sync.Once
+	parked    chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup // 进行中的钱包调用，OnDispose不等待
+}
+
+// Options.DeadLetter为空时panic，没有它重试用尽的派奖和退款会丢失
+func NewRoom(opts Options) *Room {
+	if opts.DeadLetter == nil {
+		panic("crash: Options.DeadLetter is required")
+	}
+	opts.normalize()
+	return &Room{
+		opts:       opts,
+		log:        log.NewHelper(log.With(opts.Logger, "module", "crash", "game", opts.GameId)),
+		now:        time.Now,
+		players:    make(map[string]types.PlayerImp),
+		spectators: make(map[string]types.PlayerImp),
+		bets:       make(map[string][]*bet),
+		live:       feed.NewLive(),
+		stop:       make(chan struct{}),
+		drain:      make(chan struct{}),
+		parked:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// 启动局循环
+func (r *Room) Start() *Room {
+	if r.started.CompareAndSwap(false, true) {
+		go r.run()
+	}
+	return r
+}
+
+// ========================================================================================
+// types.RoomImp
+
+func (r *Room) GetPlayerNum() int32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return int32(len(r.players))
+}
+
+func (r *Room) OnJoin(player types.PlayerImp) error {
+	select {
+	case <-r.stop:
+		return ErrRoomClosed
+	default:
+	}
+	r.mu.Lock()
+	r.players[player.GetPlayerIdent()] = player
+	ev := r.stateLocked()
+	r.mu.Unlock()
+	r.send(player, ev)
+	if r.opts.Chat != nil {
+		r.opts.Chat.Join(player)
+	}
+	return nil
+}
+
+func (r *Room) OnSwitch(player types.PlayerImp, args interface{}) error {
+	return r.OnJoin(player)
+}
+
+// 重连后替换玩家对象，进行中的下注继续有效
+func (r *Room) OnReConnect(player types.PlayerImp) error {
+	ident := player.GetPlayerIdent()
+	r.mu.Lock()
+	r.players[ident] = player
+	for _, b := range r.bets[ident] {
+		if b != nil {
+			b.player = player
+		}
+	}
+	ev := r.stateLocked()
+	r.mu.Unlock()
+	r.send(player, ev)
+	if r.opts.Chat != nil {
+		r.opts.Chat.Join(player)
+	}
+	return nil
+}
+
+// 断线后下注保留，自动提现和结算照常进行
+func (r *Room) OnDisConnect(player types.PlayerImp) error {
+	if r.opts.Chat != nil {
+		r.opts.Chat.Leave(player)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ident := player.GetPlayerIdent()
+	if r.players[ident] == player {
+		delete(r.players, ident)
+	}
+	return nil
+}
+
+// ========================================================================================
+// types.SpectatableRoomImp
+
+func (r *Room) OnSpectate(player types.PlayerImp) error {
+	select {
+	case <-r.stop:
+		return ErrRoomClosed
+	default:
+	}
+	r.mu.Lock()
+	r.spectators[player.GetPlayerIdent()] = player
+	ev := r.stateLocked()
+	r.mu.Unlock()
+	r.send(player, ev)
+	if r.opts.Chat != nil {
+		r.opts.Chat.Join(player)
+	}
+	return nil
+}
+
+func (r *Room) OnSpectatorLeave(player types.PlayerImp) {
+	if r.opts.Chat != nil {
+		r.opts.Chat.Leave(player)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ident := player.GetPlayerIdent()
+	if r.spectators[ident] == player {
+		delete(r.spectators, ident)
+	}
+}
+
+// 当前观战人数
+func (r *Room) SpectatorNum() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.spectators)
+}
+
+// ========================================================================================
+// types.DrainableRoomImp
+
+// 不再开始新的一局，等待飞行中的一局坠机结算；下注阶段直接停下，下注在OnDispose时退款
+func (r *Room) Drain(ctx context.Context) error {
+	r.drainOnce.Do(func() { close(r.drain) })
+	if !r.started.Load() {
+		return nil
+	}
+	select {
+	case <-r.parked:
+		return nil
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 断线超时: 下注阶段的下注退款，飞行中的下注按当前倍数提现
+func (r *Room) OnSeatExpired(player types.PlayerImp) {
+	r.mu.Lock()
+	phase := r.phase
+	slots := len(r.bets[player.GetPlayerIdent()])
+	r.mu.Unlock()
+
+	for i := 0; i < slots; i++ {
+		switch phase {
+		case PhaseBetting:
+			r.Cancel(player, &Command{Type: CmdCancel, Index: i})
+		case PhaseFlying:
+			r.Cashout(player, &Command{Type: CmdCashout, Index: i})
+		}
+	}
+}
+
+func (r *Room) OnMessage(player types.PlayerImp, data interface{}) error {
+	if r.opts.Chat != nil {
+		if ok, err := r.opts.Chat.OnMessage(player, data); ok {
+			return err
+		}
+	}
+	cmd, err := r.opts.Serializer.Decode(data)
+	if err != nil {
+		r.log.Warnf("decode message from %s failed: %v", player.GetPlayerIdent(), err)
+		return nil
+	}
+	if cmd == nil {
+		return nil
+	}
+
+	// 观战玩家只能查询，不能下注和提现
+	if types.IsSpectator(player) {
+		switch cmd.Type {
+		case CmdBet, CmdCancel, CmdCashout, CmdSetAuto:
+			r.send(player, &Event{Type: EventError, RoundId: r.RoundId(), Reply: cmd, Err: ErrSpectator})
+			return nil
+		}
+	}
+
+	switch cmd.Type {
+	case CmdState:
+		r.mu.Lock()
+		ev := r.stateLocked()
+		r.mu.Unlock()
+		ev.Reply = cmd
+		r.send(player, ev)
+		return nil
+	case CmdBet:
+		err = r.Bet(player, cmd)
+	case CmdCancel:
+		err = r.Cancel(player, cmd)
+	case CmdCashout:
+		err = r.Cashout(player, cmd)
+	case CmdSetAuto:
+		err = r.SetAutoCashout(player, cmd)
+	case CmdMyBets:
+		r.send(player, &Event{Type: EventMyBets, RoundId: r.RoundId(), Reply: cmd, Feed: r.live.MyBets(player.GetPlayerIdent(), r.opts.MyBetsSize)})
+		return nil
+	case CmdTopWins:
+		var bets []feed.Bet
+		bets, err = r.TopWins(cmd.Kind, cmd.Period, player.GetCurrency())
+		if err == nil {
+			r.send(player, &Event{Type: EventTopWins, RoundId: r.RoundId(), Reply: cmd, Feed: bets})
+		}
+	default:
+		err = fmt.Errorf("crash: unknown command %d", cmd.Type)
+	}
+	if err != nil {
+		r.send(player, &Event{Type: EventError, RoundId: r.RoundId(), Reply: cmd, Err: err})
+	}
+	return nil
+}
+
+// 房间销毁，未结束的下注全部退款。
+// RoomManager持有锁调用，这里不等待钱包调用；销毁后失败的调用不再重试，直接保存到死信
+func (r *Room) OnDispose() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		if r.started.Load() {
+			<-r.done
+		}
+
+		r.mu.Lock()
+		r.closed = true
+		var refunds []*bet
+		if r.phase == PhaseBetting || r.phase == PhaseFlying {
+			for _, slots := range r.bets {
+				for _, b := range slots {
+					if b != nil && b.view.Status == BetPlaced {
+						b.view.Status = BetRefunded
+						refunds = append(refunds, b)
+					}
+				}
+			}
+		}
+		r.mu.Unlock()
+
+		for _, b := range refunds {
+			r.refundAsync(b)
+		}
+	})
+}
+
+// ========================================================================================
+
+func (r *Room) RoundId() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.roundId
+}
+
+// 房间的实时下注，可以用来查询本局、上一局和玩家自己的下注
+func (r *Room) Live() *feed.Live {
+	return r.live
+}
+
+// 本游戏的最高赢奖排行榜，金额换算成currency
+func (r *Room) TopWins(kind feed.Kind, period feed.Period, currency string) ([]feed.Bet, error) {
+	if r.opts.TopWins == nil {
+		return nil, nil
+	}
+	if kind == "" {
+		kind = feed.KindWin
+	}
+	if period == "" {
+		period = feed.PeriodDay
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.WalletTimeout)
+	defer cancel()
+	return r.opts.TopWins.Top(ctx, string(r.opts.GameBrand), r.opts.GameId, kind, period, currency, feed.DefaultTopWinsSize)
+}
+
+func (r *Room) Phase() Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.phase
+}
+
+// 飞行了d之后的倍数，向下取整保留两位小数
+func (r *Room) multiplierAt(d time.Duration) float64 {
+	m := math.Exp(r.opts.GrowthRate * float64(d.Milliseconds()))
+	return math.Floor(m*100+1e-9) / 100
+}
+
+// 从起飞到坠机需要的时间
+func (r *Room) flightDuration(crashPoint float64) time.Duration {
+	return time.Duration(math.Log(crashPoint)/r.opts.GrowthRate) * time.Millisecond
+}
+
+func (r *Room) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// 下注阶段的等待，排空时提前结束
+func (r *Room) sleepBetting() bool {
+	t := time.NewTimer(r.opts.BettingTime)
+	defer t.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-r.drain:
+		return true
+	case <-t.C:
+		return true
+	}
+}
+
+// 排空时停下局循环，等待房间销毁
+func (r *Room) park() bool {
+	select {
+	case <-r.drain:
+	default:
+		return false
+	}
+	close(r.parked)
+	<-r.stop
+	return true
+}
+
+func (r *Room) run() {
+	defer close(r.done)
+	for {
+		if r.park() {
+			return
+		}
+		if err := r.startBetting(); err != nil {
+			r.log.Errorf("start round failed: %v", err)
+			if !r.sleep(time.Second) {
+				return
+			}
+			continue
+		}
+		if !r.sleepBetting() {
+			return
+		}
+		// 下注阶段开始排空，已经下的注在房间销毁时退款
+		if r.park() {
+			return
+		}
+		r.startFlying()
+
+		ticker := time.NewTicker(r.opts.TickInterval)
+		crashed := false
+		for !crashed {
+			select {
+			case <-r.stop:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				crashed = r.tick(r.now())
+			}
+		}
+		ticker.Stop()
+
+		if !r.sleep(r.opts.CrashedTime) {
+			return
+		}
+	}
+}
+
+func (r *Room) nextRoundId(ctx context.Context) (string, error) {
+	if r.opts.NextRoundId != nil {
+		return r.opts.NextRoundId(ctx)
+	}
+	return strconv.FormatInt(time.Now().Unix()*1000+r.roundSeq.Add(1)%1000, 10), nil
+}
+
+// 开始新的一局，坠机倍数在开放下注之前就已经确定；可证明公平时只公布承诺，停止下注后才算出坠机倍数
+func (r *Room) startBetting() error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.WalletTimeout)
+	defer cancel()
+
+	roundId, err := r.nextRoundId(ctx)
+	if err != nil {
+		return err
+	}
+	var crashPoint float64
+	var round *fairness.Round
+	if r.opts.Fairness != nil {
+		round, err = r.opts.Fairness.NextRound(ctx)
+	} else {
+		crashPoint, err = r.opts.CrashSource.CrashPoint(ctx, roundId)
+	}
+	if err != nil {
+		return err
+	}
+	if crashPoint < 1 {
+		crashPoint = 1
+	}
+
+	r.mu.Lock()
+	r.preRoundId = r.roundId
+	r.roundId = roundId
+	r.crashPoint = crashPoint
+	r.fairRound = round
+	r.seedBets = nil
+	r.fairPlayers = nil
+	r.phase = PhaseBetting
+	r.phaseAt = r.now()
+	r.lastTick = 1
+	r.bets = make(map[string][]*bet)
+	r.live.NextRound(roundId)
+	ev := &Event{Type: EventPhase, RoundId: roundId, Phase: PhaseBetting, Multiplier: 1, TimeLeft: r.opts.BettingTime}
+	if round != nil {
+		ev.HashedServerSeed = round.HashedServerSeed
+	}
+	r.mu.Unlock()
+
+	r.broadcast(ev)
+	r.scheduleBots()
+	return nil
+}
+
+// 机器人在下注阶段的随机时间下注
+func (r *Room) scheduleBots() {
+	if r.opts.Bots == nil {
+		return
+	}
+	// 留出最后一段时间，避免和阶段切换竞争
+	window := r.opts.BettingTime * 8 / 10
+	for _, b := range r.opts.Bots.RoundBets(r.opts.MaxBetsPerPlayer) {
+		cmd := &Command{Type: CmdBet, Index: b.Index, Amount: b.Amount, AutoCashout: b.AutoCashout}
+		player := b.Bot
+		time.AfterFunc(time.Duration(b.Delay*float64(window)), func() {
+			if err := r.Bet(player, cmd); err != nil && !IsUserError(err) && !errors.Is(err, ErrRoomClosed) {
+				r.log.Warnf("bot %s bet failed: %v", player.GetPlayerId(), err)
+			}
+		})
+	}
+}
+
+func (r *Room) startFlying() {
+	r.fairCrashPoint()
+
+	r.mu.Lock()
+	r.phase = PhaseFlying
+	r.phaseAt = r.now()
+	ev := &Event{Type: EventPhase, RoundId: r.roundId, Phase: PhaseFlying, Multiplier: 1}
+	r.mu.Unlock()
+
+	r.broadcast(ev)
+}
+
+// 停止下注时用最先下注的玩家的客户端种子算出坠机倍数
+func (r *Room) fairCrashPoint() {
+	r.mu.Lock()
+	round := r.fairRound
+	bets := append([]*bet(nil), r.seedBets...)
+	r.mu.Unlock()
+	if round == nil {
+		return
+	}
+
+	idents := make([]string, len(bets))
+	for i, b := range bets {
+		idents[i] = b.ident
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.opts.WalletTimeout)
+	defer cancel()
+	crashPoint, seeds, err := r.opts.Fairness.CrashPoint(ctx, round, idents)
+	if err != nil {
+		// 读不到客户端种子时只用ServerSeed计算，结果同样可以复算
+		r.log.Errorf("round %s client seeds failed: %v", r.RoundId(), err)
+		crashPoint, seeds, err = r.opts.Fairness.CrashPoint(ctx, round, nil)
+		if err != nil {
+			r.log.Errorf("round %s outcome failed: %v", r.RoundId(), err)
+		}
+	}
+	players := make([]fairness.PlayerSeed, len(seeds))
+	for i, seed := range seeds {
+		b := bets[i]
+		players[i] = fairness.PlayerSeed{
+			AppId:    b.appId,
+			PlayerId: b.view.PlayerId,
+			Nickname: b.view.Nickname,
+			Avatar:   b.view.Avatar,
+			Seed:     seed,
+		}
+	}
+
+	r.mu.Lock()
+	r.crashPoint = max(crashPoint, 1)
+	r.fairPlayers = players
+	r.mu.Unlock()
+}
+
+// 定时推进飞行，返回是否已经坠机
+func (r *Room) tick(now time.Time) bool {
+	r.mu.Lock()
+	if r.phase != PhaseFlying {
+		r.mu.Unlock()
+		return false
+	}
+
+	m := r.multiplierAt(now.Sub(r.phaseAt))
+	var events []*Event
+	var wins []*bet
+	// 自动提现按设置的倍数成交，与tick的间隔无关；设置的倍数等于坠机倍数也算赢，先于坠机处理
+	reached := math.Min(m, r.crashPoint)
+	for _, slots := range r.bets {
+		for _, b := range slots {
+			if b != nil && b.view.Status == BetPlaced && b.view.AutoCashout > 0 && b.view.AutoCashout <= reached {
+				events = append(events, r.cashoutLocked(b, b.view.AutoCashout))
+				wins = append(wins, b)
+			}
+		}
+	}
+
+	if m >= r.crashPoint {
+		crashEvents, settles := r.crashLocked()
+		events = append(events, crashEvents...)
+		roundId := r.roundId
+		betTotal, profitTotal := r.totalsLocked()
+		r.mu.Unlock()
+		r.broadcastAll(events)
+		for _, b := range append(wins, settles...) {
+			r.settleAsync(b)
+		}
+		r.reportAsync(roundId, betTotal, profitTotal)
+		return true
+	}
+
+	if m != r.lastTick {
+		r.lastTick = m
+		events = append([]*Event{{Type: EventTick, RoundId: r.roundId, Phase: PhaseFlying, Multiplier: m}}, events...)
+	}
+	r.mu.Unlock()
+
+	r.broadcastAll(events)
+	for _, b := range wins {
+		r.settleAsync(b)
+	}
+	return false
+}
+
+// 坠机: 自动提现倍数不超过坠机倍数的按自动提现成交，其余的都输掉
+func (r *Room) crashLocked() ([]*Event, []*bet) {
+	var events []*Event
+	var settles []*bet
+	for _, slots := range r.bets {
+		for _, b := range slots {
+			if b == nil || b.view.Status != BetPlaced {
+				continue
+			}
+			if b.view.AutoCashout > 0 && b.view.AutoCashout <= r.crashPoint {
+				events = append(events, r.cashoutLocked(b, b.view.AutoCashout))
+			} else {
+				b.view.Status = BetLost
+				r.live.Settle(b.txId, 0, 0)
+			}
+			settles = append(settles, b)
+		}
+	}
+
+	r.phase = PhaseCrashed
+	r.phaseAt = r.now()
+	r.history = append(r.history, r.crashPoint)
+	if len(r.history) > r.opts.HistorySize {
+		r.history = r.history[len(r.history)-r.opts.HistorySize:]
+	}
+	ev := &Event{Type: EventPhase, RoundId: r.roundId, Phase: PhaseCrashed, Multiplier: r.crashPoint}
+	if r.fairRound != nil {
+		rec, err := fairness.NewRecord(r.roundId, r.fairRound.Reveal(), r.fairPlayers, r.crashPoint)
+		if err != nil {
+			r.log.Errorf("round %s fairness record failed: %v", r.roundId, err)
+		}
+		ev.Fairness = rec
+		r.fairRound = nil
+	}
+	events = append(events, ev)
+	return events, settles
+}
+
+// 本局的下注总额和平台盈利，取消、退款和机器人的不算
+func (r *Room) totalsLocked() (betTotal, profitTotal float64) {
+	for _, slots := range r.bets {
+		for _, b := range slots {
+			if b != nil && !b.bot && (b.view.Status == BetCashedOut || b.view.Status == BetLost) {
+				betTotal += b.view.Amount
+				profitTotal += b.view.Amount - b.view.Win
+			}
+		}
+	}
+	return
+}
+
+func (r *Room) cashoutLocked(b *bet, multiplier float64) *Event {
+	b.view.Status = BetCashedOut
+	b.view.Multiplier = multiplier
+	b.view.Win = math.Floor(b.view.Amount*multiplier*100+1e-9) / 100
+	view := b.view
+	if fb, ok := r.live.Settle(b.txId, multiplier, view.Win); ok {
+		r.recordTopWin(fb)
+	}
+	return &Event{Type: EventCashout, RoundId: b.roundId, Phase: r.phase, Multiplier: multiplier, Bet: &view}
+}
+
+func (r *Room) slot(ident string, index int) (*bet, error) {
+	if index < 0 || index >= r.opts.MaxBetsPerPlayer {
+		return nil, ErrInvalidIndex
+	}
+	slots := r.bets[ident]
+	if slots == nil || slots[index] == nil {
+		return nil, ErrBetNotFound
+	}
+	return slots[index], nil
+}
+
+func (r *Room) Bet(player types.PlayerImp, cmd *Command) error {
+	if cmd.Amount <= 0 || (r.opts.MinBet > 0 && cmd.Amount < r.opts.MinBet) || (r.opts.MaxBet > 0 && cmd.Amount > r.opts.MaxBet) {
+		return ErrInvalidBet
+	}
+	if cmd.AutoCashout != 0 && (cmd.AutoCashout < 1.01 || cmd.AutoCashout > r.opts.MaxAutoCashout) {
+		return ErrInvalidAuto
+	}
+	if cmd.Index < 0 || cmd.Index >= r.opts.MaxBetsPerPlayer {
+		return ErrInvalidIndex
+	}
+	select {
+	case <-r.drain:
+		return ErrRoomClosed
+	default:
+	}
+
+	ident := player.GetPlayerIdent()
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRoomClosed
+	}
+	if r.phase != PhaseBetting {
+		r.mu.Unlock()
+		return ErrNotBettingPhase
+	}
+	slots := r.bets[ident]
+	if slots == nil {
+		slots = make([]*bet, r.opts.MaxBetsPerPlayer)
+		r.bets[ident] = slots
+	}
+	if slots[cmd.Index] != nil {
+		r.mu.Unlock()
+		return ErrBetExists
+	}
+	b := &bet{
+		player:  player,
+		ident:   ident,
+		appId:   player.GetAppId(),
+		bot:     types.IsBot(player),
+		roundId: r.roundId,
+		txId:    fmt.Sprintf("%s-%s-%d", r.roundId, ident, cmd.Index),
+		view: BetView{
+			PlayerId:    player.GetPlayerId(),
+			Currency:    player.GetCurrency(),
+			Index:       cmd.Index,
+			Amount:      cmd.Amount,
+			AutoCashout: cmd.AutoCashout,
+			Status:      BetPending,
+		},
+	}
+	b.view.IsBot = b.bot
+	if profile, ok := player.(types.ProfileImp); ok {
+		b.view.Nickname = profile.GetNickname()
+		b.view.Avatar = profile.GetAvatar()
+	}
+	slots[cmd.Index] = b
+	preRoundId := r.preRoundId
+	r.mu.Unlock()
+
+	var reply *v1.BetReply
+	var err error
+	if !b.bot {
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.WalletTimeout)
+		defer cancel()
+		reply, err = r.opts.Wallet.Bet(ctx, player.GetAppId(), &v1.BetRequest{
+			PlayerId:      player.GetPlayerId(),
+			RoundId:       b.roundId,
+			PreRoundId:    preRoundId,
+			Currency:      player.GetCurrency(),
+			Bet:           cmd.Amount,
+			GameBrand:     string(r.opts.GameBrand),
+			GameId:        r.opts.GameId,
+			Rtp:           r.opts.Rtp,
+			TransactionId: b.txId,
+		})
+	}
+
+	r.mu.Lock()
+	accepted := err == nil && !r.closed && r.phase == PhaseBetting && r.roundId == b.roundId && r.bets[ident] != nil && r.bets[ident][cmd.Index] == b
+	if accepted {
+		b.view.Status = BetPlaced
+		r.addSeedBetLocked(b)
+	} else {
+		if slots := r.bets[ident]; slots != nil && slots[cmd.Index] == b {
+			slots[cmd.Index] = nil
+		}
+		b.view.Status = BetRefunded
+	}
+	view := b.view
+	r.mu.Unlock()
+
+	if err != nil {
+		// 超时等错误时不确定是否已经扣款，统一退款
+		r.log.Errorf("bet %s failed: %v", b.txId, err)
+		r.refundAsync(b)
+		return err
+	}
+	if !accepted {
+		r.refundAsync(b)
+		player.SetBalanceByBetReply(reply)
+		return ErrNotBettingPhase
+	}
+
+	player.SetBalanceByBetReply(reply)
+	r.live.Place(ident, feed.Bet{
+		Id:       b.txId,
+		RoundId:  b.roundId,
+		PlayerId: view.PlayerId,
+		Nickname: view.Nickname,
+		Avatar:   view.Avatar,
+		IsBot:    view.IsBot,
+		Currency: view.Currency,
+		Amount:   view.Amount,
+		Time:     r.now().UnixMilli(),
+	})
+	r.send(player, &Event{Type: EventBetPlaced, RoundId: b.roundId, Phase: PhaseBetting, Bet: &view, Reply: cmd})
+	r.broadcastExcept(player, &Event{Type: EventBetPlaced, RoundId: b.roundId, Phase: PhaseBetting, Bet: &view})
+	return nil
+}
+
+// 记录参与计算坠机倍数的下注，每个真实玩家只取最先的一笔
+func (r *Room) addSeedBetLocked(b *bet) {
+	if r.fairRound == nil || b.bot || len(r.seedBets) >= r.opts.Fairness.maxSeeds {
+		return
+	}
+	for _, one := range r.seedBets {
+		if one.ident == b.ident {
+			return
+		}
+	}
+	r.seedBets = append(r.seedBets, b)
+}
+
+// 下注阶段取消下注并退款
+func (r *Room) Cancel(player types.PlayerImp, cmd *Command) error {
+	ident := player.GetPlayerIdent()
+	r.mu.Lock()
+	if r.phase != PhaseBetting {
+		r.mu.Unlock()
+		return ErrNotBettingPhase
+	}
+	b, err := r.slot(ident, cmd.Index)
+	if err != nil || b.view.Status != BetPlaced {
+		r.mu.Unlock()
+		return ErrBetNotFound
+	}
+	r.bets[ident][cmd.Index] = nil
+	b.view.Status = BetRefunded
+	view := b.view
+	r.mu.Unlock()
+
+	r.live.Remove(b.txId)
+	r.refundAsync(b)
+	r.send(player, &Event{Type: EventBetCanceled, RoundId: b.roundId, Phase: PhaseBetting, Bet: &view, Reply: cmd})
+	r.broadcastExcept(player, &Event{Type: EventBetCanceled, RoundId: b.roundId, Phase: PhaseBetting, Bet: &view})
+	return nil
+}
+
+// 手动提现，以收到请求时的真实倍数成交，恰好等于坠机倍数也算赢；
+// 此时如果已经超过坠机倍数，即使坠机的tick还没处理也算失败
+func (r *Room) Cashout(player types.PlayerImp, cmd *Command) error {
+	ident := player.GetPlayerIdent()
+	r.mu.Lock()
+	if r.phase != PhaseFlying {
+		r.mu.Unlock()
+		if r.Phase() == PhaseCrashed {
+			return ErrTooLate
+		}
+		return ErrNotFlying
+	}
+	b, err := r.slot(ident, cmd.Index)
+	if err != nil || b.view.Status != BetPlaced {
+		r.mu.Unlock()
+		return ErrBetNotFound
+	}
+	m := r.multiplierAt(r.now().Sub(r.phaseAt))
+	if m > r.crashPoint {
+		r.mu.Unlock()
+		return ErrTooLate
+	}
+	// 自动提现已经到达但tick还没处理的，按自动提现倍数成交
+	if b.view.AutoCashout > 0 && b.view.AutoCashout <= m {
+		m = b.view.AutoCashout
+	}
+	ev := r.cashoutLocked(b, m)
+	r.mu.Unlock()
+
+	r.settleAsync(b)
+	reply := *ev
+	reply.Reply = cmd
+	r.send(player, &reply)
+	r.broadcastExcept(player, ev)
+	return nil
+}
+
+// 修改还未提现的下注的自动提现倍数
+func (r *Room) SetAutoCashout(player types.PlayerImp, cmd *Command) error {
+	if cmd.AutoCashout != 0 && (cmd.AutoCashout < 1.01 || cmd.AutoCashout > r.opts.MaxAutoCashout) {
+		return ErrInvalidAuto
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := r.slot(player.GetPlayerIdent(), cmd.Index)
+	if err != nil || b.view.Status != BetPlaced {
+		return ErrBetNotFound
+	}
+	b.view.AutoCashout = cmd.AutoCashout
+	return nil
+}
+
+// ========================================================================================
+// 钱包
+
+// 下注时的玩家对象重连后会被替换，余额更新到当前的玩家对象
+func (r *Room) betPlayer(b *bet) types.PlayerImp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return b.player
+}
+
+// 提现派奖或输掉结算(win为0)
+func (r *Room) settleAsync(b *bet) {
+	if b.bot {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		req := &v1.WinRequest{
+			PlayerId:         b.view.PlayerId,
+			RoundId:          b.roundId,
+			Currency:         b.view.Currency,
+			Bet:              b.view.Amount,
+			GameBrand:        string(r.opts.GameBrand),
+			GameId:           r.opts.GameId,
+			Win:              b.view.Win,
+			BetTransactionId: b.txId,
+		}
+		err := r.retry("win "+b.txId, func(ctx context.Context) error {
+			reply, err := r.opts.Wallet.Win(ctx, b.appId, req)
+			if err == nil {
+				r.betPlayer(b).SetBalanceByWinReply(reply)
+			}
+			return err
+		})
+		if err != nil {
+			r.deadLetter(&FailedCall{Kind: FailedWin, AppId: b.appId, Win: req, Err: err.Error()})
+		}
+	}()
+}
+
+func (r *Room) refundAsync(b *bet) {
+	if b.bot {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		req := &v1.RefundRequest{
+			PlayerId:         b.view.PlayerId,
+			RoundId:          b.roundId,
+			Currency:         b.view.Currency,
+			Bet:              b.view.Amount,
+			GameBrand:        string(r.opts.GameBrand),
+			GameId:           r.opts.GameId,
+			BetTransactionId: b.txId,
+		}
+		err := r.retry("refund "+b.txId, func(ctx context.Context) error {
+			reply, err := r.opts.Wallet.Refund(ctx, b.appId, req)
+			if err == nil && reply.Status != "NOTFOUND" {
+				r.betPlayer(b).SetBalanceByRefundReply(reply)
+			}
+			return err
+		})
+		if err != nil {
+			r.deadLetter(&FailedCall{Kind: FailedRefund, AppId: b.appId, Refund: req, Err: err.Error()})
+		}
+	}()
+}
+
+// 机器人的赢奖只在本局展示，不进入排行榜
+func (r *Room) recordTopWin(b feed.Bet) {
+	if r.opts.TopWins == nil || b.Win <= 0 || b.IsBot {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.WalletTimeout)
+		defer cancel()
+		if err := r.opts.TopWins.Record(ctx, string(r.opts.GameBrand), r.opts.GameId, b); err != nil {
+			r.log.Warnf("record top win %s failed: %v", b.Id, err)
+		}
+	}()
+}
+
+func (r *Room) reportAsync(roundId string, betTotal, profitTotal float64) {
+	if r.opts.Settler == nil {
+		return
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		err := r.retry("settle round "+roundId, func(ctx context.Context) error {
+			return r.opts.Settler.SettleRound(ctx, roundId, betTotal, profitTotal)
+		})
+		if err != nil {
+			r.deadLetter(&FailedCall{Kind: FailedSettle, RoundId: roundId, BetTotal: betTotal, ProfitTotal: profitTotal, Err: err.Error()})
+		}
+	}()
+}
+
+// 按walletRetryDelays重试，用尽或房间销毁后返回最后一次的错误
+func (r *Room) retry(name string, call func(ctx context.Context) error) error {
+	for i := 0; ; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), r.opts.WalletTimeout)
+		err := call(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if i >= len(walletRetryDelays) {
+			r.log.Errorf("%s failed after %d attempts: %v", name, i+1, err)
+			return err
+		}
+		r.log.Warnf("%s failed, retrying: %v", name, err)
+		if !r.sleep(walletRetryDelays[i]) {
+			r.log.Errorf("%s failed after %d attempts, room disposed: %v", name, i+1, err)
+			return err
+		}
+	}
+}
+
+// 保存重试用尽的调用，保存也失败时把完整内容打到日志里，以便从日志补单
+func (r *Room) deadLetter(call *FailedCall) {
+	call.GameId = r.opts.GameId
+	err := r.retry("dead letter "+call.Kind, func(ctx context.Context) error {
+		return r.opts.DeadLetter.Save(ctx, call)
+	})
+	if err != nil {
+		data, _ := json.Marshal(call)
+		r.log.Errorf("save dead letter failed: %v, call: %s", err, data)
+	}
+}
+
+// ========================================================================================
+// 消息
+
+func (r *Room) stateLocked() *Event {
+	ev := &Event{
+		Type:       EventState,
+		RoundId:    r.roundId,
+		Phase:      r.phase,
+		Multiplier: 1,
+		History:    append([]float64(nil), r.history...),
+	}
+	if r.fairRound != nil {
+		ev.HashedServerSeed = r.fairRound.HashedServerSeed
+	}
+	switch r.phase {
+	case PhaseBetting:
+		ev.TimeLeft = max(r.opts.BettingTime-r.now().Sub(r.phaseAt), 0)
+	case PhaseFlying:
+		ev.Multiplier = r.lastTick
+	case PhaseCrashed:
+		ev.Multiplier = r.crashPoint
+	}
+	for _, slots := range r.bets {
+		for _, b := range slots {
+			if b != nil && b.view.Status != BetPending && b.view.Status != BetRefunded {
+				view := b.view
+				ev.Bets = append(ev.Bets, &view)
+			}
+		}
+	}
+	return ev
+}
+
+func (r *Room) send(player types.PlayerImp, ev *Event) {
+	frame, err := r.opts.Serializer.Encode(player, ev)
+	if err != nil {
+		r.log.Errorf("encode event %d failed: %v", ev.Type, err)
+		return
+	}
+	if frame == nil {
+		return
+	}
+	if frame.Binary {
+		err = player.SendBinary(frame.Data)
+	} else {
+		err = player.SendString(string(frame.Data))
+	}
+	if err != nil {
+		r.log.Warnf("send to %s failed: %v", player.GetPlayerIdent(), err)
+	}
+}
+
+func (r *Room) snapshotPlayers(except types.PlayerImp) []types.PlayerImp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	players := make([]types.PlayerImp, 0, len(r.players)+len(r.spectators))
+	for _, p := range r.players {
+		if p != except {
+			players = append(players, p)
+		}
+	}
+	for _, p := range r.spectators {
+		if p != except {
+			players = append(players, p)
+		}
+	}
+	return players
+}
+
+func (r *Room) broadcast(ev *Event) {
+	r.broadcastExcept(nil, ev)
+}
+
+func (r *Room) broadcastExcept(except types.PlayerImp, ev *Event) {
+	for _, p := range r.snapshotPlayers(except) {
+		r.send(p, ev)
+	}
+}
+
+func (r *Room) broadcastAll(events []*Event) {
+	if len(events) == 0 {
+		return
+	}
+	players := r.snapshotPlayers(nil)
+	for _, ev := range events {
+		for _, p := range players {
+			r.send(p, ev)
+		}
+	}
+}
+
+var (
+	_ types.SpectatableRoomImp   = (*Room)(nil)
+	_ types.SeatExpirableRoomImp = (*Room)(nil)
+	_ types.DrainableRoomImp     = (*Room)(nil)
+)
+
+// 错误是否是玩家操作导致的(而不是系统错误)
+func IsUserError(err error) bool {
+	for _, e := range []error{ErrNotBettingPhase, ErrNotFlying, ErrTooLate, ErrBetExists, ErrBetNotFound, ErrInvalidBet, ErrInvalidIndex, ErrInvalidAuto, ErrSpectator} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,685 @@
+package crash
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	"github.com/card-engine/game_common/gamehub/bot"
+	"github.com/card-engine/game_common/gamehub/feed"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/player"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/qd2ss/sfs"
+)
+
+type testPlayer struct {
+	id string
+
+	mu       sync.Mutex
+	messages []string
+	wins     int // 收到的派奖回复
+}
+
+func (p *testPlayer) SetConn(conn *websocket.Conn)                    {}
+func (p *testPlayer) GetConn() *websocket.Conn                        { return nil }
+func (p *testPlayer) CloseConn()                                      {}
+func (p *testPlayer) IsConnect() bool                                 { return true }
+func (p *testPlayer) SetRoom(room types.RoomImp)                      {}
+func (p *testPlayer) GetRoom() types.RoomImp                          { return nil }
+func (p *testPlayer) ExitRoom(isDisconnect bool) error                { return nil }
+func (p *testPlayer) GetRoomManager() types.RoomManagerImp            { return nil }
+func (p *testPlayer) SetRoomManager(types.RoomManagerImp)             {}
+func (p *testPlayer) GetBalance() float64                             { return 0 }
+func (p *testPlayer) SetBalanceByBalanceReply(*v1.BalanceReply) error { return nil }
+func (p *testPlayer) SetBalanceByBetReply(*v1.BetReply) error         { return nil }
+func (p *testPlayer) SetBalanceByRefundReply(*v1.RefundReply) error   { return nil }
+func (p *testPlayer) GetPlayerIdent() string                          { return "app-" + p.id }
+func (p *testPlayer) GetPlayerInfo() *player.PlayerInfo               { return nil }
+func (p *testPlayer) GetPlayerId() string                             { return p.id }
+func (p *testPlayer) GetAppId() string                                { return "app" }
+func (p *testPlayer) GetCurrency() string                             { return "USD" }
+func (p *testPlayer) GetLang() string                                 { return "en" }
+func (p *testPlayer) GetRtpStr() string                               { return "97" }
+func (p *testPlayer) GetRtp() float64                                 { return 97 }
+
+func (p *testPlayer) SetBalanceByWinReply(*v1.WinReply) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wins++
+	return nil
+}
+
+func (p *testPlayer) SendString(msg string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func (p *testPlayer) SendBinary(data []byte) error {
+	return p.SendString(string(data))
+}
+
+func (p *testPlayer) last() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.messages) == 0 {
+		return ""
+	}
+	return p.messages[len(p.messages)-1]
+}
+
+type testWallet struct {
+	winBlock chan struct{} // 不为nil时派奖等待关闭
+
+	mu      sync.Mutex
+	betErr  error
+	winErr  error
+	bets    map[string]float64
+	wins    map[string]float64
+	refunds map[string]float64
+}
+
+func newTestWallet() *testWallet {
+	return &testWallet{bets: map[string]float64{}, wins: map[string]float64{}, refunds: map[string]float64{}}
+}
+
+func (w *testWallet) Bet(ctx context.Context, appId string, req *v1.BetRequest) (*v1.BetReply, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.betErr != nil {
+		return nil, w.betErr
+	}
+	w.bets[req.TransactionId] = req.Bet
+	return &v1.BetReply{}, nil
+}
+
+func (w *testWallet) Win(ctx context.Context, appId string, req *v1.WinRequest) (*v1.WinReply, error) {
+	if w.winBlock != nil {
+		<-w.winBlock
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.winErr != nil {
+		return nil, w.winErr
+	}
+	w.wins[req.BetTransactionId] = req.Win
+	return &v1.WinReply{}, nil
+}
+
+func (w *testWallet) Refund(ctx context.Context, appId string, req *v1.RefundRequest) (*v1.RefundReply, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.refunds[req.BetTransactionId] = req.Bet
+	return &v1.RefundReply{Status: "CANCELED"}, nil
+}
+
+type fixedCrash float64
+
+func (c fixedCrash) CrashPoint(ctx context.Context, roundId string) (float64, error) {
+	return float64(c), nil
+}
+
+type testSettler struct {
+	mu     sync.Mutex
+	err    error
+	totals map[string][2]float64
+}
+
+func (s *testSettler) SettleRound(ctx context.Context, roundId string, betTotal, profitTotal float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.totals[roundId] = [2]float64{betTotal, profitTotal}
+	return nil
+}
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time { return c.t }
+
+// 倍数恰好为m的飞行时间
+func (r *Room) timeFor(m float64) time.Duration {
+	return r.flightDuration(m) + time.Millisecond
+}
+
+func newTestRoom(t *testing.T, crash float64, serializer Serializer) (*Room, *testWallet, *testClock) {
+	t.Helper()
+	wallet := newTestWallet()
+	clock := &testClock{t: time.Unix(1700000000, 0)}
+	r := NewRoom(Options{
+		GameBrand:   types.GameBrand_Inout,
+		GameId:      "aviator",
+		Wallet:      wallet,
+		CrashSource: fixedCrash(crash),
+		Serializer:  serializer,
+		DeadLetter:  NewMemoryDeadLetter(),
+		NextRoundId: func(ctx context.Context) (string, error) { return "r1", nil },
+	})
+	r.now = clock.now
+	if err := r.startBetting(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.OnDispose)
+	return r, wallet, clock
+}
+
+func TestRoomMultiBetAndAutoCashout(t *testing.T) {
+	r, wallet, clock := newTestRoom(t, 3, InoutSerializer{})
+	settler := &testSettler{totals: map[string][2]float64{}}
+	r.opts.Settler = settler
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 0, Amount: 10, AutoCashout: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 1, Amount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 1, Amount: 5}); !errors.Is(err, ErrBetExists) {
+		t.Fatalf("expected ErrBetExists, got %v", err)
+	}
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 2, Amount: 5}); !errors.Is(err, ErrInvalidIndex) {
+		t.Fatalf("expected ErrInvalidIndex, got %v", err)
+	}
+
+	r.startFlying()
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 1, Amount: 5}); !errors.Is(err, ErrNotBettingPhase) {
+		t.Fatalf("expected ErrNotBettingPhase, got %v", err)
+	}
+
+	// tick跳过了1.5，自动提现仍然按1.5成交
+	clock.t = clock.t.Add(r.timeFor(1.8))
+	if r.tick(clock.t) {
+		t.Fatal("should not crash at 1.8")
+	}
+	if err := r.Cashout(p, &Command{Type: CmdCashout, Index: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Cashout(p, &Command{Type: CmdCashout, Index: 1}); !errors.Is(err, ErrBetNotFound) {
+		t.Fatalf("expected ErrBetNotFound, got %v", err)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	if !r.tick(clock.t) {
+		t.Fatal("should crash")
+	}
+	r.wg.Wait()
+
+	if wallet.wins["r1-app-p1-0"] != 15 || wallet.wins["r1-app-p1-1"] != 9 {
+		t.Fatalf("unexpected wins %v", wallet.wins)
+	}
+	if r.Phase() != PhaseCrashed {
+		t.Fatalf("phase %v", r.Phase())
+	}
+	// 下注15，派奖24
+	if settler.totals["r1"] != [2]float64{15, -9} {
+		t.Fatalf("unexpected settle %v", settler.totals)
+	}
+}
+
+func TestRoomCashoutRace(t *testing.T) {
+	r, wallet, clock := newTestRoom(t, 2, InoutSerializer{})
+	p1, p2 := &testPlayer{id: "p1"}, &testPlayer{id: "p2"}
+	r.OnJoin(p1)
+	r.OnJoin(p2)
+	r.Bet(p1, &Command{Type: CmdBet, Amount: 10})
+	r.Bet(p2, &Command{Type: CmdBet, Amount: 10, AutoCashout: 1.99})
+	r.startFlying()
+
+	// 已经超过坠机倍数但坠机的tick还没处理
+	clock.t = clock.t.Add(r.timeFor(2.01))
+	if err := r.Cashout(p1, &Command{Type: CmdCashout}); !errors.Is(err, ErrTooLate) {
+		t.Fatalf("expected ErrTooLate, got %v", err)
+	}
+	if !r.tick(clock.t) {
+		t.Fatal("should crash")
+	}
+	if err := r.Cashout(p1, &Command{Type: CmdCashout}); !errors.Is(err, ErrTooLate) {
+		t.Fatalf("expected ErrTooLate after crash, got %v", err)
+	}
+	r.wg.Wait()
+
+	// 自动提现低于坠机倍数的在坠机时成交
+	if wallet.wins["r1-app-p1-0"] != 0 || wallet.wins["r1-app-p2-0"] != 19.9 {
+		t.Fatalf("unexpected wins %v", wallet.wins)
+	}
+}
+
+// 提现倍数恰好等于坠机倍数算赢，与CrashCurve.SurvivalProbability和模拟器的口径一致
+func TestRoomCashoutAtCrashPoint(t *testing.T) {
+	r, wallet, clock := newTestRoom(t, 2, InoutSerializer{})
+	p1, p2, p3 := &testPlayer{id: "p1"}, &testPlayer{id: "p2"}, &testPlayer{id: "p3"}
+	r.OnJoin(p1)
+	r.OnJoin(p2)
+	r.OnJoin(p3)
+	r.Bet(p1, &Command{Type: CmdBet, Amount: 10})
+	r.Bet(p2, &Command{Type: CmdBet, Amount: 10, AutoCashout: 2})
+	r.Bet(p3, &Command{Type: CmdBet, Amount: 10, AutoCashout: 2.01})
+	r.startFlying()
+
+	clock.t = clock.t.Add(r.timeFor(2))
+	if err := r.Cashout(p1, &Command{Type: CmdCashout}); err != nil {
+		t.Fatalf("cashout at the crash point should win: %v", err)
+	}
+	if !r.tick(clock.t) {
+		t.Fatal("should crash")
+	}
+	r.wg.Wait()
+
+	if wallet.wins["r1-app-p1-0"] != 20 || wallet.wins["r1-app-p2-0"] != 20 || wallet.wins["r1-app-p3-0"] != 0 {
+		t.Fatalf("unexpected wins %v", wallet.wins)
+	}
+
+	// tick跳过了坠机倍数时，等于坠机倍数的自动提现同样成交
+	r2, wallet2, clock2 := newTestRoom(t, 2, InoutSerializer{})
+	r2.OnJoin(p2)
+	r2.Bet(p2, &Command{Type: CmdBet, Amount: 10, AutoCashout: 2})
+	r2.startFlying()
+	clock2.t = clock2.t.Add(time.Minute)
+	if !r2.tick(clock2.t) {
+		t.Fatal("should crash")
+	}
+	r2.wg.Wait()
+	if wallet2.wins["r1-app-p2-0"] != 20 {
+		t.Fatalf("unexpected wins %v", wallet2.wins)
+	}
+}
+
+func TestRoomRefund(t *testing.T) {
+	r, wallet, _ := newTestRoom(t, 2, InoutSerializer{})
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+
+	wallet.betErr = errors.New("timeout")
+	if err := r.Bet(p, &Command{Type: CmdBet, Amount: 10}); err == nil {
+		t.Fatal("expected wallet error")
+	}
+	r.wg.Wait()
+	if _, ok := wallet.refunds["r1-app-p1-0"]; !ok {
+		t.Fatal("failed bet should be refunded")
+	}
+
+	// 扣款失败后下注框可以重新使用
+	wallet.betErr = nil
+	if err := r.Bet(p, &Command{Type: CmdBet, Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Cancel(p, &Command{Type: CmdCancel}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 1, Amount: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 房间销毁时未结束的下注退款
+	r.OnDispose()
+	r.wg.Wait()
+	if wallet.refunds["r1-app-p1-1"] != 20 {
+		t.Fatalf("unexpected refunds %v", wallet.refunds)
+	}
+}
+
+// 房间销毁不等待重试中的钱包调用，销毁后不再重试直接保存到死信
+func TestRoomDisposeDuringRetry(t *testing.T) {
+	delays := walletRetryDelays
+	walletRetryDelays = []time.Duration{time.Hour}
+	t.Cleanup(func() { walletRetryDelays = delays })
+
+	r, wallet, clock := newTestRoom(t, 3, InoutSerializer{})
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+	r.Bet(p, &Command{Type: CmdBet, Amount: 10})
+	r.startFlying()
+	wallet.mu.Lock()
+	wallet.winErr = errors.New("wallet down")
+	wallet.mu.Unlock()
+	clock.t = clock.t.Add(r.timeFor(1.5))
+	if err := r.Cashout(p, &Command{Type: CmdCashout}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	r.OnDispose()
+	r.wg.Wait()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("dispose waited for retries: %v", elapsed)
+	}
+	calls := r.opts.DeadLetter.(*MemoryDeadLetter).Calls()
+	if len(calls) != 1 || calls[0].Kind != FailedWin || calls[0].Win.BetTransactionId != "r1-app-p1-0" {
+		t.Fatalf("pending win should be saved to dead letter: %+v", calls)
+	}
+}
+
+// 派奖进行中玩家重连，余额更新到新的玩家对象
+func TestRoomReconnectDuringSettle(t *testing.T) {
+	r, wallet, clock := newTestRoom(t, 3, InoutSerializer{})
+	wallet.winBlock = make(chan struct{})
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+	if err := r.Bet(p, &Command{Type: CmdBet, Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+	r.startFlying()
+	clock.t = clock.t.Add(r.timeFor(1.5))
+	if err := r.Cashout(p, &Command{Type: CmdCashout}); err != nil {
+		t.Fatal(err)
+	}
+
+	reconnected := &testPlayer{id: "p1"}
+	r.OnDisConnect(p)
+	r.OnReConnect(reconnected)
+	close(wallet.winBlock)
+	r.wg.Wait()
+
+	if wallet.wins["r1-app-p1-0"] != 15 {
+		t.Fatalf("unexpected wins %v", wallet.wins)
+	}
+	if p.wins != 0 || reconnected.wins != 1 {
+		t.Fatalf("balance should go to the reconnected player: old %d, new %d", p.wins, reconnected.wins)
+	}
+}
+
+// 重试用尽的派奖和库存上报保存到死信
+func TestRoomDeadLetter(t *testing.T) {
+	delays := walletRetryDelays
+	walletRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	t.Cleanup(func() { walletRetryDelays = delays })
+
+	r, wallet, clock := newTestRoom(t, 2, InoutSerializer{})
+	settler := &testSettler{err: errors.New("rtp down"), totals: map[string][2]float64{}}
+	r.opts.Settler = settler
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+	r.Bet(p, &Command{Type: CmdBet, Amount: 10, AutoCashout: 1.5})
+	r.startFlying()
+
+	wallet.mu.Lock()
+	wallet.winErr = errors.New("wallet down")
+	wallet.mu.Unlock()
+	clock.t = clock.t.Add(time.Minute)
+	r.tick(clock.t)
+	r.wg.Wait()
+
+	calls := r.opts.DeadLetter.(*MemoryDeadLetter).Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected win and settle in dead letter, got %d", len(calls))
+	}
+	byKind := map[string]*FailedCall{}
+	for _, call := range calls {
+		byKind[call.Kind] = call
+	}
+	win := byKind[FailedWin]
+	if win == nil || win.Win.BetTransactionId != "r1-app-p1-0" || win.Win.Win != 15 || win.AppId != "app" || win.GameId != "aviator" || win.Err != "wallet down" {
+		t.Fatalf("unexpected failed win %+v", win)
+	}
+	if settle := byKind[FailedSettle]; settle == nil || settle.RoundId != "r1" || settle.BetTotal != 10 || settle.ProfitTotal != -5 {
+		t.Fatalf("unexpected failed settle %+v", settle)
+	}
+}
+
+func TestNewRoomRequiresDeadLetter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic without DeadLetter")
+		}
+	}()
+	NewRoom(Options{GameId: "aviator", Wallet: newTestWallet(), CrashSource: fixedCrash(2), Serializer: InoutSerializer{}})
+}
+
+type testBots struct{ bets []*bot.Bet }
+
+func (b *testBots) RoundBets(maxBetsPerPlayer int) []*bot.Bet { return b.bets }
+
+func TestRoomBots(t *testing.T) {
+	r, wallet, clock := newTestRoom(t, 2, InoutSerializer{})
+	settler := &testSettler{totals: map[string][2]float64{}}
+	r.opts.Settler = settler
+	pool := bot.NewPool(&types.RtpRoomArgs{Appid: "app", Rtp: "97", Currency: "USD"}, bot.Config{MinBots: 1, MaxBots: 1})
+	b := pool.Bots()[0]
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+
+	if err := r.Bet(b, &Command{Type: CmdBet, Amount: 100, AutoCashout: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.last(), `"isBot":true`) || r.GetPlayerNum() != 1 {
+		t.Fatalf("bots should be flagged and not counted as players: %s", p.last())
+	}
+	r.Bet(p, &Command{Type: CmdBet, Amount: 10})
+	r.mu.Lock()
+	view := r.bets[b.GetPlayerIdent()][0].view
+	r.mu.Unlock()
+	if !view.IsBot || view.Nickname == "" || view.Avatar == "" {
+		t.Fatalf("unexpected bot bet %+v", view)
+	}
+
+	r.startFlying()
+	clock.t = clock.t.Add(time.Minute)
+	r.tick(clock.t)
+	r.wg.Wait()
+
+	// 机器人不访问钱包，也不计入库存
+	if len(wallet.bets) != 1 || len(wallet.wins) != 1 {
+		t.Fatalf("bot touched the wallet: %v %v", wallet.bets, wallet.wins)
+	}
+	if settler.totals["r1"] != [2]float64{10, 10} {
+		t.Fatalf("unexpected settle %v", settler.totals)
+	}
+}
+
+func TestRoomFeed(t *testing.T) {
+	r, _, clock := newTestRoom(t, 3, InoutSerializer{})
+	r.opts.TopWins = feed.NewMemoryTopWins()
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+	r.Bet(p, &Command{Type: CmdBet, Amount: 10, AutoCashout: 2})
+	r.Bet(p, &Command{Type: CmdBet, Index: 1, Amount: 5})
+	r.startFlying()
+	clock.t = clock.t.Add(time.Minute)
+	r.tick(clock.t)
+	r.wg.Wait()
+
+	r.OnMessage(p, &types.InoutMsgData{MsgId: "433", Action: "gameService-get-my-bets-history", Payload: "{}"})
+	if got := p.last(); !strings.HasPrefix(got, `433[[{"id":"r1-app-p1-0"`) || !strings.Contains(got, `"win":20`) {
+		t.Fatalf("unexpected my bets %s", got)
+	}
+
+	r.OnMessage(p, &types.InoutMsgData{MsgId: "434", Action: "get-top-wins", Payload: `{"kind":"multiplier","period":"month"}`})
+	if got := p.last(); !strings.HasPrefix(got, `434[[{"id":"r1-app-p1-0"`) || strings.Contains(got, "r1-app-p1-1") {
+		t.Fatalf("unexpected top wins %s", got)
+	}
+}
+
+func TestRoomTopWinsSkipBots(t *testing.T) {
+	r, _, clock := newTestRoom(t, 3, InoutSerializer{})
+	topWins := feed.NewMemoryTopWins()
+	r.opts.TopWins = topWins
+	pool := bot.NewPool(&types.RtpRoomArgs{Appid: "app", Rtp: "97", Currency: "USD"}, bot.Config{MinBots: 1, MaxBots: 1})
+	b := pool.Bots()[0]
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+	r.Bet(b, &Command{Type: CmdBet, Amount: 100, AutoCashout: 2.5})
+	r.Bet(p, &Command{Type: CmdBet, Amount: 10, AutoCashout: 2})
+	r.startFlying()
+	clock.t = clock.t.Add(time.Minute)
+	r.tick(clock.t)
+	r.wg.Wait()
+
+	wins, err := r.TopWins(feed.KindWin, feed.PeriodDay, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wins) != 1 || wins[0].Id != "r1-app-p1-0" {
+		t.Fatalf("bots should not enter top wins: %+v", wins)
+	}
+}
+
+func TestRoomSeatExpired(t *testing.T) {
+	r, wallet, clock := newTestRoom(t, 3, InoutSerializer{})
+	p1, p2 := &testPlayer{id: "p1"}, &testPlayer{id: "p2"}
+	r.OnJoin(p1)
+	r.OnJoin(p2)
+	r.Bet(p1, &Command{Type: CmdBet, Amount: 10})
+	r.Bet(p2, &Command{Type: CmdBet, Amount: 10})
+
+	// 下注阶段超时退款
+	r.OnDisConnect(p1)
+	r.OnSeatExpired(p1)
+	r.wg.Wait()
+	if wallet.refunds["r1-app-p1-0"] != 10 {
+		t.Fatalf("unexpected refunds %v", wallet.refunds)
+	}
+
+	// 飞行中超时按当前倍数提现
+	r.startFlying()
+	clock.t = clock.t.Add(r.timeFor(2))
+	r.OnDisConnect(p2)
+	r.OnSeatExpired(p2)
+	r.wg.Wait()
+	if wallet.wins["r1-app-p2-0"] != 20 {
+		t.Fatalf("unexpected wins %v", wallet.wins)
+	}
+}
+
+func TestRoomDrain(t *testing.T) {
+	wallet := newTestWallet()
+	r := NewRoom(Options{
+		GameBrand:   types.GameBrand_Inout,
+		GameId:      "aviator",
+		Wallet:      wallet,
+		CrashSource: fixedCrash(1.2),
+		Serializer:  InoutSerializer{},
+		DeadLetter:  NewMemoryDeadLetter(),
+		BettingTime: time.Minute,
+		NextRoundId: func(ctx context.Context) (string, error) { return "r1", nil },
+	}).Start()
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+
+	deadline := time.Now().Add(time.Second)
+	for r.Phase() != PhaseBetting {
+		if time.Now().After(deadline) {
+			t.Fatal("round did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := r.Bet(p, &Command{Type: CmdBet, Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 下注阶段排空立即停下，不再接受下注
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Bet(p, &Command{Type: CmdBet, Index: 1, Amount: 10}); !errors.Is(err, ErrRoomClosed) {
+		t.Fatalf("expected ErrRoomClosed, got %v", err)
+	}
+	if r.Phase() != PhaseBetting {
+		t.Fatalf("room should not fly after drain, phase %v", r.Phase())
+	}
+
+	r.OnDispose()
+	r.wg.Wait()
+	if wallet.refunds["r1-app-p1-0"] != 10 {
+		t.Fatalf("unexpected refunds %v", wallet.refunds)
+	}
+}
+
+type testSpectator struct {
+	testPlayer
+}
+
+func (p *testSpectator) IsSpectator() bool { return true }
+
+func TestRoomSpectator(t *testing.T) {
+	r, wallet, _ := newTestRoom(t, 2, InoutSerializer{})
+	p := &testPlayer{id: "p1"}
+	s := &testSpectator{testPlayer{id: "s1"}}
+	r.OnJoin(p)
+	if err := r.OnSpectate(s); err != nil {
+		t.Fatal(err)
+	}
+	if r.GetPlayerNum() != 1 || r.SpectatorNum() != 1 {
+		t.Fatalf("players %d, spectators %d", r.GetPlayerNum(), r.SpectatorNum())
+	}
+	if got := s.last(); !strings.HasPrefix(got, `42["onGameState"`) {
+		t.Fatalf("unexpected state %s", got)
+	}
+
+	r.OnMessage(s, &types.InoutMsgData{MsgId: "431", Action: "bet", Payload: `{"index":0,"amount":1.5}`})
+	if got, want := s.last(), `431[{"error":{"message":"crash: spectators can not bet"}}]`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if len(wallet.bets) != 0 {
+		t.Fatalf("spectator bet reached wallet %v", wallet.bets)
+	}
+
+	// 观战玩家收到其他玩家的下注广播
+	r.Bet(p, &Command{Type: CmdBet, Amount: 10})
+	if got := s.last(); !strings.HasPrefix(got, `42["onBet"`) {
+		t.Fatalf("unexpected broadcast %s", got)
+	}
+
+	r.OnSpectatorLeave(s)
+	if r.SpectatorNum() != 0 {
+		t.Fatal("spectator should leave")
+	}
+}
+
+func TestInoutSerializer(t *testing.T) {
+	r, _, _ := newTestRoom(t, 2, InoutSerializer{})
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+	if got := p.last(); !strings.HasPrefix(got, `42["onGameState",{"roundId":"r1","state":"betting"`) {
+		t.Fatalf("unexpected state %s", got)
+	}
+
+	r.OnMessage(p, &types.InoutMsgData{MsgId: "431", Action: "bet", Payload: `{"index":0,"amount":1.5}`})
+	if got, want := p.last(), `431[{"playerId":"p1","currency":"USD","index":0,"amount":1.5,"status":2}]`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	r.OnMessage(p, &types.InoutMsgData{MsgId: "432", Action: "withdraw", Payload: `{"index":0}`})
+	if got, want := p.last(), `432[{"error":{"message":"crash: not flying"}}]`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSpribeSerializer(t *testing.T) {
+	r, _, _ := newTestRoom(t, 2, SpribeSerializer{})
+	p := &testPlayer{id: "p1"}
+	r.OnJoin(p)
+
+	cmd, err := SpribeSerializer{}.Decode(map[string]interface{}{})
+	if cmd != nil || err != nil {
+		t.Fatal("non sfs message should be ignored")
+	}
+
+	r.OnMessage(p, sfsMessage("betHandler", 2, 3.5))
+	_, _, data, err := utils.Unpack([]byte(p.last()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["c"] != "betHandlerResponse" {
+		t.Fatalf("unexpected response %v", data)
+	}
+	if r.bets["app-p1"][1] == nil || r.bets["app-p1"][1].view.Amount != 3.5 {
+		t.Fatal("bet not placed in second slot")
+	}
+}
+
+func sfsMessage(cmd string, betId int32, amount float64) sfs.SFSObject {
+	return sfs.SFSObject{"c": cmd, "p": sfs.SFSObject{"betId": betId, "bet": amount}}
+}
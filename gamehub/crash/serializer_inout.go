@@ -0,0 +1,129 @@
+package crash
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/feed"
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// inout 的消息格式: 指令为gameService的action，回复 43x[...]，广播 42["event",...]
+type InoutSerializer struct{}
+
+type inoutBetPayload struct {
+	Index       int         `json:"index"`
+	Amount      float64     `json:"amount"`
+	AutoCashout float64     `json:"autoCashout"`
+	Kind        feed.Kind   `json:"kind"`
+	Period      feed.Period `json:"period"`
+}
+
+var inoutCommands = map[string]CommandType{
+	"get-game-state":                  CmdState,
+	"bet":                             CmdBet,
+	"cancel-bet":                      CmdCancel,
+	"withdraw":                        CmdCashout,
+	"change-auto-cashout":             CmdSetAuto,
+	"gameService-get-my-bets-history": CmdMyBets,
+	"get-top-wins":                    CmdTopWins,
+}
+
+func (InoutSerializer) Decode(data interface{}) (*Command, error) {
+	msg, ok := data.(*types.InoutMsgData)
+	if !ok {
+		return nil, nil
+	}
+	t, ok := inoutCommands[msg.Action]
+	if !ok {
+		return nil, nil
+	}
+
+	var p inoutBetPayload
+	if msg.Payload != "" {
+		if err := json.Unmarshal([]byte(msg.Payload), &p); err != nil {
+			return nil, err
+		}
+	}
+	return &Command{Type: t, ReplyId: msg.MsgId, Index: p.Index, Amount: p.Amount, AutoCashout: p.AutoCashout, Kind: p.Kind, Period: p.Period}, nil
+}
+
+type inoutState struct {
+	RoundId    string     `json:"roundId"`
+	State      string     `json:"state"`
+	Multiplier float64    `json:"multiplier"`
+	TimeLeft   int64      `json:"timeLeft,omitempty"` // 毫秒
+	Bets       []*BetView `json:"bets,omitempty"`
+	History    []float64  `json:"history,omitempty"`
+
+	HashedServerSeed string      `json:"hashedServerSeed,omitempty"` // 可证明公平时本局种子的承诺
+	Fairness         interface{} `json:"fairness,omitempty"`         // 坠机后公开的种子，格式同get-game-seeds
+}
+
+func (InoutSerializer) Encode(player types.PlayerImp, ev *Event) (*Frame, error) {
+	if ev.Type == EventError {
+		msgId := types.DefaultMsgId
+		if ev.Reply != nil && ev.Reply.ReplyId != "" {
+			msgId = ev.Reply.ReplyId
+		}
+		return inoutFrame(msgId, map[string]interface{}{"error": map[string]string{"message": ev.Err.Error()}})
+	}
+
+	var name string
+	var body interface{}
+	switch ev.Type {
+	case EventState, EventPhase:
+		name = "onGameState"
+		state := &inoutState{RoundId: ev.RoundId, State: ev.Phase.String(), Multiplier: ev.Multiplier, TimeLeft: ev.TimeLeft.Milliseconds(), HashedServerSeed: ev.HashedServerSeed}
+		if ev.Type == EventState {
+			state.Bets = ev.Bets
+			state.History = ev.History
+		}
+		if ev.Fairness != nil {
+			seeds, err := fairness.InoutFormatter{}.Format(ev.Fairness)
+			if err != nil {
+				return nil, err
+			}
+			state.Fairness = seeds
+		}
+		body = state
+	case EventTick:
+		name = "onMultiplier"
+		body = map[string]interface{}{"roundId": ev.RoundId, "multiplier": ev.Multiplier}
+	case EventBetPlaced:
+		name, body = "onBet", ev.Bet
+	case EventBetCanceled:
+		name, body = "onCancelBet", ev.Bet
+	case EventCashout:
+		name, body = "onWithdraw", ev.Bet
+	case EventMyBets:
+		name, body = "onMyBets", nonNilBets(ev.Feed)
+	case EventTopWins:
+		name, body = "onTopWins", nonNilBets(ev.Feed)
+	default:
+		return nil, nil
+	}
+
+	// 对指令的回复只带数据，广播带事件名
+	if ev.Reply != nil && ev.Reply.ReplyId != "" {
+		return inoutFrame(ev.Reply.ReplyId, body)
+	}
+	return inoutFrame(types.DefaultMsgId, name, body)
+}
+
+func inoutFrame(msgId string, data ...interface{}) (*Frame, error) {
+	buff, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Data: []byte(fmt.Sprintf("%s%s", msgId, buff))}, nil
+}
+
+// 没有数据时返回[]而不是null
+func nonNilBets(bets []feed.Bet) []feed.Bet {
+	if bets == nil {
+		return []feed.Bet{}
+	}
+	return bets
+}
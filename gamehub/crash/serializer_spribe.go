@@ -0,0 +1,214 @@
+package crash
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/feed"
+	"github.com/card-engine/game_common/gamehub/spribe"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/qd2ss/sfs"
+)
+
+// spribe 的消息格式: 指令为sfs扩展消息的c，回复 <c>Response，广播 changeState/x/updateCurrentBets 等
+type SpribeSerializer struct{}
+
+var spribeCommands = map[string]CommandType{
+	"currentBetsInfoHandler": CmdState,
+	"betHandler":             CmdBet,
+	"cancelBet":              CmdCancel,
+	"cashOutHandler":         CmdCashout,
+	"changeAutoCashOut":      CmdSetAuto,
+	"getMyBetsHistory":       CmdMyBets,
+	"getTopWins":             CmdTopWins,
+}
+
+func (SpribeSerializer) Decode(data interface{}) (*Command, error) {
+	obj, ok := data.(sfs.SFSObject)
+	if !ok {
+		return nil, nil
+	}
+	c, _ := obj["c"].(string)
+	t, ok := spribeCommands[c]
+	if !ok {
+		return nil, nil
+	}
+
+	cmd := &Command{Type: t, ReplyId: c}
+	if p, ok := obj["p"].(sfs.SFSObject); ok {
+		// 客户端的betId从1开始
+		if id := int(sfsNumber(p["betId"])); id > 0 {
+			cmd.Index = id - 1
+		}
+		cmd.Amount = sfsNumber(p["bet"])
+		cmd.AutoCashout = sfsNumber(p["autoCashOut"])
+		kind, _ := p["kind"].(string)
+		period, _ := p["period"].(string)
+		cmd.Kind, cmd.Period = feed.Kind(kind), feed.Period(period)
+	}
+	return cmd, nil
+}
+
+func sfsNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int8:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case int:
+		return float64(n)
+	}
+	return 0
+}
+
+func spribeErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNotBettingPhase):
+		return spribe.CodeNotBettingStage
+	case IsUserError(err):
+		return spribe.CodeInvalidParameter
+	}
+	return spribe.CodeSystemError
+}
+
+func spribeBet(b *BetView) sfs.SFSObject {
+	obj := sfs.SFSObject{
+		"player_id": b.PlayerId,
+		"currency":  b.Currency,
+		"betId":     int32(b.Index + 1),
+		"bet":       b.Amount,
+	}
+	if b.Nickname != "" {
+		obj["username"] = b.Nickname
+		obj["profileImage"] = b.Avatar
+	}
+	if b.IsBot {
+		obj["isBot"] = true
+	}
+	if b.Status == BetCashedOut {
+		obj["coeff"] = b.Multiplier
+		obj["winAmount"] = b.Win
+	}
+	return obj
+}
+
+func spribeFeedBet(b *feed.Bet) sfs.SFSObject {
+	obj := sfs.SFSObject{
+		"roundId":      b.RoundId,
+		"player_id":    b.PlayerId,
+		"username":     b.Nickname,
+		"profileImage": b.Avatar,
+		"currency":     b.Currency,
+		"bet":          b.Amount,
+		"payout":       b.Multiplier,
+		"win":          b.Win,
+		"endDate":      b.Time,
+	}
+	if b.IsBot {
+		obj["isBot"] = true
+	}
+	return obj
+}
+
+func (SpribeSerializer) Encode(player types.PlayerImp, ev *Event) (*Frame, error) {
+	var cmd string
+	var p sfs.SFSObject
+	switch {
+	case ev.Type == EventError:
+		cmd = "error"
+		if ev.Reply != nil {
+			cmd = ev.Reply.ReplyId + "Response"
+		}
+		code := spribeErrorCode(ev.Err)
+		p = sfs.SFSObject{
+			"code":    int32(code),
+			"message": spribe.GetErrorMessage(code, spribe.Language(player.GetLang())),
+		}
+	case ev.Type == EventState:
+		bets := make(sfs.SFSArray, 0, len(ev.Bets))
+		for _, b := range ev.Bets {
+			bets = append(bets, spribeBet(b))
+		}
+		history := make(sfs.SFSArray, 0, len(ev.History))
+		for _, x := range ev.History {
+			history = append(history, x)
+		}
+		cmd = "currentBetsInfoHandler"
+		if ev.Reply != nil {
+			cmd = ev.Reply.ReplyId + "Response"
+		}
+		p = sfs.SFSObject{
+			"roundId":    ev.RoundId,
+			"stageId":    int32(ev.Phase),
+			"x":          ev.Multiplier,
+			"timeLeft":   ev.TimeLeft.Milliseconds(),
+			"bets":       bets,
+			"roundsInfo": history,
+			"betsCount":  int32(len(bets)),
+		}
+	case ev.Type == EventPhase:
+		cmd = "changeState"
+		p = sfs.SFSObject{"newStateId": int32(ev.Phase), "roundId": ev.RoundId}
+		if ev.Phase == PhaseBetting {
+			p["timeLeft"] = ev.TimeLeft.Milliseconds()
+			if hashed, err := hex.DecodeString(ev.HashedServerSeed); err == nil && len(hashed) > 0 {
+				// 与公平性弹窗一致，使用base64url编码
+				p["serverSeedHash"] = base64.RawURLEncoding.EncodeToString(hashed)
+			}
+		}
+		if ev.Phase == PhaseCrashed {
+			p["crashX"] = ev.Multiplier
+			if ev.Fairness != nil {
+				seeds, err := fairness.SpribeFormatter{}.Format(ev.Fairness)
+				if err != nil {
+					return nil, err
+				}
+				p["fairness"] = seeds
+			}
+		}
+	case ev.Type == EventMyBets || ev.Type == EventTopWins:
+		bets := make(sfs.SFSArray, 0, len(ev.Feed))
+		for i := range ev.Feed {
+			bets = append(bets, spribeFeedBet(&ev.Feed[i]))
+		}
+		cmd = "getMyBetsHistoryResponse"
+		if ev.Reply != nil {
+			cmd = ev.Reply.ReplyId + "Response"
+		}
+		p = sfs.SFSObject{"bets": bets}
+	case ev.Type == EventTick:
+		cmd = "x"
+		p = sfs.SFSObject{"x": ev.Multiplier}
+	case ev.Reply != nil:
+		// 下注、取消、提现的回复
+		cmd = ev.Reply.ReplyId + "Response"
+		p = spribeBet(ev.Bet)
+	case ev.Type == EventBetPlaced || ev.Type == EventBetCanceled:
+		cmd = "updateCurrentBets"
+		p = sfs.SFSObject{"bets": sfs.SFSArray{spribeBet(ev.Bet)}, "canceled": ev.Type == EventBetCanceled}
+	case ev.Type == EventCashout:
+		cmd = "updateCurrentCashOuts"
+		p = sfs.SFSObject{"cashouts": sfs.SFSArray{spribeBet(ev.Bet)}}
+	default:
+		return nil, nil
+	}
+
+	buff, err := utils.PackCustomData(cmd, p)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Binary: true, Data: buff}, nil
+}
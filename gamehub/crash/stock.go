@@ -0,0 +1,43 @@
+package crash
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/card-engine/game_common/gamehub/utils"
+	"github.com/card-engine/game_common/gamehub/utils/stock"
+)
+
+// 不依赖rtp服务的坠机倍数来源，库存控制在本地进行，用于测试和模拟
+type LocalCrashSource struct {
+	controller *stock.Controller
+	key        stock.Key
+	rtp        float64
+	rng        *rand.Rand
+}
+
+// rng为空时使用全局随机数
+func NewLocalCrashSource(controller *stock.Controller, key stock.Key, rng *rand.Rand) *LocalCrashSource {
+	rtp, _ := strconv.ParseFloat(key.Rtp, 64)
+	return &LocalCrashSource{controller: controller, key: key, rtp: rtp, rng: rng}
+}
+
+func (s *LocalCrashSource) float64() float64 {
+	if s.rng != nil {
+		return s.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+func (s *LocalCrashSource) CrashPoint(ctx context.Context, roundId string) (float64, error) {
+	if x, ok := s.controller.Rates(s.key).Force(s.float64()); ok {
+		return x, nil
+	}
+	return utils.GetCrashCurve(s.rtp).Crash(s.rng), nil
+}
+
+func (s *LocalCrashSource) SettleRound(ctx context.Context, roundId string, betTotal, profitTotal float64) error {
+	s.controller.Settle(s.key, s.rtp, betTotal, profitTotal)
+	return nil
+}
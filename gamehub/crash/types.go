@@ -0,0 +1,252 @@
+// 飞机类(crash)游戏的通用房间: 下注 -> 飞行 -> 坠机 -> 结算 循环，
+// 游戏只需要提供钱包、坠机倍数来源和品牌的消息格式。
+package crash
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	"github.com/card-engine/game_common/gamehub/bot"
+	"github.com/card-engine/game_common/gamehub/chat"
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/feed"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type Phase int
+
+const (
+	PhaseBetting Phase = iota + 1 // 下注阶段
+	PhaseFlying                   // 飞行中，可以提现
+	PhaseCrashed                  // 已坠机，结算并展示结果
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseBetting:
+		return "betting"
+	case PhaseFlying:
+		return "flying"
+	case PhaseCrashed:
+		return "crashed"
+	}
+	return "unknown"
+}
+
+type BetStatus int
+
+const (
+	BetPending   BetStatus = iota + 1 // 钱包扣款中
+	BetPlaced                         // 已下注
+	BetCashedOut                      // 已提现
+	BetLost                           // 坠机未提现
+	BetRefunded                       // 已退款
+)
+
+var (
+	ErrNotBettingPhase = errors.New("crash: not in betting phase")
+	ErrNotFlying       = errors.New("crash: not flying")
+	ErrTooLate         = errors.New("crash: already crashed")
+	ErrBetExists       = errors.New("crash: bet already placed")
+	ErrBetNotFound     = errors.New("crash: bet not found")
+	ErrInvalidBet      = errors.New("crash: invalid bet amount")
+	ErrInvalidIndex    = errors.New("crash: invalid bet index")
+	ErrInvalidAuto     = errors.New("crash: invalid auto cashout")
+	ErrRoomClosed      = errors.New("crash: room closed")
+	ErrSpectator       = errors.New("crash: spectators can not bet")
+)
+
+// 钱包，默认实现为GrpcWallet
+type Wallet interface {
+	Bet(ctx context.Context, appId string, req *v1.BetRequest) (*v1.BetReply, error)
+	Win(ctx context.Context, appId string, req *v1.WinRequest) (*v1.WinReply, error)
+	Refund(ctx context.Context, appId string, req *v1.RefundRequest) (*v1.RefundReply, error)
+}
+
+// 每一局的坠机倍数，默认实现为GrpcCrashSource
+type CrashSource interface {
+	CrashPoint(ctx context.Context, roundId string) (float64, error)
+}
+
+// 每局结束后上报本局下注总额和平台盈利(下注 - 派奖)，用于库存控制
+type Settler interface {
+	SettleRound(ctx context.Context, roundId string, betTotal, profitTotal float64) error
+}
+
+// 每局的机器人下注，默认实现为bot.Pool
+type BotSource interface {
+	RoundBets(maxBetsPerPlayer int) []*bot.Bet
+}
+
+type CommandType int
+
+const (
+	CmdState   CommandType = iota + 1 // 获取当前状态
+	CmdBet                            // 下注
+	CmdCancel                         // 取消下注
+	CmdCashout                        // 提现
+	CmdSetAuto                        // 修改自动提现倍数
+	CmdMyBets                         // 自己最近的下注
+	CmdTopWins                        // 最高赢奖排行榜
+)
+
+// 玩家发来的指令，由Serializer从品牌消息中解析
+type Command struct {
+	Type        CommandType
+	ReplyId     string      // 回复时需要带回的消息id(inout的43x)
+	Index       int         // 第几个下注框，从0开始
+	Amount      float64     // 下注金额
+	AutoCashout float64     // 自动提现倍数，0表示不自动提现
+	Kind        feed.Kind   // 排行榜排序方式，CmdTopWins使用
+	Period      feed.Period // 排行榜时间段，CmdTopWins使用
+}
+
+type EventType int
+
+const (
+	EventState       EventType = iota + 1 // 当前完整状态，进房/重连/请求时发给玩家
+	EventPhase                            // 阶段切换，广播
+	EventTick                             // 飞行倍数，广播
+	EventBetPlaced                        // 下注成功，发给玩家并广播
+	EventBetCanceled                      // 取消下注，发给玩家并广播
+	EventCashout                          // 提现成功，发给玩家并广播
+	EventError                            // 指令失败，发给玩家
+	EventMyBets                           // 自己最近的下注，发给玩家
+	EventTopWins                          // 排行榜，发给玩家
+)
+
+// 对外展示的一笔下注
+type BetView struct {
+	PlayerId    string    `json:"playerId"`
+	Nickname    string    `json:"nickname,omitempty"`
+	Avatar      string    `json:"avatar,omitempty"`
+	IsBot       bool      `json:"isBot,omitempty"` // 机器人的下注，不涉及钱包
+	Currency    string    `json:"currency"`
+	Index       int       `json:"index"`
+	Amount      float64   `json:"amount"`
+	AutoCashout float64   `json:"autoCashout,omitempty"`
+	Status      BetStatus `json:"status"`
+	Multiplier  float64   `json:"multiplier,omitempty"` // 提现倍数
+	Win         float64   `json:"win,omitempty"`
+}
+
+type Event struct {
+	Type       EventType
+	RoundId    string
+	Phase      Phase
+	Multiplier float64       // 当前倍数，坠机后为坠机倍数
+	TimeLeft   time.Duration // 下注阶段剩余时间
+	Bet        *BetView      // 下注/提现相关的事件
+	Bets       []*BetView    // 本局所有下注，EventState使用
+	History    []float64     // 最近几局的坠机倍数，EventState使用
+	Feed       []feed.Bet    // EventMyBets、EventTopWins使用
+	Reply      *Command      // 对某个指令的回复
+	Err        error
+
+	HashedServerSeed string           // 可证明公平时本局种子的承诺，PhaseBetting和EventState使用
+	Fairness         *fairness.Record // 可证明公平时坠机后公开的本局种子，PhaseCrashed使用
+}
+
+// 发给客户端的一帧数据
+type Frame struct {
+	Binary bool
+	Data   []byte
+}
+
+// 品牌消息格式
+type Serializer interface {
+	Decode(data interface{}) (*Command, error)
+	// 返回nil表示这个品牌不需要发送该事件
+	Encode(player types.PlayerImp, ev *Event) (*Frame, error)
+}
+
+type Options struct {
+	GameBrand types.GameBrand
+	GameId    string
+	AppId     string
+	Rtp       string
+	Currency  string
+
+	Wallet      Wallet
+	CrashSource CrashSource
+	Serializer  Serializer
+	// 必须设置，钱包调用重试用尽后保存到这里补发
+	DeadLetter DeadLetter
+	// 不为空时代替CrashSource决定坠机倍数，结果可以公开校验
+	Fairness *FairCrashSource
+	// 为空时如果CrashSource实现了Settler则使用CrashSource
+	Settler Settler
+	// 为空时没有机器人
+	Bots BotSource
+	// 为空时不记录排行榜
+	TopWins feed.TopWinsStore
+	// 为空时没有聊天
+	Chat *chat.Channel
+	// 生成局号，为空时使用本地自增
+	NextRoundId func(ctx context.Context) (string, error)
+
+	BettingTime      time.Duration // 下注阶段时长
+	CrashedTime      time.Duration // 坠机后展示结果的时长
+	TickInterval     time.Duration // 飞行倍数广播间隔
+	GrowthRate       float64       // 倍数增长速度，multiplier = e^(GrowthRate * 毫秒)
+	MaxBetsPerPlayer int           // 每个玩家每局最多几个下注框
+	MinBet           float64
+	MaxBet           float64
+	MaxAutoCashout   float64
+	HistorySize      int // 保留最近几局的坠机倍数
+	MyBetsSize       int // 查询自己的下注时最多返回几条
+	WalletTimeout    time.Duration
+
+	Logger log.Logger
+}
+
+const (
+	DefaultBettingTime      = 5 * time.Second
+	DefaultCrashedTime      = 3 * time.Second
+	DefaultTickInterval     = 100 * time.Millisecond
+	DefaultGrowthRate       = 0.00006 // 约11.5秒到2倍
+	DefaultMaxBetsPerPlayer = 2
+	DefaultMaxAutoCashout   = 10000
+	DefaultHistorySize      = 50
+	DefaultMyBetsSize       = 20
+	DefaultWalletTimeout    = 5 * time.Second
+)
+
+func (o *Options) normalize() {
+	if o.BettingTime <= 0 {
+		o.BettingTime = DefaultBettingTime
+	}
+	if o.CrashedTime <= 0 {
+		o.CrashedTime = DefaultCrashedTime
+	}
+	if o.TickInterval <= 0 {
+		o.TickInterval = DefaultTickInterval
+	}
+	if o.GrowthRate <= 0 {
+		o.GrowthRate = DefaultGrowthRate
+	}
+	if o.MaxBetsPerPlayer <= 0 {
+		o.MaxBetsPerPlayer = DefaultMaxBetsPerPlayer
+	}
+	if o.MaxAutoCashout <= 0 {
+		o.MaxAutoCashout = DefaultMaxAutoCashout
+	}
+	if o.HistorySize <= 0 {
+		o.HistorySize = DefaultHistorySize
+	}
+	if o.MyBetsSize <= 0 {
+		o.MyBetsSize = DefaultMyBetsSize
+	}
+	if o.WalletTimeout <= 0 {
+		o.WalletTimeout = DefaultWalletTimeout
+	}
+	if o.Settler == nil {
+		o.Settler, _ = o.CrashSource.(Settler)
+	}
+	if o.Logger == nil {
+		o.Logger = log.GetLogger()
+	}
+}
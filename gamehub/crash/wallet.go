@@ -0,0 +1,52 @@
+package crash
+
+import (
+	"context"
+
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	client_utils "github.com/card-engine/game_common/api/game/v1/client"
+	"github.com/card-engine/game_common/gamehub/utils"
+	google_grpc "google.golang.org/grpc"
+)
+
+// 通过game api的grpc接口访问钱包
+type GrpcWallet struct {
+	conn *google_grpc.ClientConn
+}
+
+func NewGrpcWallet(conn *google_grpc.ClientConn) *GrpcWallet {
+	return &GrpcWallet{conn: conn}
+}
+
+func (w *GrpcWallet) Bet(ctx context.Context, appId string, req *v1.BetRequest) (*v1.BetReply, error) {
+	return client_utils.Bet(ctx, w.conn, appId, req)
+}
+
+func (w *GrpcWallet) Win(ctx context.Context, appId string, req *v1.WinRequest) (*v1.WinReply, error) {
+	return client_utils.Win(ctx, w.conn, appId, req)
+}
+
+func (w *GrpcWallet) Refund(ctx context.Context, appId string, req *v1.RefundRequest) (*v1.RefundReply, error) {
+	return client_utils.Refund(ctx, w.conn, appId, req)
+}
+
+// 通过rtp服务计算坠机倍数并上报每局结果，见utils.CalculateCrashX和utils.SettleCrashRound
+type GrpcCrashSource struct {
+	conn      *google_grpc.ClientConn
+	appId     string
+	gameBrand string
+	gameId    string
+	rtp       string
+}
+
+func NewGrpcCrashSource(conn *google_grpc.ClientConn, appId, gameBrand, gameId, rtp string) *GrpcCrashSource {
+	return &GrpcCrashSource{conn: conn, appId: appId, gameBrand: gameBrand, gameId: gameId, rtp: rtp}
+}
+
+func (s *GrpcCrashSource) CrashPoint(ctx context.Context, roundId string) (float64, error) {
+	return utils.CalculateCrashX(s.conn, s.appId, s.gameBrand, s.gameId, roundId, s.rtp)
+}
+
+func (s *GrpcCrashSource) SettleRound(ctx context.Context, roundId string, betTotal, profitTotal float64) error {
+	return utils.SettleCrashRound(s.conn, s.appId, s.gameBrand, s.gameId, roundId, s.rtp, betTotal, profitTotal)
+}
@@ -1,3 +1,8 @@
 package event
 
 type PingPongEvent struct{}
+
+// 断线保留座位超时
+type SeatExpireEvent struct {
+	PlayerIdent string
+}
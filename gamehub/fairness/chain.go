@@ -0,0 +1,280 @@
+package fairness
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 可证明公平的种子链(commit/reveal):
+//
+//	s_0     = HMAC-SHA256(secret, "chain:<gameId>:<chainId>")
+//	s_{k+1} = SHA256(s_k)
+//
+// 一条链长度为L，第n局(nonce从1开始)使用 s_{L-n} 作为ServerSeed，链的承诺为 s_L。
+// 开局前公布 HashedServerSeed = SHA256(ServerSeed)，它等于上一局的ServerSeed(第一局为链的承诺)，
+// 因此任何人都可以验证每一局公开的种子都连在同一条链上，而在公开之前无法推算出后面的种子。
+// 结果 = HMAC-SHA512(ServerSeed, clientSeeds + nonce)，结算后公开ServerSeed。
+const (
+	DefaultChainLength     = 1_000_000
+	DefaultChainCheckpoint = 1000
+
+	// 当前链剩余不到1/chainPrefetchRatio时在后台展开下一条链
+	chainPrefetchRatio = 10
+)
+
+var ErrSeedAlreadyRevealed = errors.New("fairness: server seed already revealed")
+
+type ChainConfig struct {
+	// 链的密钥，只保存在服务端配置中，泄露后需要更换
+	Secret []byte
+	// 链长度，用完后自动切换到下一条链
+	Length int64
+	// 每隔多少个种子缓存一个检查点，用于快速推算任意位置的种子
+	Checkpoint int64
+}
+
+// 一条已经展开的链，只缓存检查点
+type chain struct {
+	id          int64
+	length      int64
+	step        int64
+	checkpoints [][]byte // checkpoints[i] = s_{i*step}
+	terminal    []byte   // s_L
+}
+
+func newChain(secret []byte, gameId string, chainId, length, step int64) *chain {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fmt.Sprintf("chain:%s:%d", gameId, chainId)))
+	seed := mac.Sum(nil)
+
+	c := &chain{id: chainId, length: length, step: step}
+	for k := int64(0); k < length; k++ {
+		if k%step == 0 {
+			c.checkpoints = append(c.checkpoints, seed)
+		}
+		next := sha256.Sum256(seed)
+		seed = next[:]
+	}
+	c.terminal = seed
+	return c
+}
+
+// 正在展开或已经展开的链，ready关闭后c可用
+type chainEntry struct {
+	ready chan struct{}
+	c     *chain
+}
+
+// s_k
+func (c *chain) seedAt(k int64) []byte {
+	seed := c.checkpoints[k/c.step]
+	for i := (k / c.step) * c.step; i < k; i++ {
+		next := sha256.Sum256(seed)
+		seed = next[:]
+	}
+	return seed
+}
+
+type Engine struct {
+	gameId string
+	store  ChainStore
+	cfg    ChainConfig
+
+	mu     sync.Mutex
+	chains map[int64]*chainEntry // 只保留最近使用的链
+}
+
+func NewEngine(gameId string, store ChainStore, cfg ChainConfig) (*Engine, error) {
+	if gameId == "" {
+		return nil, errors.New("fairness: gameId is empty")
+	}
+	if store == nil {
+		return nil, errors.New("fairness: chain store is nil")
+	}
+	if len(cfg.Secret) < 16 {
+		return nil, errors.New("fairness: chain secret must be at least 16 bytes")
+	}
+	if cfg.Length <= 0 {
+		cfg.Length = DefaultChainLength
+	}
+	if cfg.Checkpoint <= 0 {
+		cfg.Checkpoint = DefaultChainCheckpoint
+	}
+	return &Engine{gameId: gameId, store: store, cfg: cfg, chains: make(map[int64]*chainEntry)}, nil
+}
+
+func (e *Engine) GameId() string {
+	return e.gameId
+}
+
+// 展开一条链需要计算Length次SHA256，在锁外进行，同一条链的其它调用方等待展开完成
+func (e *Engine) chain(chainId int64) *chain {
+	entry, created := e.chainEntry(chainId)
+	if created {
+		e.buildChain(chainId, entry)
+	}
+	<-entry.ready
+	return entry.c
+}
+
+// 在后台展开下一条链，切链时不用等待
+func (e *Engine) prefetchChain(chainId int64) {
+	if entry, created := e.chainEntry(chainId); created {
+		go e.buildChain(chainId, entry)
+	}
+}
+
+func (e *Engine) chainEntry(chainId int64) (*chainEntry, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if entry, ok := e.chains[chainId]; ok {
+		return entry, false
+	}
+	// 切链时丢弃更早的链
+	for id := range e.chains {
+		if id < chainId-1 {
+			delete(e.chains, id)
+		}
+	}
+	entry := &chainEntry{ready: make(chan struct{})}
+	e.chains[chainId] = entry
+	return entry, true
+}
+
+func (e *Engine) buildChain(chainId int64, entry *chainEntry) {
+	entry.c = newChain(e.cfg.Secret, e.gameId, chainId, e.cfg.Length, e.cfg.Checkpoint)
+	close(entry.ready)
+}
+
+// 链的承诺(s_L)，链启用前公布
+func (e *Engine) ChainCommitment(chainId int64) string {
+	return hex.EncodeToString(e.chain(chainId).terminal)
+}
+
+// 开始新的一局，位置由store原子分配，重启后也不会重复使用种子
+func (e *Engine) NextRound(ctx context.Context) (*Round, error) {
+	chainId, nonce, err := e.store.Next(ctx, e.gameId, e.cfg.Length)
+	if err != nil {
+		return nil, err
+	}
+	if nonce < 1 || nonce > e.cfg.Length {
+		return nil, fmt.Errorf("fairness: nonce %d out of chain length %d", nonce, e.cfg.Length)
+	}
+	if e.cfg.Length-nonce <= e.cfg.Length/chainPrefetchRatio {
+		e.prefetchChain(chainId + 1)
+	}
+	return e.round(chainId, nonce), nil
+}
+
+func (e *Engine) round(chainId, nonce int64) *Round {
+	c := e.chain(chainId)
+	seed := c.seedAt(c.length - nonce)
+	hashed := sha256.Sum256(seed)
+	return &Round{
+		GameId:           e.gameId,
+		ChainId:          chainId,
+		Nonce:            nonce,
+		HashedServerSeed: hex.EncodeToString(hashed[:]),
+		ChainCommitment:  hex.EncodeToString(c.terminal),
+		serverSeed:       hex.EncodeToString(seed),
+	}
+}
+
+// 一局的种子，ServerSeed在Reveal之前不对外
+type Round struct {
+	GameId           string `json:"game_id"`
+	ChainId          int64  `json:"chain_id"`
+	Nonce            int64  `json:"nonce"`
+	HashedServerSeed string `json:"hashed_server_seed"` // 开局前公布的承诺
+	ChainCommitment  string `json:"chain_commitment"`
+
+	mu         sync.Mutex
+	serverSeed string
+	outcome    *Outcome
+	revealed   bool
+}
+
+// 停止下注时用收集到的客户端种子计算本局结果，同一局只计算一次
+func (r *Round) Outcome(clientSeeds []string) (Outcome, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.outcome != nil {
+		return *r.outcome, nil
+	}
+	if r.revealed {
+		return Outcome{}, ErrSeedAlreadyRevealed
+	}
+	outcome := Verify(r.serverSeed, clientSeeds, r.Nonce)
+	r.outcome = &outcome
+	return outcome, nil
+}
+
+// 结算后公开ServerSeed
+func (r *Round) Reveal() RevealedRound {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revealed = true
+	revealed := RevealedRound{
+		GameId:           r.GameId,
+		ChainId:          r.ChainId,
+		Nonce:            r.Nonce,
+		ServerSeed:       r.serverSeed,
+		HashedServerSeed: r.HashedServerSeed,
+		ChainCommitment:  r.ChainCommitment,
+	}
+	if r.outcome != nil {
+		revealed.ClientSeeds = r.outcome.ClientSeeds
+		revealed.Outcome = r.outcome
+	}
+	return revealed
+}
+
+type RevealedRound struct {
+	GameId           string   `json:"game_id"`
+	ChainId          int64    `json:"chain_id"`
+	Nonce            int64    `json:"nonce"`
+	ServerSeed       string   `json:"server_seed"`
+	HashedServerSeed string   `json:"hashed_server_seed"`
+	ChainCommitment  string   `json:"chain_commitment"`
+	ClientSeeds      []string `json:"client_seeds"`
+	Outcome          *Outcome `json:"outcome,omitempty"`
+}
+
+type Outcome struct {
+	Nonce       int64    `json:"nonce"`
+	ClientSeeds []string `json:"client_seeds"`
+	Hash        string   `json:"hash"`    // HMAC-SHA512(ServerSeed, message)的hex
+	Decimal     uint64   `json:"decimal"` // hash前13个hex字符(52位)
+	Float       float64  `json:"float"`   // Decimal / 2^52，均匀分布在[0,1)
+}
+
+// message = clientSeed1:clientSeed2:...:nonce，key为hex形式的ServerSeed字符串，方便玩家用通用工具复算
+func outcomeMessage(clientSeeds []string, nonce int64) string {
+	parts := append(append([]string(nil), clientSeeds...), strconv.FormatInt(nonce, 10))
+	return strings.Join(parts, ":")
+}
+
+// 由公开的ServerSeed、客户端种子和nonce复算结果，不依赖任何服务端状态，玩家和运营方都可以独立验证
+func Verify(serverSeed string, clientSeeds []string, nonce int64) Outcome {
+	mac := hmac.New(sha512.New, []byte(serverSeed))
+	mac.Write([]byte(outcomeMessage(clientSeeds, nonce)))
+	sum := mac.Sum(nil)
+
+	decimal := binary.BigEndian.Uint64(sum[:8]) >> 12
+	return Outcome{
+		Nonce:       nonce,
+		ClientSeeds: append([]string(nil), clientSeeds...),
+		Hash:        hex.EncodeToString(sum),
+		Decimal:     decimal,
+		Float:       float64(decimal) / float64(uint64(1)<<52),
+	}
+}
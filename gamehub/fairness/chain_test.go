@@ -0,0 +1,154 @@
+package fairness
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testEngine(t *testing.T, store ChainStore) *Engine {
+	t.Helper()
+	e, err := NewEngine("aviator", store, ChainConfig{
+		Secret:     []byte("0123456789abcdef-test"),
+		Length:     50,
+		Checkpoint: 7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func sha256Hex(t *testing.T, seedHex string) string {
+	t.Helper()
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(seed)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestEngineChainLinks(t *testing.T) {
+	ctx := context.Background()
+	e := testEngine(t, NewMemoryChainStore())
+
+	// 第一局的承诺就是链的承诺，之后每局的承诺等于上一局公开的种子
+	prev := e.ChainCommitment(0)
+	seen := make(map[string]struct{})
+	for i := 1; i <= 120; i++ {
+		round, err := e.NextRound(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if round.Nonce == 1 {
+			prev = round.ChainCommitment
+			if prev != e.ChainCommitment(round.ChainId) {
+				t.Fatalf("chain commitment mismatch at chain %d", round.ChainId)
+			}
+		}
+		if round.HashedServerSeed != prev {
+			t.Fatalf("round %d/%d commitment does not link to previous seed", round.ChainId, round.Nonce)
+		}
+		revealed := round.Reveal()
+		if sha256Hex(t, revealed.ServerSeed) != round.HashedServerSeed {
+			t.Fatalf("revealed seed does not match commitment")
+		}
+		if _, ok := seen[revealed.ServerSeed]; ok {
+			t.Fatalf("server seed reused at %d/%d", round.ChainId, round.Nonce)
+		}
+		seen[revealed.ServerSeed] = struct{}{}
+		prev = revealed.ServerSeed
+	}
+}
+
+func TestEngineResumeFromStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryChainStore()
+
+	first := testEngine(t, store)
+	r1, _ := first.NextRound(ctx)
+
+	// 模拟重启: 新的Engine共享同一个store，继续往后使用
+	second := testEngine(t, store)
+	r2, _ := second.NextRound(ctx)
+	if r2.Nonce != r1.Nonce+1 || r2.HashedServerSeed != r1.Reveal().ServerSeed {
+		t.Fatalf("restart should continue the chain: r1=%d r2=%d", r1.Nonce, r2.Nonce)
+	}
+}
+
+func TestRoundOutcome(t *testing.T) {
+	e := testEngine(t, NewMemoryChainStore())
+	round, err := e.NextRound(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := []string{"a1", "b2", "c3"}
+	out, err := round.Outcome(seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Float < 0 || out.Float >= 1 || len(out.Hash) != 128 {
+		t.Fatalf("bad outcome: %+v", out)
+	}
+	// 结果只计算一次，后续传入不同的客户端种子也不会改变
+	again, _ := round.Outcome([]string{"x"})
+	if again.Hash != out.Hash {
+		t.Fatal("outcome changed after first computation")
+	}
+	revealed := round.Reveal()
+	if revealed.Outcome == nil || Verify(revealed.ServerSeed, seeds, round.Nonce).Hash != out.Hash {
+		t.Fatal("outcome cannot be recomputed from revealed seed")
+	}
+
+	other, _ := e.NextRound(context.Background())
+	other.Reveal()
+	if _, err := other.Outcome(seeds); !errors.Is(err, ErrSeedAlreadyRevealed) {
+		t.Fatalf("expected ErrSeedAlreadyRevealed, got %v", err)
+	}
+}
+
+func TestEnginePrefetchNextChain(t *testing.T) {
+	ctx := context.Background()
+	e := testEngine(t, NewMemoryChainStore())
+
+	next := func() *chainEntry {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return e.chains[1]
+	}
+	// 长度50，剩余5局时开始展开下一条链
+	for i := 1; i < 45; i++ {
+		if _, err := e.NextRound(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if next() != nil {
+		t.Fatal("next chain should not be built yet")
+	}
+	if _, err := e.NextRound(ctx); err != nil {
+		t.Fatal(err)
+	}
+	entry := next()
+	if entry == nil {
+		t.Fatal("next chain should be prefetched")
+	}
+	select {
+	case <-entry.ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prefetch did not finish")
+	}
+
+	// 切链时使用预先展开的链
+	for i := 45; i <= 50; i++ {
+		if _, err := e.NextRound(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e.chain(1) != entry.c {
+		t.Fatal("switching chain should reuse the prefetched chain")
+	}
+}
@@ -0,0 +1,126 @@
+package fairness
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	MaxClientSeedLength = 64
+
+	redisClientSeedKeyPrefix = "fairness:client_seed:"
+	// 玩家长时间不玩之后客户端种子过期，下次会重新生成
+	redisClientSeedTTL = 30 * 24 * time.Hour
+)
+
+var ErrInvalidClientSeed = errors.New("fairness: client seed must be 1-64 characters of [0-9A-Za-z_-]")
+
+// 玩家的客户端种子，key为玩家唯一标识(PlayerImp.GetPlayerIdent)
+type ClientSeedStore interface {
+	// 获取玩家当前的客户端种子，没有时生成一个并保存
+	Get(ctx context.Context, playerIdent string) (string, error)
+	// 玩家自己设置客户端种子
+	Set(ctx context.Context, playerIdent, seed string) error
+}
+
+// 客户端种子会拼接到结果的message中，只允许不含分隔符的字符
+func ValidateClientSeed(seed string) error {
+	if len(seed) == 0 || len(seed) > MaxClientSeedLength {
+		return ErrInvalidClientSeed
+	}
+	for i := 0; i < len(seed); i++ {
+		c := seed[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-') {
+			return ErrInvalidClientSeed
+		}
+	}
+	return nil
+}
+
+// 随机生成一个客户端种子
+func NewClientSeed() string {
+	b := make([]byte, 10)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// 换一个新的随机客户端种子
+func RotateClientSeed(ctx context.Context, store ClientSeedStore, playerIdent string) (string, error) {
+	seed := NewClientSeed()
+	if err := store.Set(ctx, playerIdent, seed); err != nil {
+		return "", err
+	}
+	return seed, nil
+}
+
+// 按玩家顺序取出客户端种子，用于计算一局的结果(Round.Outcome)
+func ClientSeedsOf(ctx context.Context, store ClientSeedStore, playerIdents []string) ([]string, error) {
+	seeds := make([]string, 0, len(playerIdents))
+	for _, playerIdent := range playerIdents {
+		seed, err := store.Get(ctx, playerIdent)
+		if err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, seed)
+	}
+	return seeds, nil
+}
+
+type RedisClientSeedStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisClientSeedStore(rdb *redis.Client) *RedisClientSeedStore {
+	return &RedisClientSeedStore{rdb: rdb}
+}
+
+func (s *RedisClientSeedStore) Get(ctx context.Context, playerIdent string) (string, error) {
+	key := redisClientSeedKeyPrefix + playerIdent
+	seed, err := s.rdb.Get(ctx, key).Result()
+	if err == nil {
+		return seed, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+	// 并发时以先写入的为准
+	if _, err := s.rdb.SetNX(ctx, key, NewClientSeed(), redisClientSeedTTL).Result(); err != nil {
+		return "", err
+	}
+	return s.rdb.Get(ctx, key).Result()
+}
+
+func (s *RedisClientSeedStore) Set(ctx context.Context, playerIdent, seed string) error {
+	if err := ValidateClientSeed(seed); err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, redisClientSeedKeyPrefix+playerIdent, seed, redisClientSeedTTL).Err()
+}
+
+// 内存版本，仅用于测试和本地调试
+type MemoryClientSeedStore struct {
+	seeds sync.Map
+}
+
+func NewMemoryClientSeedStore() *MemoryClientSeedStore {
+	return &MemoryClientSeedStore{}
+}
+
+func (s *MemoryClientSeedStore) Get(ctx context.Context, playerIdent string) (string, error) {
+	seed, _ := s.seeds.LoadOrStore(playerIdent, NewClientSeed())
+	return seed.(string), nil
+}
+
+func (s *MemoryClientSeedStore) Set(ctx context.Context, playerIdent, seed string) error {
+	if err := ValidateClientSeed(seed); err != nil {
+		return err
+	}
+	s.seeds.Store(playerIdent, seed)
+	return nil
+}
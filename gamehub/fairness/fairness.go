@@ -2,13 +2,16 @@ package fairness
 
 import (
 	"crypto/hmac"
+	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math"
 	"math/rand"
+	"strings"
 
 	"github.com/card-engine/game_common/utils"
 	"github.com/google/uuid"
@@ -42,6 +45,7 @@ func RoundIdInt64(s string) int64 {
 	return int64(binary.BigEndian.Uint64(hashBytes[:8]))
 }
 
+// Deprecated: 客户端种子由roundId推算，玩家无法参与，新游戏请使用Engine并收集玩家真实的客户端种子。
 func GenerateClientSeeds(roundId int64, count int) []ClientSeed {
 	seed := roundId
 	r := rand.New(rand.NewSource(seed)) // 固定种子，保证可复现
@@ -70,6 +74,8 @@ func GenerateClientSeeds(roundId int64, count int) []ClientSeed {
 	return seeds
 }
 
+// Deprecated: ServerSeed由roundId推算，知道roundId就能提前算出种子，Decimal也与结果无关。
+// 新游戏请使用Engine(NextRound/Outcome/Reveal)。
 func GenerateServerSeed(roundID int64, result float64) SeedInfo {
 	// Step 1: 用 roundID 派生出 ServerSeed
 	key := []byte(fmt.Sprintf("round-key-%d", roundID))
@@ -119,6 +125,8 @@ func GenerateServerSeed(roundID int64, result float64) SeedInfo {
 }
 
 // 仅生成 ServerSeed 字符串
+//
+// Deprecated: 同GenerateServerSeed。
 func GenerateServerSeedStr(roundID int64) string {
 	key := []byte(fmt.Sprintf("round-key-%d", roundID))
 	mac := hmac.New(sha256.New, key)
@@ -135,6 +143,33 @@ func GenerateClientSeedsStr(roundId int64) string {
 	return clientSeed
 }
 
+// 旧的spribe服务器种子，使用seed做随机数种子，查询时重新计算即可
+//
+// Deprecated: 使用Engine，展示格式使用SpribeFormatter。
+func GenerateSpribeServerSeed(seed int32) (string, error) {
+	rng := rand.New(rand.NewSource(int64(seed)))
+	randomBytes := make([]byte, 16)
+	for i := range randomBytes {
+		randomBytes[i] = byte(rng.Intn(256))
+	}
+	// 计算哈希
+	hash := sha256.Sum256(randomBytes)
+
+	// Base64 URL 编码，去掉末尾的 '='
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(hash[:]), "="), nil
+}
+
+// 使用服务器的种子生成spribe的ID
+func GenerateSpribeHashBasedID(seed string) int64 {
+	hash := sha1.Sum([]byte(seed))
+
+	// 取前4字节并转换为int32，然后加上基数
+	id := int64(binary.BigEndian.Uint32(hash[:4]))
+	baseValue := int64(3260000000)
+
+	return baseValue + (id % 1000000) // 限制在合理范围内
+}
+
 // ======================================================================
 // 随机昵称（可复现）
 func randomNickname(r *rand.Rand) string {
@@ -0,0 +1,30 @@
+package fairness
+
+import (
+	"fmt"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+// 把标准记录转换成各品牌客户端需要的格式，算法只在Engine/Verify中实现一次
+type Formatter interface {
+	Format(rec *Record) (interface{}, error)
+}
+
+var formatters = map[types.GameBrand]Formatter{
+	types.GameBrand_Inout:  InoutFormatter{},
+	types.GameBrand_Spribe: SpribeFormatter{},
+}
+
+func GetFormatter(brand types.GameBrand) (Formatter, bool) {
+	f, ok := formatters[brand]
+	return f, ok
+}
+
+func Format(brand types.GameBrand, rec *Record) (interface{}, error) {
+	f, ok := GetFormatter(brand)
+	if !ok {
+		return nil, fmt.Errorf("fairness: no formatter for brand %s", brand)
+	}
+	return f.Format(rec)
+}
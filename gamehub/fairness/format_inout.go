@@ -0,0 +1,65 @@
+package fairness
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+// inout get-game-seeds 返回的种子信息
+type InoutGameSeeds struct {
+	GameUUID         string            `json:"gameUUID"`
+	ServerSeed       string            `json:"serverSeed"`
+	HashedServerSeed string            `json:"hashedServerSeed"`
+	ClientSeeds      []InoutClientSeed `json:"clientSeeds"`
+	CombinedHash     string            `json:"combinedHash"`
+	Decimal          string            `json:"decimal"` // CombinedHash作为整数的科学计数法
+	Nonce            int64             `json:"nonce"`
+	Result           float64           `json:"result"`
+}
+
+type InoutClientSeed struct {
+	ID         string `json:"id"` // operatorId::userId
+	OperatorID string `json:"operatorId"`
+	UserID     string `json:"userId"`
+	ClientSeed string `json:"clientSeed"`
+	Nickname   string `json:"nickname"`
+}
+
+type InoutFormatter struct{}
+
+func (InoutFormatter) Format(rec *Record) (interface{}, error) {
+	hash, err := hex.DecodeString(rec.Outcome.Hash)
+	if err != nil {
+		return nil, err
+	}
+	decimal := new(big.Float).SetInt(new(big.Int).SetBytes(hash))
+
+	seeds := make([]InoutClientSeed, len(rec.Players))
+	for i, p := range rec.Players {
+		nickname := p.Nickname
+		if nickname == "" {
+			nickname = p.PlayerId
+		}
+		seeds[i] = InoutClientSeed{
+			ID:         p.AppId + "::" + p.PlayerId,
+			OperatorID: p.AppId,
+			UserID:     p.PlayerId,
+			ClientSeed: p.Seed,
+			Nickname:   nickname,
+		}
+	}
+
+	return &InoutGameSeeds{
+		// 同一个游戏同一局的UUID固定
+		GameUUID:         uuid.NewSHA1(uuid.NameSpaceOID, []byte(rec.GameId+":"+rec.RoundId)).String(),
+		ServerSeed:       rec.ServerSeed,
+		HashedServerSeed: rec.HashedServerSeed,
+		ClientSeeds:      seeds,
+		CombinedHash:     rec.Outcome.Hash,
+		Decimal:          decimal.Text('e', 15),
+		Nonce:            rec.Nonce,
+		Result:           rec.Result,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package fairness
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/qd2ss/sfs"
+)
+
+// spribe 公平性弹窗的数据，直接作为sfs消息的p发送
+type SpribeFormatter struct{}
+
+func (SpribeFormatter) Format(rec *Record) (interface{}, error) {
+	// spribe客户端展示的ServerSeed hash是base64url编码
+	hashed, err := hex.DecodeString(rec.HashedServerSeed)
+	if err != nil {
+		return nil, err
+	}
+
+	seeds := make(sfs.SFSArray, 0, len(rec.Players))
+	for _, p := range rec.Players {
+		username := p.Nickname
+		if username == "" {
+			username = p.PlayerId
+		}
+		seeds = append(seeds, sfs.SFSObject{
+			"seed":         p.Seed,
+			"username":     username,
+			"profileImage": p.Avatar,
+		})
+	}
+
+	hexStr := rec.Outcome.Hash
+	if len(hexStr) > 13 {
+		hexStr = hexStr[:13]
+	}
+	return sfs.SFSObject{
+		"roundId":        rec.RoundId,
+		"serverSeed":     rec.ServerSeed,
+		"serverSeedHash": base64.RawURLEncoding.EncodeToString(hashed),
+		"clientSeeds":    seeds,
+		"combinedHash":   rec.Outcome.Hash,
+		"hex":            hexStr,
+		"decimal":        float64(rec.Outcome.Decimal),
+		"nonce":          rec.Nonce,
+		"result":         rec.Result,
+	}, nil
+}
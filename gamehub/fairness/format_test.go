@@ -0,0 +1,92 @@
+package fairness
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/types"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// 固定密钥和链位置，保证每次生成的记录相同
+func goldenRecord(t *testing.T) *Record {
+	t.Helper()
+	e := testEngine(t, NewMemoryChainStore())
+	round, err := e.NextRound(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	players := []PlayerSeed{
+		{AppId: "op1", PlayerId: "10001", Nickname: "Ace", Avatar: "av-3.png", Seed: "alice-seed"},
+		{AppId: "op2", PlayerId: "20002", Seed: "bob_seed"},
+	}
+	if _, err := round.Outcome([]string{players[0].Seed, players[1].Seed}); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := NewRecord("1000001", round.Reveal(), players, 2.37)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func checkGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("%s mismatch (run with -update to regenerate)\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestFormatGolden(t *testing.T) {
+	rec := goldenRecord(t)
+	tests := []struct {
+		brand  types.GameBrand
+		golden string
+	}{
+		{types.GameBrand_Inout, "inout_get_game_seeds.golden"},
+		{types.GameBrand_Spribe, "spribe_fairness.golden"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.brand), func(t *testing.T) {
+			v, err := Format(tt.brand, rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.golden, v)
+		})
+	}
+
+	if _, err := Format(types.GameBrand_Jdb, rec); err == nil {
+		t.Fatal("expected error for brand without formatter")
+	}
+}
+
+func TestNewRecordRejectsMismatch(t *testing.T) {
+	e := testEngine(t, NewMemoryChainStore())
+	round, _ := e.NextRound(context.Background())
+	round.Outcome([]string{"a"})
+	if _, err := NewRecord("1", round.Reveal(), []PlayerSeed{{Seed: "b"}}, 1); err == nil {
+		t.Fatal("expected error when players do not match client seeds")
+	}
+}
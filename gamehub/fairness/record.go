@@ -0,0 +1,65 @@
+package fairness
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 参与一局的玩家和他的客户端种子
+type PlayerSeed struct {
+	AppId    string `json:"app_id"`
+	PlayerId string `json:"player_id"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+	Seed     string `json:"seed"`
+}
+
+// 一局结算后的标准公平性记录，各品牌的展示格式都由它转换而来
+type Record struct {
+	GameId           string       `json:"game_id"`
+	RoundId          string       `json:"round_id"`
+	ChainId          int64        `json:"chain_id"`
+	Nonce            int64        `json:"nonce"`
+	ServerSeed       string       `json:"server_seed"`
+	HashedServerSeed string       `json:"hashed_server_seed"`
+	ChainCommitment  string       `json:"chain_commitment"`
+	Players          []PlayerSeed `json:"players"`
+	Outcome          Outcome      `json:"outcome"`
+	Result           float64      `json:"result"` // 游戏对Outcome的解释，比如crash的倍数
+}
+
+// 用公开后的一局生成记录，players的顺序需要和计算结果时的客户端种子一致
+func NewRecord(roundId string, revealed RevealedRound, players []PlayerSeed, result float64) (*Record, error) {
+	if len(players) != len(revealed.ClientSeeds) {
+		return nil, fmt.Errorf("fairness: %d players for %d client seeds", len(players), len(revealed.ClientSeeds))
+	}
+	for i, p := range players {
+		if p.Seed != revealed.ClientSeeds[i] {
+			return nil, errors.New("fairness: players do not match client seeds")
+		}
+	}
+	outcome, err := VerifyRound(revealed)
+	if err != nil {
+		return nil, err
+	}
+	return &Record{
+		GameId:           revealed.GameId,
+		RoundId:          roundId,
+		ChainId:          revealed.ChainId,
+		Nonce:            revealed.Nonce,
+		ServerSeed:       revealed.ServerSeed,
+		HashedServerSeed: revealed.HashedServerSeed,
+		ChainCommitment:  revealed.ChainCommitment,
+		Players:          append([]PlayerSeed(nil), players...),
+		Outcome:          outcome,
+		Result:           result,
+	}, nil
+}
+
+func (r *Record) ClientSeeds() []string {
+	seeds := make([]string, len(r.Players))
+	for i, p := range r.Players {
+		seeds[i] = p.Seed
+	}
+	return seeds
+}
@@ -0,0 +1,67 @@
+package fairness
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 链的使用进度，必须持久化，保证重启后不会重复使用种子
+type ChainStore interface {
+	// 原子地分配下一局的位置，当前链用完时切换到下一条链，nonce从1开始
+	Next(ctx context.Context, gameId string, length int64) (chainId int64, nonce int64, err error)
+}
+
+const redisChainKeyPrefix = "fairness:chain:"
+
+// KEYS[1] 链进度的hash，ARGV[1] 链长度
+var nextChainScript = redis.NewScript(`
+local nonce = redis.call('HINCRBY', KEYS[1], 'nonce', 1)
+local chain = tonumber(redis.call('HGET', KEYS[1], 'chain') or '0')
+if nonce > tonumber(ARGV[1]) then
+	chain = redis.call('HINCRBY', KEYS[1], 'chain', 1)
+	nonce = 1
+	redis.call('HSET', KEYS[1], 'nonce', nonce)
+end
+return {chain, nonce}
+`)
+
+type RedisChainStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisChainStore(rdb *redis.Client) *RedisChainStore {
+	return &RedisChainStore{rdb: rdb}
+}
+
+func (s *RedisChainStore) Next(ctx context.Context, gameId string, length int64) (int64, int64, error) {
+	ret, err := nextChainScript.Run(ctx, s.rdb, []string{redisChainKeyPrefix + gameId}, length).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return ret[0], ret[1], nil
+}
+
+// 内存版本，重启后会从头开始，仅用于测试和本地调试
+type MemoryChainStore struct {
+	mu    sync.Mutex
+	state map[string][2]int64
+}
+
+func NewMemoryChainStore() *MemoryChainStore {
+	return &MemoryChainStore{state: make(map[string][2]int64)}
+}
+
+func (s *MemoryChainStore) Next(ctx context.Context, gameId string, length int64) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state[gameId]
+	st[1]++
+	if st[1] > length {
+		st[0]++
+		st[1] = 1
+	}
+	s.state[gameId] = st
+	return st[0], st[1], nil
+}
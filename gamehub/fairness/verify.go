@@ -0,0 +1,74 @@
+package fairness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrSeedMismatch = errors.New("fairness: server seed does not match its commitment")
+
+// 校验公开的一局: ServerSeed与开局前的承诺一致，且结果可以复算
+func VerifyRound(round RevealedRound) (Outcome, error) {
+	seed, err := hex.DecodeString(round.ServerSeed)
+	if err != nil {
+		return Outcome{}, err
+	}
+	hashed := sha256.Sum256(seed)
+	if hex.EncodeToString(hashed[:]) != round.HashedServerSeed {
+		return Outcome{}, ErrSeedMismatch
+	}
+	outcome := Verify(round.ServerSeed, round.ClientSeeds, round.Nonce)
+	if round.Outcome != nil && round.Outcome.Hash != outcome.Hash {
+		return outcome, errors.New("fairness: outcome does not match the revealed seeds")
+	}
+	return outcome, nil
+}
+
+type verifyResponse struct {
+	Outcome
+	ServerSeed       string `json:"server_seed"`
+	HashedServerSeed string `json:"hashed_server_seed"`
+	// 请求中带了hashed_server_seed时返回是否一致
+	CommitmentValid *bool `json:"commitment_valid,omitempty"`
+}
+
+// 公开的校验接口:
+//
+//	GET ?server_seed=<hex>&client_seeds=a,b,c&nonce=1[&hashed_server_seed=<hex>]
+func VerifyHandler(c *fiber.Ctx) error {
+	serverSeed := c.Query("server_seed")
+	seed, err := hex.DecodeString(serverSeed)
+	if err != nil || len(seed) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid server_seed")
+	}
+	nonce, err := strconv.ParseInt(c.Query("nonce"), 10, 64)
+	if err != nil || nonce < 1 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid nonce")
+	}
+	var clientSeeds []string
+	if raw := c.Query("client_seeds"); raw != "" {
+		clientSeeds = strings.Split(raw, ",")
+		for _, cs := range clientSeeds {
+			if err := ValidateClientSeed(cs); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+		}
+	}
+
+	hashed := sha256.Sum256(seed)
+	rsp := verifyResponse{
+		Outcome:          Verify(serverSeed, clientSeeds, nonce),
+		ServerSeed:       serverSeed,
+		HashedServerSeed: hex.EncodeToString(hashed[:]),
+	}
+	if commitment := c.Query("hashed_server_seed"); commitment != "" {
+		valid := strings.EqualFold(commitment, rsp.HashedServerSeed)
+		rsp.CommitmentValid = &valid
+	}
+	return c.JSON(rsp)
+}
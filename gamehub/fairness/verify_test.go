@@ -0,0 +1,96 @@
+package fairness
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestVerifyKnownVector(t *testing.T) {
+	// HMAC-SHA512(key="abab...", "alice:bob:42")，可以用任意通用工具复算
+	out := Verify(strings.Repeat("ab", 32), []string{"alice", "bob"}, 42)
+	if out.Hash != "90318c66b2921fa80c360397011cbedc29acd3665cebd7b3837965fed0d7f20e5511a0352ddefc1d7d1b131ae88ca2fbf7130d7c40f12b05aedff0e6811f362b" {
+		t.Fatalf("unexpected hash %s", out.Hash)
+	}
+	if out.Decimal != 2536679733405985 {
+		t.Fatalf("unexpected decimal %d", out.Decimal)
+	}
+}
+
+func TestVerifyRound(t *testing.T) {
+	e := testEngine(t, NewMemoryChainStore())
+	round, err := e.NextRound(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := round.Outcome([]string{"p1", "p2"}); err != nil {
+		t.Fatal(err)
+	}
+	revealed := round.Reveal()
+	if _, err := VerifyRound(revealed); err != nil {
+		t.Fatal(err)
+	}
+
+	revealed.HashedServerSeed = strings.Repeat("0", 64)
+	if _, err := VerifyRound(revealed); err != ErrSeedMismatch {
+		t.Fatalf("expected ErrSeedMismatch, got %v", err)
+	}
+}
+
+func TestClientSeedStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryClientSeedStore()
+
+	first, _ := store.Get(ctx, "app-1")
+	if again, _ := store.Get(ctx, "app-1"); again != first || ValidateClientSeed(first) != nil {
+		t.Fatalf("generated seed should be stable and valid: %q %q", first, again)
+	}
+	if err := store.Set(ctx, "app-1", "my_lucky-seed"); err != nil {
+		t.Fatal(err)
+	}
+	if seed, _ := store.Get(ctx, "app-1"); seed != "my_lucky-seed" {
+		t.Fatalf("seed = %q", seed)
+	}
+	for _, bad := range []string{"", "a:b", "a,b", strings.Repeat("x", MaxClientSeedLength+1)} {
+		if err := store.Set(ctx, "app-1", bad); err != ErrInvalidClientSeed {
+			t.Fatalf("seed %q should be rejected", bad)
+		}
+	}
+	rotated, err := RotateClientSeed(ctx, store, "app-1")
+	if err != nil || rotated == "my_lucky-seed" {
+		t.Fatalf("rotate: %q %v", rotated, err)
+	}
+}
+
+func TestVerifyHandler(t *testing.T) {
+	app := fiber.New()
+	app.Get("/fairness/verify", VerifyHandler)
+
+	seed := strings.Repeat("ab", 32)
+	req := httptest.NewRequest("GET", "/fairness/verify?server_seed="+seed+"&client_seeds=alice,bob&nonce=42&hashed_server_seed=00", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	var rsp verifyResponse
+	if err := json.Unmarshal(body, &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Hash != Verify(seed, []string{"alice", "bob"}, 42).Hash || rsp.CommitmentValid == nil || *rsp.CommitmentValid {
+		t.Fatalf("unexpected response %s", body)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/fairness/verify?server_seed=zz&nonce=1", nil))
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("invalid seed should be rejected, status %d", resp.StatusCode)
+	}
+}
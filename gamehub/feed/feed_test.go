@@ -0,0 +1,85 @@
+package feed
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLive(t *testing.T) {
+	l := NewLive()
+	l.NextRound("r1")
+	l.Place("app-p1", Bet{Id: "r1-0", RoundId: "r1", PlayerId: "p1", Currency: "USD", Amount: 1})
+	l.Place("app-p2", Bet{Id: "r1-1", RoundId: "r1", PlayerId: "p2", Currency: "INR", Amount: 500})
+	l.Place("app-bot", Bet{Id: "r1-2", RoundId: "r1", PlayerId: "bot", Currency: "USD", Amount: 2, IsBot: true})
+	l.Place("app-p1", Bet{Id: "r1-3", RoundId: "r1", PlayerId: "p1", Currency: "USD", Amount: 3})
+	l.Remove("r1-3")
+
+	// 500INR约5.7美元，排在最前
+	current := l.Current()
+	if len(current) != 3 || current[0].PlayerId != "p2" || current[1].PlayerId != "bot" {
+		t.Fatalf("unexpected current %+v", current)
+	}
+	if b, ok := l.Settle("r1-0", 2, 2); !ok || !b.Settled {
+		t.Fatal("settle failed")
+	}
+	if mine := l.MyBets("app-p1", 0); len(mine) != 1 || mine[0].Win != 2 {
+		t.Fatalf("unexpected my bets %+v", mine)
+	}
+
+	l.NextRound("r2")
+	l.Place("app-p1", Bet{Id: "r2-0", RoundId: "r2", PlayerId: "p1", Currency: "USD", Amount: 5})
+	if len(l.Current()) != 1 || len(l.Previous()) != 3 {
+		t.Fatal("round not rotated")
+	}
+	mine := l.MyBets("app-p1", 10)
+	if len(mine) != 2 || mine[0].RoundId != "r2" || mine[1].RoundId != "r1" {
+		t.Fatalf("unexpected my bets %+v", mine)
+	}
+	if len(l.MyBets("app-bot", 10)) != 0 {
+		t.Fatal("bot bets should not be kept")
+	}
+}
+
+func TestMemoryTopWins(t *testing.T) {
+	s := NewMemoryTopWins()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	s.Record(ctx, "spribe", "aviator", Bet{Id: "1", Currency: "USD", Amount: 10, Multiplier: 5, Win: 50})
+	s.Record(ctx, "spribe", "aviator", Bet{Id: "2", Currency: "INR", Amount: 100, Multiplier: 100, Win: 10000})
+	s.Record(ctx, "spribe", "aviator", Bet{Id: "3", Currency: "USD", Amount: 10, Multiplier: 1.5})
+	if err := s.Record(ctx, "spribe", "aviator", Bet{Id: "4", Currency: "XXX", Win: 1}); err == nil {
+		t.Fatal("expected unsupported currency error")
+	}
+
+	// 10000INR约114美元
+	top, _ := s.Top(ctx, "spribe", "aviator", KindWin, PeriodDay, "", 10)
+	if len(top) != 2 || top[0].Id != "2" || top[1].Id != "1" {
+		t.Fatalf("unexpected top wins %+v", top)
+	}
+	top, _ = s.Top(ctx, "spribe", "aviator", KindMultiplier, PeriodYear, "USD", 1)
+	if len(top) != 1 || top[0].Id != "2" || top[0].Currency != "USD" || top[0].Win < 114 || top[0].Win > 115 {
+		t.Fatalf("unexpected top multipliers %+v", top)
+	}
+
+	// 第二天的日榜是空的，月榜还在
+	now = now.Add(24 * time.Hour)
+	if top, _ := s.Top(ctx, "spribe", "aviator", KindWin, PeriodDay, "", 10); len(top) != 0 {
+		t.Fatalf("day board should roll over: %+v", top)
+	}
+	if top, _ := s.Top(ctx, "spribe", "aviator", KindWin, PeriodMonth, "", 10); len(top) != 2 {
+		t.Fatalf("month board should keep wins: %+v", top)
+	}
+}
+
+func TestTopWinsKey(t *testing.T) {
+	now := time.Date(2026, 10, 19, 23, 0, 0, 0, time.FixedZone("UTC-8", -8*3600))
+	if got := topWinsKey("inout", "crash", KindWin, PeriodDay, now); got != "feed:top_wins:inout:crash:win:day:20261020" {
+		t.Fatalf("got %s", got)
+	}
+	if got := topWinsKey("inout", "crash", KindMultiplier, PeriodMonth, now); got != "feed:top_wins:inout:crash:multiplier:month:202610" {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -0,0 +1,176 @@
+// 多人房间共用的下注展示: 房间内存中的实时下注列表("all bets"/"my bets")，
+// 以及按游戏统计的日/月/年最高赢奖排行榜("top wins")。
+package feed
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	DefaultMyBetsSize      = 50  // 每个玩家保留的最近下注条数
+	DefaultPreviousSize    = 500 // 上一局最多保留的下注条数
+	DefaultMaxTrackedUsers = 10000
+)
+
+// 一笔下注，Multiplier和Win在结算后才有值
+type Bet struct {
+	Id         string  `json:"id"` // 局号+下注框，全局唯一
+	RoundId    string  `json:"roundId"`
+	PlayerId   string  `json:"playerId"`
+	Nickname   string  `json:"nickname,omitempty"`
+	Avatar     string  `json:"avatar,omitempty"`
+	IsBot      bool    `json:"isBot,omitempty"`
+	Currency   string  `json:"currency"`
+	Amount     float64 `json:"amount"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Win        float64 `json:"win,omitempty"`
+	Settled    bool    `json:"settled"`
+	Time       int64   `json:"time"` // 毫秒
+}
+
+// 房间内的实时下注，并发安全
+type Live struct {
+	mu       sync.Mutex
+	roundId  string
+	current  map[string]*Bet   // 本局的下注
+	previous []*Bet            // 上一局的下注
+	mine     map[string][]*Bet // 玩家唯一标识 -> 最近的下注(不含本局)，新的在前
+	owners   map[string]string // 下注id -> 玩家唯一标识
+	order    []string          // 玩家出现的顺序，超过上限时淘汰最早的
+	myBets   int
+	maxUsers int
+}
+
+func NewLive() *Live {
+	return &Live{
+		current:  make(map[string]*Bet),
+		mine:     make(map[string][]*Bet),
+		owners:   make(map[string]string),
+		myBets:   DefaultMyBetsSize,
+		maxUsers: DefaultMaxTrackedUsers,
+	}
+}
+
+// 开始新的一局，本局的下注移到上一局和各自玩家的记录中
+func (l *Live) NextRound(roundId string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.previous = sortBets(l.current)
+	if len(l.previous) > DefaultPreviousSize {
+		l.previous = l.previous[:DefaultPreviousSize]
+	}
+	for id, b := range l.current {
+		ident := l.owners[id]
+		if b.IsBot {
+			continue
+		}
+		if _, ok := l.mine[ident]; !ok {
+			l.order = append(l.order, ident)
+		}
+		bets := append([]*Bet{b}, l.mine[ident]...)
+		if len(bets) > l.myBets {
+			bets = bets[:l.myBets]
+		}
+		l.mine[ident] = bets
+	}
+	for len(l.order) > l.maxUsers {
+		delete(l.mine, l.order[0])
+		l.order = l.order[1:]
+	}
+
+	l.roundId = roundId
+	l.current = make(map[string]*Bet)
+	l.owners = make(map[string]string)
+}
+
+// 下注成功
+func (l *Live) Place(playerIdent string, bet Bet) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.current[bet.Id] = &bet
+	l.owners[bet.Id] = playerIdent
+}
+
+// 取消下注
+func (l *Live) Remove(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.current, id)
+	delete(l.owners, id)
+}
+
+// 结算，输掉的multiplier和win为0
+func (l *Live) Settle(id string, multiplier, win float64) (Bet, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.current[id]
+	if !ok {
+		return Bet{}, false
+	}
+	b.Multiplier = multiplier
+	b.Win = win
+	b.Settled = true
+	return *b, true
+}
+
+// 本局所有下注，按下注金额(换算成美元)从大到小
+func (l *Live) Current() []Bet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return copyBets(sortBets(l.current))
+}
+
+// 上一局所有下注
+func (l *Live) Previous() []Bet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return copyBets(l.previous)
+}
+
+// 玩家最近的下注，包括本局的，新的在前
+func (l *Live) MyBets(playerIdent string, limit int) []Bet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var bets []*Bet
+	for id, b := range l.current {
+		if l.owners[id] == playerIdent {
+			bets = append(bets, b)
+		}
+	}
+	sort.Slice(bets, func(i, j int) bool { return bets[i].Id < bets[j].Id })
+	bets = append(bets, l.mine[playerIdent]...)
+	if limit > 0 && len(bets) > limit {
+		bets = bets[:limit]
+	}
+	return copyBets(bets)
+}
+
+func sortBets(m map[string]*Bet) []*Bet {
+	bets := make([]*Bet, 0, len(m))
+	for _, b := range m {
+		bets = append(bets, b)
+	}
+	// 不同币种换算成美元比较
+	usd := make(map[*Bet]float64, len(bets))
+	for _, b := range bets {
+		usd[b], _ = ToUsd(b.Amount, b.Currency)
+	}
+	sort.Slice(bets, func(i, j int) bool {
+		if usd[bets[i]] != usd[bets[j]] {
+			return usd[bets[i]] > usd[bets[j]]
+		}
+		return bets[i].Id < bets[j].Id
+	})
+	return bets
+}
+
+func copyBets(bets []*Bet) []Bet {
+	out := make([]Bet, len(bets))
+	for i, b := range bets {
+		out[i] = *b
+	}
+	return out
+}
@@ -0,0 +1,214 @@
+package feed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/card-engine/game_common/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultTopWinsSize = 100 // 每个排行榜保留的条数
+
+	redisTopWinsKeyPrefix = "feed:top_wins:"
+)
+
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+)
+
+var Periods = []Period{PeriodDay, PeriodMonth, PeriodYear}
+
+// 排行榜的排序方式
+type Kind string
+
+const (
+	KindWin        Kind = "win"        // 按赢奖金额(换算成美元)
+	KindMultiplier Kind = "multiplier" // 按倍数
+)
+
+var Kinds = []Kind{KindWin, KindMultiplier}
+
+// 排行榜的时间段，day为20261019，month为202610，year为2026，按UTC计算
+func periodBucket(period Period, t time.Time) string {
+	t = t.UTC()
+	switch period {
+	case PeriodDay:
+		return t.Format("20060102")
+	case PeriodMonth:
+		return t.Format("200601")
+	}
+	return t.Format("2006")
+}
+
+// 时间段结束之后再保留一段时间
+func periodTTL(period Period) time.Duration {
+	switch period {
+	case PeriodDay:
+		return 2 * 24 * time.Hour
+	case PeriodMonth:
+		return 62 * 24 * time.Hour
+	}
+	return 400 * 24 * time.Hour
+}
+
+// 换算成美元，用于不同币种之间比较，不支持的币种返回false
+func ToUsd(amount float64, currency string) (float64, bool) {
+	usd, err := utils.ConvertCurrency(amount, currency, "USD", utils.ExchangeRates)
+	return usd, err == nil
+}
+
+func score(kind Kind, bet *Bet) (float64, bool) {
+	if kind == KindMultiplier {
+		return bet.Multiplier, true
+	}
+	return ToUsd(bet.Win, bet.Currency)
+}
+
+// 把金额换算成展示的币种，currency为空或者不支持时保持原币种
+func convertBet(bet Bet, currency string) Bet {
+	if currency == "" || currency == bet.Currency {
+		return bet
+	}
+	amount, err1 := utils.ConvertCurrency(bet.Amount, bet.Currency, currency, utils.ExchangeRates)
+	win, err2 := utils.ConvertCurrency(bet.Win, bet.Currency, currency, utils.ExchangeRates)
+	if err1 != nil || err2 != nil {
+		return bet
+	}
+	bet.Amount, bet.Win, bet.Currency = amount, win, currency
+	return bet
+}
+
+// 每个游戏的最高赢奖排行榜
+type TopWinsStore interface {
+	// 记录一笔赢奖，没有赢的不记录
+	Record(ctx context.Context, gameBrand, gameId string, bet Bet) error
+	// 查询排行榜，金额换算成currency展示
+	Top(ctx context.Context, gameBrand, gameId string, kind Kind, period Period, currency string, limit int) ([]Bet, error)
+}
+
+func topWinsKey(gameBrand, gameId string, kind Kind, period Period, t time.Time) string {
+	return fmt.Sprintf("%s%s:%s:%s:%s:%s", redisTopWinsKeyPrefix, gameBrand, gameId, kind, period, periodBucket(period, t))
+}
+
+// 使用redis的有序集合，member为下注的json
+type RedisTopWins struct {
+	rdb  *redis.Client
+	size int
+	now  func() time.Time
+}
+
+func NewRedisTopWins(rdb *redis.Client) *RedisTopWins {
+	return &RedisTopWins{rdb: rdb, size: DefaultTopWinsSize, now: time.Now}
+}
+
+func (s *RedisTopWins) Record(ctx context.Context, gameBrand, gameId string, bet Bet) error {
+	if bet.Win <= 0 {
+		return nil
+	}
+	member, err := json.Marshal(bet)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	pipe := s.rdb.TxPipeline()
+	for _, kind := range Kinds {
+		sc, ok := score(kind, &bet)
+		if !ok {
+			return fmt.Errorf("feed: unsupported currency %s", bet.Currency)
+		}
+		for _, period := range Periods {
+			key := topWinsKey(gameBrand, gameId, kind, period, now)
+			pipe.ZAdd(ctx, key, redis.Z{Score: sc, Member: member})
+			// 只保留分数最高的size条
+			pipe.ZRemRangeByRank(ctx, key, 0, int64(-s.size-1))
+			pipe.Expire(ctx, key, periodTTL(period))
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *RedisTopWins) Top(ctx context.Context, gameBrand, gameId string, kind Kind, period Period, currency string, limit int) ([]Bet, error) {
+	if limit <= 0 || limit > s.size {
+		limit = s.size
+	}
+	members, err := s.rdb.ZRevRange(ctx, topWinsKey(gameBrand, gameId, kind, period, s.now()), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	bets := make([]Bet, 0, len(members))
+	for _, m := range members {
+		var bet Bet
+		if err := json.Unmarshal([]byte(m), &bet); err != nil {
+			continue
+		}
+		bets = append(bets, convertBet(bet, currency))
+	}
+	return bets, nil
+}
+
+// 内存实现，用于测试和没有redis的环境
+type MemoryTopWins struct {
+	mu     sync.Mutex
+	size   int
+	now    func() time.Time
+	boards map[string][]scoredBet
+}
+
+type scoredBet struct {
+	score float64
+	bet   Bet
+}
+
+func NewMemoryTopWins() *MemoryTopWins {
+	return &MemoryTopWins{size: DefaultTopWinsSize, now: time.Now, boards: make(map[string][]scoredBet)}
+}
+
+func (s *MemoryTopWins) Record(ctx context.Context, gameBrand, gameId string, bet Bet) error {
+	if bet.Win <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, kind := range Kinds {
+		sc, ok := score(kind, &bet)
+		if !ok {
+			return fmt.Errorf("feed: unsupported currency %s", bet.Currency)
+		}
+		for _, period := range Periods {
+			key := topWinsKey(gameBrand, gameId, kind, period, now)
+			board := append(s.boards[key], scoredBet{score: sc, bet: bet})
+			sort.SliceStable(board, func(i, j int) bool { return board[i].score > board[j].score })
+			if len(board) > s.size {
+				board = board[:s.size]
+			}
+			s.boards[key] = board
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTopWins) Top(ctx context.Context, gameBrand, gameId string, kind Kind, period Period, currency string, limit int) ([]Bet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	board := s.boards[topWinsKey(gameBrand, gameId, kind, period, s.now())]
+	if limit <= 0 || limit > len(board) {
+		limit = len(board)
+	}
+	bets := make([]Bet, 0, limit)
+	for _, b := range board[:limit] {
+		bets = append(bets, convertBet(b.bet, currency))
+	}
+	return bets, nil
+}
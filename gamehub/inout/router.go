@@ -14,6 +14,8 @@ import (
 	v1 "github.com/card-engine/game_common/api/game/v1"
 	client_utils "github.com/card-engine/game_common/api/game/v1/client"
 	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/session"
 	"github.com/card-engine/game_common/gamehub/types"
 
 	inout_utils "github.com/card-engine/game_common/inout/utils"
@@ -37,6 +39,10 @@ type InoutRouter struct {
 	roomManager *common.RoomManager
 	lobby       types.LobbyImp
 	logger      log.Logger
+
+	sessions  *session.Manager        // 为空时不限制多处登陆
+	sendQueue common.SendQueueOptions // 玩家连接的发送队列配置
+	flood     common.FloodOptions     // 消息限流，默认不限制
 }
 
 func NewInoutRouter(
@@ -48,6 +54,10 @@ func NewInoutRouter(
 	roomManager *common.RoomManager,
 	lobby types.LobbyImp,
 	logger log.Logger) *InoutRouter {
+	// 客户端种子放在roomManager上，房间计算结果时使用
+	if rdb != nil {
+		roomManager.SetClientSeeds(fairness.NewRedisClientSeedStore(rdb))
+	}
 	return &InoutRouter{
 		app:         app,
 		gameName:    gameName,
@@ -86,6 +96,9 @@ func (r *InoutRouter) OnWebSocketHandler(c *websocket.Conn, operatorId string, t
 	defer func() {
 		c.Close()
 		if inoutPlayer != nil {
+			if r.sessions != nil {
+				r.sessions.Logout(inoutPlayer)
+			}
 			r.roomManager.OnDisConnect(inoutPlayer)
 			inoutPlayer.SetConn(nil)
 		}
@@ -114,7 +127,9 @@ func (r *InoutRouter) OnWebSocketHandler(c *websocket.Conn, operatorId string, t
 	// 	return err
 	// }
 
-	inoutPlayer = common.NewPlayer(types.GameBrand_Inout, c, playerInfo, r.rtpGrpcConn, r.log)
+	newPlayer := common.NewPlayer(types.GameBrand_Inout, c, playerInfo, r.rtpGrpcConn, r.log)
+	newPlayer.SetSendQueueOptions(r.sendQueue)
+	inoutPlayer = newPlayer
 
 	// inoutPlayer = &Player{
 	// 	gameBrand:  GameBrand_Inout,
@@ -128,9 +143,13 @@ func (r *InoutRouter) OnWebSocketHandler(c *websocket.Conn, operatorId string, t
 		return err
 	}
 
+	guard := common.NewFloodGuard(r.flood)
+	guard.Watch(c)
 	for {
+		guard.BeforeRead(c)
 		messageType, msg, err := c.ReadMessage()
 		if err != nil {
+			guard.OnReadError(err)
 			r.log.Errorf("read websocket message error: %v", err)
 			break
 		}
@@ -140,7 +159,7 @@ func (r *InoutRouter) OnWebSocketHandler(c *websocket.Conn, operatorId string, t
 			break
 		}
 
-		if err := r.OnMessage(inoutPlayer, msg); err != nil {
+		if err := r.onMessage(inoutPlayer, guard, msg); err != nil {
 			r.log.Errorf("OnMessage failed: %v", err)
 			break
 		}
@@ -157,12 +176,23 @@ func (r *InoutRouter) generateSessionID() string {
 }
 
 func (r *InoutRouter) OnMessage(player types.PlayerImp, msg []byte) error {
+	return r.onMessage(player, nil, msg)
+}
+
+func (r *InoutRouter) onMessage(player types.PlayerImp, guard *common.FloodGuard, msg []byte) error {
 	msgType, payload, err := inout_utils.ParseCustomMessage(string(msg))
 	if err != nil {
 		r.log.Errorf("ParseCustomMessage failed: %v", err)
 		return err
 	}
 
+	// 自定义消息在解析出事件名之后检查
+	if !strings.HasPrefix(msgType, "42") {
+		if ok, err := r.allow(player, guard, "", ""); !ok {
+			return err
+		}
+	}
+
 	switch msgType {
 	case "0": // Engine.IO握手请求，发送握手响应
 		return nil
@@ -175,8 +205,55 @@ func (r *InoutRouter) OnMessage(player types.PlayerImp, msg []byte) error {
 		return r.onInitData(player)
 	default:
 		// 自定义消息格式处理
-		return r.onCustomMessage(player, msgType, payload)
+		return r.onCustomMessage(player, guard, msgType, payload)
+	}
+}
+
+// 超过限制时丢弃消息，带消息id的回复错误，次数太多时返回错误断开连接
+func (r *InoutRouter) allow(player types.PlayerImp, guard *common.FloodGuard, action, responseType string) (bool, error) {
+	err := guard.Allow(action)
+	if errors.Is(err, common.ErrRateLimited) {
+		if len(responseType) > 2 {
+			player.SendString(fmt.Sprintf(`%s[{"error":{"message":"Too many requests"}}]`, responseType))
+		}
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 设置消息限流、最大消息长度和空闲超时，需要在Start之前调用
+func (r *InoutRouter) SetFloodOptions(opts common.FloodOptions) {
+	r.flood = opts
+}
+
+// 设置会话管理，同一个玩家只能在一个地方登陆
+func (r *InoutRouter) SetSessionManager(sessions *session.Manager) {
+	r.sessions = sessions
+}
+
+// 设置玩家连接的发送队列，需要在Start之前调用
+func (r *InoutRouter) SetSendQueueOptions(opts common.SendQueueOptions) {
+	r.sendQueue = opts
+}
+
+// 节点下线前通知客户端重连到其它节点，然后发送socket.io的断开
+func (r *InoutRouter) ReconnectHint(player types.PlayerImp) {
+	player.SendString(`42["serverRestart",{"reconnect":true}]`)
+	player.SendString("41")
+}
+
+// 管理员踢人或销毁房间时通知玩家原因
+func (r *InoutRouter) KickNotice(player types.PlayerImp, reason string) {
+	buff, err := json.Marshal([]interface{}{"kicked", map[string]string{"reason": reason}})
+	if err != nil {
+		r.log.Errorf("marshal kick notice failed: %v", err)
+		return
 	}
+	player.SendString(types.DefaultMsgId + string(buff))
+}
+
+func (r *InoutRouter) onLoggedInElsewhere(player types.PlayerImp) {
+	player.SendString(`42["loggedInElsewhere",{"message":"You have logged in from another device"}]`)
 }
 
 func (r *InoutRouter) startHandshake(player types.PlayerImp) error {
@@ -189,7 +266,7 @@ func (r *InoutRouter) onPing(player types.PlayerImp) error {
 }
 
 // 处理所有42xx消息并返回43xx响应
-func (r *InoutRouter) onCustomMessage(player types.PlayerImp, msgType string, payload string) error {
+func (r *InoutRouter) onCustomMessage(player types.PlayerImp, guard *common.FloodGuard, msgType string, payload string) error {
 	responseType := "43" + msgType[2:]
 	if strings.HasPrefix(msgType, "42") {
 		simpleJson, err := simplejson.NewJson([]byte(payload))
@@ -215,6 +292,16 @@ func (r *InoutRouter) onCustomMessage(player types.PlayerImp, msgType string, pa
 			dataJson = simpleJson.GetIndex(1)
 		}
 
+		limitKey := action
+		if action == "gameService" && dataJson != nil {
+			if subAction := dataJson.Get("action").MustString(""); subAction != "" {
+				limitKey = action + ":" + subAction
+			}
+		}
+		if ok, err := r.allow(player, guard, limitKey, responseType); !ok {
+			return err
+		}
+
 		switch action {
 		case "gameService-latencyTest":
 			responseMsg := fmt.Sprintf(`%s[{"date":%v}]`, responseType, time.Now().UnixMilli())
@@ -231,6 +318,8 @@ func (r *InoutRouter) onCustomMessage(player types.PlayerImp, msgType string, pa
 		case "changeGameAvatar":
 			// 处理修改游戏头像消息
 			return r.onHandleChangeGameAvatar(player, dataJson, responseType)
+		case "fairness-get-client-seed", "fairness-set-client-seed", "fairness-rotate-client-seed":
+			return r.onHandleClientSeed(player, action, dataJson, responseType)
 		}
 
 		return nil
@@ -314,6 +403,39 @@ func (r *InoutRouter) onHandleChangeGameAvatar(player types.PlayerImp, data inte
 	return nil
 }
 
+// 玩家查看、设置或者随机更换自己的客户端种子，下一局开始生效
+func (r *InoutRouter) onHandleClientSeed(player types.PlayerImp, action string, data *simplejson.Json, responseType string) error {
+	ctx := context.Background()
+	playerIdent := player.GetPlayerIdent()
+
+	var seed string
+	var err error
+	switch action {
+	case "fairness-set-client-seed":
+		if data != nil {
+			seed = data.Get("clientSeed").MustString("")
+		}
+		err = r.roomManager.ClientSeeds().Set(ctx, playerIdent, seed)
+	case "fairness-rotate-client-seed":
+		seed, err = fairness.RotateClientSeed(ctx, r.roomManager.ClientSeeds(), playerIdent)
+	default:
+		seed, err = r.roomManager.ClientSeeds().Get(ctx, playerIdent)
+	}
+	if err != nil {
+		r.log.Errorf("%s failed: %v", action, err)
+		if errors.Is(err, fairness.ErrInvalidClientSeed) {
+			return player.SendString(fmt.Sprintf(`%s[{"error":{"message":"%s"}}]`, responseType, err.Error()))
+		}
+		return err
+	}
+
+	responseData, err := json.Marshal([]interface{}{map[string]string{"clientSeed": seed}})
+	if err != nil {
+		return err
+	}
+	return player.SendString(responseType + string(responseData))
+}
+
 func (r *InoutRouter) onHandleGameServiceMessage(player types.PlayerImp, data *simplejson.Json, responseType string) error {
 	action := data.Get("action").MustString("")
 	if action == "" {
@@ -359,6 +481,14 @@ func (r *InoutRouter) onInitData(player types.PlayerImp) error {
 		return err
 	}
 
+	// 踢掉其它地方的登陆
+	if r.sessions != nil {
+		if err := r.sessions.Login(player, r.onLoggedInElsewhere); err != nil {
+			r.log.Errorf("session Login failed: %v", err)
+			return err
+		}
+	}
+
 	// 剩下的交给房间处理
 	if err := r.lobby.OnLogin(player); err != nil {
 		r.log.Errorf("OnLogin failed: %v", err)
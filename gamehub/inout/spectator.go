@@ -0,0 +1,121 @@
+package inout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/spectator"
+	inout_utils "github.com/card-engine/game_common/inout/utils"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 观战入口，token由运营方通过spectator.Issue签发，不需要玩家登录
+func (r *InoutRouter) RouteSpectator(gate *spectator.Gate) {
+	routPath := fmt.Sprintf("/%s/spectate", r.gameName)
+
+	r.app.Get(routPath, func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		claims, release, err := gate.Admit(c.Query("token"))
+		if err != nil {
+			r.log.Warnf("spectator rejected: %v", err)
+			if errors.Is(err, spectator.ErrTooManySpectators) {
+				return fiber.ErrTooManyRequests
+			}
+			return fiber.ErrUnauthorized
+		}
+
+		return websocket.New(func(conn *websocket.Conn) {
+			defer release()
+			watcher := spectator.New(conn, claims)
+			watcher.SetSendQueueOptions(r.sendQueue)
+			r.onSpectatorHandler(watcher)
+		})(c)
+	})
+}
+
+func (r *InoutRouter) onSpectatorHandler(watcher *spectator.Spectator) {
+	conn := watcher.GetConn()
+	defer func() {
+		conn.Close()
+		r.roomManager.OnDisConnect(watcher)
+		watcher.SetConn(nil)
+	}()
+
+	if err := r.startHandshake(watcher); err != nil {
+		r.log.Errorf("startHandshake failed: %v", err)
+		return
+	}
+
+	guard := common.NewFloodGuard(r.flood)
+	guard.Watch(conn)
+	for {
+		guard.BeforeRead(conn)
+		messageType, msg, err := conn.ReadMessage()
+		if err != nil {
+			guard.OnReadError(err)
+			break
+		}
+		if messageType != websocket.TextMessage {
+			r.log.Error("recv error websocket message type")
+			break
+		}
+		if err := r.onSpectatorMessage(watcher, guard, msg); err != nil {
+			r.log.Errorf("spectator OnMessage failed: %v", err)
+			break
+		}
+	}
+}
+
+// 与OnMessage相同，只是40不查余额，直接进入观战；只能心跳、查询状态和历史，其余的游戏操作都会被拒绝
+func (r *InoutRouter) onSpectatorMessage(watcher *spectator.Spectator, guard *common.FloodGuard, msg []byte) error {
+	msgType, payload, err := inout_utils.ParseCustomMessage(string(msg))
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(msgType, "42") {
+		if ok, err := r.allow(watcher, guard, "", ""); !ok {
+			return err
+		}
+	}
+
+	switch msgType {
+	case "0", "3":
+		return nil
+	case "2":
+		return r.onPing(watcher)
+	case "40":
+		if err := watcher.SendString(fmt.Sprintf(`40{"sid":"%s"}`, r.generateSessionID())); err != nil {
+			return err
+		}
+		return r.roomManager.Spectate(watcher, watcher.RoomType(), watcher.RoomArgs())
+	default:
+		if strings.HasPrefix(msgType, "42") {
+			if action := customAction(payload); !common.IsSpectatorCommand(action) {
+				r.log.Warnf("spectator %s action %s rejected", watcher.GetPlayerIdent(), action)
+				return nil
+			}
+		}
+		return r.onCustomMessage(watcher, guard, msgType, payload)
+	}
+}
+
+// 42消息的事件名，gameService取其中的action
+func customAction(payload string) string {
+	simpleJson, err := simplejson.NewJson([]byte(payload))
+	if err != nil {
+		return ""
+	}
+	action := simpleJson.GetIndex(0).MustString("")
+	if action == "gameService" {
+		return simpleJson.GetIndex(1).Get("action").MustString("")
+	}
+	return action
+}
@@ -2,11 +2,13 @@ package jdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	v1 "github.com/card-engine/game_common/api/game/v1"
 	client_utils "github.com/card-engine/game_common/api/game/v1/client"
 	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/session"
 	"github.com/card-engine/game_common/gamehub/types"
 	"github.com/card-engine/game_common/player"
 	"github.com/card-engine/game_common/sfs/protocol"
@@ -32,6 +34,10 @@ type JDBRouter struct {
 	lobby types.LobbyImp
 
 	logger log.Logger
+
+	sessions  *session.Manager        // 为空时不限制多处登陆
+	sendQueue common.SendQueueOptions // 玩家连接的发送队列配置
+	flood     common.FloodOptions     // 消息限流，默认不限制
 }
 
 func NewJdbRouter(
@@ -83,11 +89,20 @@ func (r *JDBRouter) Route() {
 			if err := c.Close(); err != nil {
 				r.log.Errorf("close websocket error: %v", err)
 			}
+			// 连接释放前停止写协程
+			if player != nil {
+				player.SetConn(nil)
+			}
 		}()
 
+		guard := common.NewFloodGuard(r.flood)
+		guard.Watch(c)
+
 		// 第一阶段：握手
+		guard.BeforeRead(c)
 		_, msg, err := c.ReadMessage()
 		if err != nil {
+			guard.OnReadError(err)
 			r.log.Errorf("read websocket message error: %v", err)
 			return
 		}
@@ -97,8 +112,10 @@ func (r *JDBRouter) Route() {
 		}
 
 		// 第二阶段：smartfoxserver层面的登录
+		guard.BeforeRead(c)
 		_, msg, err = c.ReadMessage()
 		if err != nil {
+			guard.OnReadError(err)
 			r.log.Errorf("read websocket message error: %v", err)
 			return
 		}
@@ -108,8 +125,10 @@ func (r *JDBRouter) Route() {
 		}
 
 		// 第三阶段：游戏层的登录
+		guard.BeforeRead(c)
 		_, msg, err = c.ReadMessage()
 		if err != nil {
+			guard.OnReadError(err)
 			r.log.Errorf("read websocket message error: %v", err)
 			return
 		}
@@ -122,13 +141,15 @@ func (r *JDBRouter) Route() {
 
 		// 第三阶段：正常消息处理
 		for {
+			guard.BeforeRead(c)
 			_, msg, err := c.ReadMessage()
 			if err != nil {
+				guard.OnReadError(err)
 				r.log.Errorf("read websocket message error: %v", err)
 				break
 			}
 
-			if err := r.onMessage(player, msg); err != nil {
+			if err := r.onMessage(player, guard, msg); err != nil {
 				r.log.Errorf("handle message error: %v", err)
 				break
 			}
@@ -272,6 +293,7 @@ func (r *JDBRouter) onLogin(c *websocket.Conn, buff []byte) (types.PlayerImp, er
 	// }
 
 	player := common.NewPlayer(types.GameBrand_Jdb, c, playerInfo, r.rtpGrpcConn, r.log)
+	player.SetSendQueueOptions(r.sendQueue)
 
 	// 初使化金币
 	balanceRsp, err := client_utils.Balance(context.Background(), r.apiGrpcConn, playerInfo.AppID, &v1.BalanceRequest{
@@ -299,18 +321,35 @@ func (r *JDBRouter) onLogin(c *websocket.Conn, buff []byte) (types.PlayerImp, er
 	// 	conn.Close()
 	// 	return nil, err
 	// }
+	// 踢掉其它地方的登陆，jdb没有对应的提示消息，直接断开
+	if r.sessions != nil {
+		if err := r.sessions.Login(player, nil); err != nil {
+			r.log.Errorf("session Login failed: %v", err)
+			return nil, err
+		}
+	}
+
 	if err := r.lobby.OnLogin(player); err != nil {
 		return nil, err
 	}
 	return player, nil
 }
 
-func (s *JDBRouter) onMessage(player types.PlayerImp, buff []byte) error {
+func (s *JDBRouter) onMessage(player types.PlayerImp, guard *common.FloodGuard, buff []byte) error {
 	action, controller, data, err := utils.Unpack(buff)
 	if err != nil {
 		return err
 	}
 
+	// jdb没有对应的错误消息，超过限制的消息直接丢弃
+	cmd, _ := data["c"].(string)
+	if err := guard.Allow(cmd); err != nil {
+		if errors.Is(err, common.ErrRateLimited) {
+			return nil
+		}
+		return err
+	}
+
 	// ping
 	if action == 29 && controller == 0 {
 		return player.SendBinary(buff)
@@ -330,7 +369,25 @@ func (s *JDBRouter) onMessage(player types.PlayerImp, buff []byte) error {
 	return nil
 }
 
+// 设置消息限流、最大消息长度和空闲超时，需要在Start之前调用
+func (s *JDBRouter) SetFloodOptions(opts common.FloodOptions) {
+	s.flood = opts
+}
+
+// 设置会话管理，同一个玩家只能在一个地方登陆
+func (s *JDBRouter) SetSessionManager(sessions *session.Manager) {
+	s.sessions = sessions
+}
+
+// 设置玩家连接的发送队列，需要在Start之前调用
+func (s *JDBRouter) SetSendQueueOptions(opts common.SendQueueOptions) {
+	s.sendQueue = opts
+}
+
 func (s *JDBRouter) onDisconnect(player types.PlayerImp) error {
+	if s.sessions != nil {
+		s.sessions.Logout(player)
+	}
 	return s.roomManager.OnDisConnect(player)
 }
 
@@ -2,12 +2,14 @@ package jili
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 
 	v1 "github.com/card-engine/game_common/api/game/v1"
 	client_utils "github.com/card-engine/game_common/api/game/v1/client"
 	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/session"
 	"github.com/card-engine/game_common/gamehub/types"
 	"github.com/card-engine/game_common/jili/fish/message"
 	"github.com/card-engine/game_common/player"
@@ -31,6 +33,10 @@ type JiliRouter struct {
 	lobby types.LobbyImp
 
 	logger log.Logger
+
+	sessions  *session.Manager        // 为空时不限制多处登陆
+	sendQueue common.SendQueueOptions // 玩家连接的发送队列配置
+	flood     common.FloodOptions     // 消息限流，默认不限制
 }
 
 func NewJiliRouter(
@@ -108,7 +114,11 @@ func (s *JiliRouter) onWebSocketHandler(token, retryCount string, c *websocket.C
 	// 	return err
 	// }
 
+	guard := common.NewFloodGuard(s.flood)
+	guard.Watch(c)
+
 	jiliPlayer := common.NewPlayer(types.GameBrand_Jili, c, playerInfo, s.rtpGrpcConn, s.log)
+	jiliPlayer.SetSendQueueOptions(s.sendQueue)
 
 	defer func() {
 		if jiliPlayer != nil {
@@ -120,6 +130,10 @@ func (s *JiliRouter) onWebSocketHandler(token, retryCount string, c *websocket.C
 		if err := c.Close(); err != nil {
 			s.log.Errorf("close websocket error: %v", err)
 		}
+		// 连接释放前停止写协程
+		if jiliPlayer != nil {
+			jiliPlayer.SetConn(nil)
+		}
 	}()
 
 	// 初使化金币
@@ -138,14 +152,24 @@ func (s *JiliRouter) onWebSocketHandler(token, retryCount string, c *websocket.C
 		return err
 	}
 
+	// 踢掉其它地方的登陆，jili没有对应的提示消息，直接断开
+	if s.sessions != nil {
+		if err := s.sessions.Login(jiliPlayer, nil); err != nil {
+			s.log.Errorf("session Login failed: %v", err)
+			return err
+		}
+	}
+
 	if err := s.lobby.OnLogin(jiliPlayer); err != nil {
 		return err
 	}
 
 	for {
+		guard.BeforeRead(c)
 		_, msg, err := c.ReadMessage()
 
 		if err != nil {
+			guard.OnReadError(err)
 			s.log.Errorf("read websocket message error: %v", err)
 			break
 		}
@@ -156,6 +180,14 @@ func (s *JiliRouter) onWebSocketHandler(token, retryCount string, c *websocket.C
 			continue
 		}
 
+		// jili没有对应的错误消息，超过限制的消息直接丢弃
+		if err := guard.Allow(strconv.FormatUint(uint64(data.GetType()), 10)); err != nil {
+			if errors.Is(err, common.ErrRateLimited) {
+				continue
+			}
+			return err
+		}
+
 		// 如果有大厅的话，将消息转发至大厅
 		if s.lobby != nil {
 			if err := s.lobby.OnMessage(jiliPlayer, data); err != nil {
@@ -171,6 +203,24 @@ func (s *JiliRouter) onWebSocketHandler(token, retryCount string, c *websocket.C
 	return nil
 }
 
+// 设置消息限流、最大消息长度和空闲超时，需要在Start之前调用
+func (s *JiliRouter) SetFloodOptions(opts common.FloodOptions) {
+	s.flood = opts
+}
+
+// 设置会话管理，同一个玩家只能在一个地方登陆
+func (s *JiliRouter) SetSessionManager(sessions *session.Manager) {
+	s.sessions = sessions
+}
+
+// 设置玩家连接的发送队列，需要在Start之前调用
+func (s *JiliRouter) SetSendQueueOptions(opts common.SendQueueOptions) {
+	s.sendQueue = opts
+}
+
 func (s *JiliRouter) onDisconnect(player types.PlayerImp) error {
+	if s.sessions != nil {
+		s.sessions.Logout(player)
+	}
 	return s.roomManager.OnDisConnect(player)
 }
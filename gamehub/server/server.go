@@ -2,30 +2,46 @@ package sever
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/fairness"
 	"github.com/card-engine/game_common/gamehub/inout"
 	"github.com/card-engine/game_common/gamehub/jdb"
 	"github.com/card-engine/game_common/gamehub/jili"
+	"github.com/card-engine/game_common/gamehub/session"
+	"github.com/card-engine/game_common/gamehub/spectator"
 	"github.com/card-engine/game_common/gamehub/spribe"
 	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/health"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 
 	google_grpc "google.golang.org/grpc"
 )
 
+const DefaultDrainTimeout = 30 * time.Second
+
 type GameApiServer struct {
 	app *fiber.App
 	log *log.Helper
 
-	serverAddr string //服务器绑定的地址
-	router     types.Router
+	serverAddr  string //服务器绑定的地址
+	router      types.Router
+	roomManager *common.RoomManager
+	sessions    *session.Manager
+	logger      log.Logger
+	rdb         *redis.Client
+
+	readiness    *health.Readiness
+	drainTimeout time.Duration // 下线时等待房间结束当前局的最长时间
 
 	endpoint *url.URL
 	lis      net.Listener
@@ -66,9 +82,15 @@ func InitGameApiServerWithLobby(
 		log: log.NewHelper(logger),
 
 		serverAddr: serverAddr,
+		logger:     logger,
+		rdb:        rdb,
+
+		readiness:    health.NewReadiness(),
+		drainTimeout: DefaultDrainTimeout,
 	}
 
 	roomManager := common.NewRoomManager(gameBrand, roomCreator, tableMatcherType, logger)
+	s.roomManager = roomManager
 
 	var lobby types.LobbyImp = nil
 	if lobbyCreator != nil {
@@ -97,9 +119,118 @@ func (s *GameApiServer) route() {
 		s.log.Fatalf("router is nil")
 	}
 
+	// 公平性校验，任何人都可以用公开的种子复算结果
+	s.app.Get("/fairness/verify", fairness.VerifyHandler)
+
 	s.router.Route()
 }
 
+// 设置配桌算法，TableMatcherType_CUSTOM时必须在Start之前调用
+func (s *GameApiServer) SetTableMatcher(matcher types.TableMatcher) {
+	s.roomManager.SetTableMatcher(matcher)
+}
+
+// 开启座位超时，断线超过grace没有重连则释放座位，推荐const_val.ReconnectGraceTime秒。
+// 默认为0，一直保留座位直到房间自己调用ExitRoom
+func (s *GameApiServer) SetReconnectGrace(grace time.Duration) {
+	s.roomManager.SetReconnectGrace(grace)
+}
+
+// 支持单点登陆的路由
+type sessionRouter interface {
+	SetSessionManager(sessions *session.Manager)
+}
+
+// 开启单点登陆，同一个玩家在任何节点的新登陆都会踢掉旧的会话，需要在Start之前调用。
+// nodeId为空时自动生成
+func (s *GameApiServer) EnableSingleLogin(registry session.Registry, nodeId string) error {
+	router, ok := s.router.(sessionRouter)
+	if !ok {
+		return fmt.Errorf("router %T does not support single login", s.router)
+	}
+	sessions, err := session.NewManager(registry, nodeId, s.logger)
+	if err != nil {
+		return err
+	}
+	s.sessions = sessions
+	router.SetSessionManager(sessions)
+	return nil
+}
+
+// 支持配置发送队列的路由
+type sendQueueRouter interface {
+	SetSendQueueOptions(opts common.SendQueueOptions)
+}
+
+// 设置玩家连接的发送队列大小、溢出策略和写超时，需要在Start之前调用
+func (s *GameApiServer) SetSendQueueOptions(opts common.SendQueueOptions) error {
+	router, ok := s.router.(sendQueueRouter)
+	if !ok {
+		return fmt.Errorf("router %T does not support send queue options", s.router)
+	}
+	router.SetSendQueueOptions(opts)
+	return nil
+}
+
+// 支持消息限流的路由
+type floodRouter interface {
+	SetFloodOptions(opts common.FloodOptions)
+}
+
+// 设置每个连接的消息限流、最大消息长度和空闲超时，需要在Start之前调用，
+// 可以使用common.DefaultFloodOptions()
+func (s *GameApiServer) SetFloodOptions(opts common.FloodOptions) error {
+	router, ok := s.router.(floodRouter)
+	if !ok {
+		return fmt.Errorf("router %T does not support flood options", s.router)
+	}
+	router.SetFloodOptions(opts)
+	return nil
+}
+
+// 支持观战的路由
+type spectatorRouter interface {
+	RouteSpectator(gate *spectator.Gate)
+}
+
+// 开启观战，需要在Start之前调用，房间需要实现types.SpectatableRoomImp
+func (s *GameApiServer) EnableSpectators(cfg spectator.Config) *spectator.Gate {
+	router, ok := s.router.(spectatorRouter)
+	if !ok {
+		s.log.Errorf("router %T does not support spectators", s.router)
+		return nil
+	}
+	gate := spectator.NewGate(cfg)
+	router.RouteSpectator(gate)
+	return gate
+}
+
+// 管理员踢人时通知玩家原因的路由
+type kickNoticeRouter interface {
+	KickNotice(player types.PlayerImp, reason string)
+}
+
+// 开启管理接口，挂在/admin下，请求需要带Authorization: Bearer <token>，需要在Start之前调用
+func (s *GameApiServer) EnableAdmin(token string) error {
+	if token == "" {
+		return fmt.Errorf("admin token is required")
+	}
+	admin := s.app.Group("/admin", func(c *fiber.Ctx) error {
+		auth := c.Get(fiber.HeaderAuthorization)
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+			return fiber.ErrUnauthorized
+		}
+		return c.Next()
+	})
+
+	var notice func(types.PlayerImp, string)
+	if router, ok := s.router.(kickNoticeRouter); ok {
+		notice = router.KickNotice
+	}
+	s.roomManager.RegisterAdminRoutes(admin, notice)
+	return nil
+}
+
 func (s *GameApiServer) Start(ctx context.Context) error {
 	if err := s.ensureListener(); err != nil {
 		return err
@@ -127,7 +258,60 @@ func normalizeListenAddr(addr string) string {
 	return a
 }
 
+// 发送重连提示的路由，排空结束断开连接前发给玩家
+type reconnectHintRouter interface {
+	ReconnectHint(player types.PlayerImp)
+}
+
+// 设置下线时等待房间结束当前局的最长时间
+func (s *GameApiServer) SetDrainTimeout(timeout time.Duration) {
+	s.drainTimeout = timeout
+}
+
+// 设置排空超时后处理未结束对局的钩子，用于退款或者结算
+func (s *GameApiServer) SetUnfinishedRoundHook(hook types.UnfinishedRoundHook) {
+	s.roomManager.SetUnfinishedRoundHook(hook)
+}
+
+// 开启存活和就绪检查(/health/livez、/health/readyz)，排空时就绪检查失败，需要在Start之前调用。
+// db为nil时不检查数据库；自己挂载健康检查的可以用health.WithReadiness(s.Readiness())
+func (s *GameApiServer) EnableHealthCheck(db *gorm.DB, opts ...health.HealthCheckOption) {
+	if db == nil {
+		opts = append([]health.HealthCheckOption{health.WithDBChecker(nil)}, opts...)
+	}
+	opts = append(opts, health.WithReadiness(s.readiness))
+	s.app.Use(health.Check(db, s.rdb, opts...))
+}
+
+// 就绪开关，Drain时置为未就绪
+func (s *GameApiServer) Readiness() *health.Readiness {
+	return s.readiness
+}
+
+// 排空: 标记未就绪，不再接受新的玩家，等房间结束当前局后发送重连提示并断开
+func (s *GameApiServer) Drain(ctx context.Context) {
+	s.readiness.SetReady(false)
+
+	if s.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.drainTimeout)
+		defer cancel()
+	}
+
+	var hint func(types.PlayerImp)
+	if router, ok := s.router.(reconnectHintRouter); ok {
+		hint = router.ReconnectHint
+	}
+	if unfinished := s.roomManager.Drain(ctx, hint); unfinished > 0 {
+		s.log.Warnf("drain finished with %d unfinished rooms", unfinished)
+	}
+}
+
 func (s *GameApiServer) Stop(ctx context.Context) error {
+	s.Drain(ctx)
+	if s.sessions != nil {
+		s.sessions.Close()
+	}
 	if s.app != nil {
 		s.app.Shutdown()
 		s.app = nil
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+const registryTimeout = 3 * time.Second
+
+// 被踢下线之前发给玩家的品牌消息，为空时直接断开连接
+type Notify func(player types.PlayerImp)
+
+type localSession struct {
+	player types.PlayerImp
+	epoch  int64
+	notify Notify
+}
+
+// 本节点的会话，负责登记、注销和处理踢下线通知
+type Manager struct {
+	nodeId   string
+	registry Registry
+	log      *log.Helper
+
+	mu          sync.Mutex
+	sessions    map[string]*localSession
+	unsubscribe func()
+}
+
+// nodeId为空时使用 主机名-随机串，每次启动都不同，这样重启前的会话不会被误认为在本节点
+func NewManager(registry Registry, nodeId string, logger log.Logger) (*Manager, error) {
+	if nodeId == "" {
+		host, _ := os.Hostname()
+		nodeId = host + "-" + uuid.NewString()[:8]
+	}
+	m := &Manager{
+		nodeId:   nodeId,
+		registry: registry,
+		log:      log.NewHelper(log.With(logger, "module", "session")),
+		sessions: make(map[string]*localSession),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+	unsubscribe, err := registry.Subscribe(ctx, nodeId, m.onKick)
+	if err != nil {
+		return nil, err
+	}
+	m.unsubscribe = unsubscribe
+	return m, nil
+}
+
+func (m *Manager) NodeId() string {
+	return m.nodeId
+}
+
+// 玩家登陆，旧的会话不管在哪个节点都会被踢下线
+func (m *Manager) Login(player types.PlayerImp, notify Notify) error {
+	ident := player.GetPlayerIdent()
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	epoch, old, err := m.registry.Register(ctx, ident, m.nodeId)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	prev := m.sessions[ident]
+	m.sessions[ident] = &localSession{player: player, epoch: epoch, notify: notify}
+	m.mu.Unlock()
+
+	// 旧会话在本节点，直接踢掉
+	if prev != nil && prev.player != player {
+		m.kick(ident, prev)
+	}
+
+	if old != nil && old.NodeId != m.nodeId {
+		if err := m.registry.Kick(ctx, old.NodeId, Kick{PlayerIdent: ident, Epoch: epoch}); err != nil {
+			m.log.Errorf("kick %s on %s failed: %v", ident, old.NodeId, err)
+		}
+	}
+	return nil
+}
+
+// 连接断开，只有还是当前会话时才注销
+func (m *Manager) Logout(player types.PlayerImp) {
+	ident := player.GetPlayerIdent()
+
+	m.mu.Lock()
+	s, ok := m.sessions[ident]
+	if !ok || s.player != player {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.sessions, ident)
+	m.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+	if err := m.registry.Unregister(ctx, ident, s.epoch); err != nil {
+		m.log.Errorf("unregister %s failed: %v", ident, err)
+	}
+}
+
+func (m *Manager) Close() {
+	if m.unsubscribe != nil {
+		m.unsubscribe()
+	}
+}
+
+func (m *Manager) onKick(kick Kick) {
+	m.mu.Lock()
+	s, ok := m.sessions[kick.PlayerIdent]
+	if !ok || s.epoch >= kick.Epoch {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.sessions, kick.PlayerIdent)
+	m.mu.Unlock()
+
+	m.kick(kick.PlayerIdent, s)
+}
+
+func (m *Manager) kick(ident string, s *localSession) {
+	m.log.Infof("%s logged in elsewhere, kick session %d", ident, s.epoch)
+	if s.notify != nil {
+		s.notify(s.player)
+	}
+	// 关闭连接后路由的读循环退出，按正常断线处理
+	s.player.CloseConn()
+}
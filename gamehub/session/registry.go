@@ -0,0 +1,209 @@
+// 跨节点的登陆会话: 同一个玩家只能有一个在线会话，新的登陆会把其它节点(或本节点)上的旧会话踢下线。
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisSessionKeyPrefix = "session:player:"
+	redisEpochKeyPrefix   = "session:epoch:"
+	redisKickChannel      = "session:kick:"
+	// 会话在登陆时续期，长时间没有重新登陆的会话自动过期
+	redisSessionTTL = 24 * time.Hour
+)
+
+var ErrClosed = errors.New("session: registry closed")
+
+// 一个玩家当前的会话，key为玩家唯一标识(PlayerImp.GetPlayerIdent)
+type Session struct {
+	PlayerIdent string `json:"playerIdent"`
+	NodeId      string `json:"nodeId"`
+	Epoch       int64  `json:"epoch"` // 每次登陆递增，旧的会话epoch更小
+}
+
+// 踢下线通知，发给旧会话所在的节点，节点上epoch小于Epoch的会话都要下线
+type Kick struct {
+	PlayerIdent string `json:"playerIdent"`
+	Epoch       int64  `json:"epoch"`
+}
+
+type Registry interface {
+	// 登记新的会话并返回它的epoch，old为被替换掉的会话(没有时为nil)
+	Register(ctx context.Context, playerIdent, nodeId string) (epoch int64, old *Session, err error)
+	// epoch一致时删除会话，已经被新的会话替换时什么都不做
+	Unregister(ctx context.Context, playerIdent string, epoch int64) error
+	// 玩家当前的会话，没有时返回nil
+	Get(ctx context.Context, playerIdent string) (*Session, error)
+	// 通知节点踢掉旧会话
+	Kick(ctx context.Context, nodeId string, kick Kick) error
+	// 接收发给本节点的踢下线通知，返回取消订阅的函数
+	Subscribe(ctx context.Context, nodeId string, handler func(Kick)) (func(), error)
+}
+
+// ========================================================================================
+
+type RedisRegistry struct {
+	rdb *redis.Client
+}
+
+func NewRedisRegistry(rdb *redis.Client) *RedisRegistry {
+	return &RedisRegistry{rdb: rdb}
+}
+
+// 递增epoch并替换会话，返回 {epoch, 旧node, 旧epoch}
+var registerScript = redis.NewScript(`
+local epoch = redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+local old = redis.call('HMGET', KEYS[1], 'node', 'epoch')
+redis.call('HSET', KEYS[1], 'node', ARGV[1], 'epoch', epoch)
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return {epoch, old[1] or '', old[2] or ''}
+`)
+
+var unregisterScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'epoch') == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (r *RedisRegistry) Register(ctx context.Context, playerIdent, nodeId string) (int64, *Session, error) {
+	res, err := registerScript.Run(ctx, r.rdb,
+		[]string{redisSessionKeyPrefix + playerIdent, redisEpochKeyPrefix + playerIdent},
+		nodeId, int64(redisSessionTTL/time.Second)).Slice()
+	if err != nil {
+		return 0, nil, err
+	}
+	epoch, _ := res[0].(int64)
+	oldNode, _ := res[1].(string)
+	if oldNode == "" {
+		return epoch, nil, nil
+	}
+	oldEpochStr, _ := res[2].(string)
+	oldEpoch, _ := strconv.ParseInt(oldEpochStr, 10, 64)
+	return epoch, &Session{PlayerIdent: playerIdent, NodeId: oldNode, Epoch: oldEpoch}, nil
+}
+
+func (r *RedisRegistry) Unregister(ctx context.Context, playerIdent string, epoch int64) error {
+	return unregisterScript.Run(ctx, r.rdb, []string{redisSessionKeyPrefix + playerIdent}, epoch).Err()
+}
+
+func (r *RedisRegistry) Get(ctx context.Context, playerIdent string) (*Session, error) {
+	values, err := r.rdb.HMGet(ctx, redisSessionKeyPrefix+playerIdent, "node", "epoch").Result()
+	if err != nil {
+		return nil, err
+	}
+	node, _ := values[0].(string)
+	if node == "" {
+		return nil, nil
+	}
+	epochStr, _ := values[1].(string)
+	epoch, _ := strconv.ParseInt(epochStr, 10, 64)
+	return &Session{PlayerIdent: playerIdent, NodeId: node, Epoch: epoch}, nil
+}
+
+func (r *RedisRegistry) Kick(ctx context.Context, nodeId string, kick Kick) error {
+	payload, err := json.Marshal(kick)
+	if err != nil {
+		return err
+	}
+	return r.rdb.Publish(ctx, redisKickChannel+nodeId, payload).Err()
+}
+
+func (r *RedisRegistry) Subscribe(ctx context.Context, nodeId string, handler func(Kick)) (func(), error) {
+	sub := r.rdb.Subscribe(ctx, redisKickChannel+nodeId)
+	// 等待订阅成功，避免订阅之前发布的通知丢失
+	if _, err := sub.Receive(ctx); err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			var kick Kick
+			if err := json.Unmarshal([]byte(msg.Payload), &kick); err != nil {
+				continue
+			}
+			handler(kick)
+		}
+	}()
+	return func() { sub.Close() }, nil
+}
+
+// ========================================================================================
+
+// 单进程使用，或者测试中模拟多个节点
+type MemoryRegistry struct {
+	mu       sync.Mutex
+	epochs   map[string]int64
+	sessions map[string]Session
+	handlers map[string]func(Kick)
+}
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		epochs:   make(map[string]int64),
+		sessions: make(map[string]Session),
+		handlers: make(map[string]func(Kick)),
+	}
+}
+
+func (r *MemoryRegistry) Register(ctx context.Context, playerIdent, nodeId string) (int64, *Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.epochs[playerIdent]++
+	epoch := r.epochs[playerIdent]
+	var old *Session
+	if s, ok := r.sessions[playerIdent]; ok {
+		old = &s
+	}
+	r.sessions[playerIdent] = Session{PlayerIdent: playerIdent, NodeId: nodeId, Epoch: epoch}
+	return epoch, old, nil
+}
+
+func (r *MemoryRegistry) Unregister(ctx context.Context, playerIdent string, epoch int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[playerIdent]; ok && s.Epoch == epoch {
+		delete(r.sessions, playerIdent)
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) Get(ctx context.Context, playerIdent string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[playerIdent]; ok {
+		return &s, nil
+	}
+	return nil, nil
+}
+
+func (r *MemoryRegistry) Kick(ctx context.Context, nodeId string, kick Kick) error {
+	r.mu.Lock()
+	handler := r.handlers[nodeId]
+	r.mu.Unlock()
+	if handler != nil {
+		go handler(kick)
+	}
+	return nil
+}
+
+func (r *MemoryRegistry) Subscribe(ctx context.Context, nodeId string, handler func(Kick)) (func(), error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[nodeId] = handler
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.handlers, nodeId)
+	}, nil
+}
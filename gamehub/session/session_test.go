@@ -0,0 +1,97 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type testPlayer struct {
+	types.PlayerImp
+	ident  string
+	closed chan struct{}
+}
+
+func newTestPlayer(ident string) *testPlayer {
+	return &testPlayer{ident: ident, closed: make(chan struct{})}
+}
+
+func (p *testPlayer) GetPlayerIdent() string { return p.ident }
+func (p *testPlayer) CloseConn()             { close(p.closed) }
+
+func (p *testPlayer) isClosed() bool {
+	select {
+	case <-p.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestKickAcrossNodes(t *testing.T) {
+	registry := NewMemoryRegistry()
+	nodeA, err := NewManager(registry, "a", log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeB, _ := NewManager(registry, "b", log.DefaultLogger)
+
+	old := newTestPlayer("app-p1")
+	notified := make(chan types.PlayerImp, 1)
+	if err := nodeA.Login(old, func(p types.PlayerImp) { notified <- p }); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := newTestPlayer("app-p1")
+	if err := nodeB.Login(fresh, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case p := <-notified:
+		if p != old {
+			t.Fatal("notified the wrong player")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("old session was not kicked")
+	}
+	<-old.closed
+
+	// 旧会话断开时不能注销新会话
+	nodeA.Logout(old)
+	s, _ := registry.Get(context.Background(), "app-p1")
+	if s == nil || s.NodeId != "b" || s.Epoch != 2 {
+		t.Fatalf("unexpected session %+v", s)
+	}
+
+	nodeB.Logout(fresh)
+	if s, _ := registry.Get(context.Background(), "app-p1"); s != nil {
+		t.Fatalf("session should be removed, got %+v", s)
+	}
+	if fresh.isClosed() {
+		t.Fatal("new session should stay connected")
+	}
+}
+
+func TestKickSameNode(t *testing.T) {
+	m, _ := NewManager(NewMemoryRegistry(), "", log.DefaultLogger)
+	if m.NodeId() == "" {
+		t.Fatal("node id should be generated")
+	}
+
+	old, fresh := newTestPlayer("app-p1"), newTestPlayer("app-p1")
+	m.Login(old, nil)
+	m.Login(fresh, nil)
+	if !old.isClosed() || fresh.isClosed() {
+		t.Fatal("only the old session should be closed")
+	}
+
+	// 过期的踢下线通知不影响新的会话
+	m.onKick(Kick{PlayerIdent: "app-p1", Epoch: 1})
+	if fresh.isClosed() {
+		t.Fatal("stale kick closed the new session")
+	}
+}
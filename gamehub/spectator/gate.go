@@ -0,0 +1,75 @@
+package spectator
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrTooManySpectators = errors.New("spectator: too many spectators")
+
+const (
+	DefaultMaxSpectators       = 2000
+	DefaultMaxSpectatorsPerApp = 500
+)
+
+type Config struct {
+	Secret    []byte
+	MaxTotal  int // 整个服务最多多少观战连接，与玩家连接分开计算
+	MaxPerApp int // 每个商户最多多少观战连接
+}
+
+// 校验token并限制观战连接数
+type Gate struct {
+	cfg Config
+	now func() time.Time
+
+	mu    sync.Mutex
+	total int
+	apps  map[string]int
+}
+
+func NewGate(cfg Config) *Gate {
+	if cfg.MaxTotal <= 0 {
+		cfg.MaxTotal = DefaultMaxSpectators
+	}
+	if cfg.MaxPerApp <= 0 {
+		cfg.MaxPerApp = DefaultMaxSpectatorsPerApp
+	}
+	return &Gate{cfg: cfg, now: time.Now, apps: make(map[string]int)}
+}
+
+// 校验token并占用一个连接名额，连接断开时必须调用release
+func (g *Gate) Admit(token string) (claims *Claims, release func(), err error) {
+	claims, err = Parse(g.cfg.Secret, token, g.now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.total >= g.cfg.MaxTotal || g.apps[claims.AppId] >= g.cfg.MaxPerApp {
+		return nil, nil, ErrTooManySpectators
+	}
+	g.total++
+	g.apps[claims.AppId]++
+
+	var once sync.Once
+	return claims, func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.total--
+			if g.apps[claims.AppId]--; g.apps[claims.AppId] <= 0 {
+				delete(g.apps, claims.AppId)
+			}
+		})
+	}, nil
+}
+
+// 当前的观战连接数
+func (g *Gate) Count() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.total
+}
@@ -0,0 +1,193 @@
+package spectator
+
+import (
+	"errors"
+	"sync"
+
+	v1 "github.com/card-engine/game_common/api/game/v1"
+	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/types"
+	"github.com/card-engine/game_common/player"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+var ErrSpectator = errors.New("spectator: watch only")
+
+// 观战玩家，实现types.PlayerImp和types.SpectatorImp，所有钱包相关的操作都会失败
+type Spectator struct {
+	id     string
+	claims Claims
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+
+	sendOpts common.SendQueueOptions // 与玩家相同的发送队列，广播不会被慢的观战连接阻塞
+	queue    *common.SendQueue
+
+	roomMu      sync.RWMutex // 销毁房间和排空时在其它协程中修改，与连接的读协程并发
+	room        types.RoomImp
+	roomManager types.RoomManagerImp
+}
+
+func New(conn *websocket.Conn, claims *Claims) *Spectator {
+	return &Spectator{id: "spectator:" + uuid.NewString(), claims: *claims, conn: conn}
+}
+
+func (s *Spectator) IsSpectator() bool { return true }
+
+func (s *Spectator) Claims() Claims { return s.claims }
+
+// 观战的房间，与NoLobby的rtp配桌规则一致
+func (s *Spectator) RoomType() string {
+	return s.claims.AppId + "-" + s.claims.Rtp
+}
+
+func (s *Spectator) RoomArgs() *types.RtpRoomArgs {
+	return &types.RtpRoomArgs{Appid: s.claims.AppId, Rtp: s.claims.Rtp, Currency: s.claims.Currency}
+}
+
+// 设置发送队列，需要在第一次发送之前调用
+func (s *Spectator) SetSendQueueOptions(opts common.SendQueueOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendOpts = opts
+}
+
+// 发送队列的状态，还没有发送过消息时为空
+func (s *Spectator) SendQueueStats() common.SendQueueStats {
+	s.mu.Lock()
+	q := s.queue
+	s.mu.Unlock()
+	if q == nil {
+		return common.SendQueueStats{}
+	}
+	return q.Stats()
+}
+
+// 更换连接时丢弃旧连接还没写出的消息
+func (s *Spectator) SetConn(conn *websocket.Conn) {
+	s.mu.Lock()
+	q := s.queue
+	s.queue = nil
+	s.conn = conn
+	s.mu.Unlock()
+	if q != nil {
+		q.Close(false)
+	}
+}
+
+func (s *Spectator) GetConn() *websocket.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+// 等待排队的消息写完(最长FlushTimeout)后关闭连接
+func (s *Spectator) CloseConn() {
+	s.mu.Lock()
+	conn, q := s.conn, s.queue
+	s.conn, s.queue = nil, nil
+	s.mu.Unlock()
+	if q != nil {
+		q.Close(true)
+	}
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+func (s *Spectator) IsConnect() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn != nil
+}
+
+func (s *Spectator) SetRoom(room types.RoomImp) {
+	s.roomMu.Lock()
+	defer s.roomMu.Unlock()
+	s.room = room
+}
+
+func (s *Spectator) GetRoom() types.RoomImp {
+	s.roomMu.RLock()
+	defer s.roomMu.RUnlock()
+	return s.room
+}
+
+func (s *Spectator) ExitRoom(isDisconnect bool) error {
+	if roomManager := s.GetRoomManager(); roomManager != nil {
+		roomManager.ExitRoom(s, isDisconnect)
+	}
+	return nil
+}
+
+func (s *Spectator) GetRoomManager() types.RoomManagerImp {
+	s.roomMu.RLock()
+	defer s.roomMu.RUnlock()
+	return s.roomManager
+}
+
+func (s *Spectator) SetRoomManager(roomManager types.RoomManagerImp) {
+	s.roomMu.Lock()
+	defer s.roomMu.Unlock()
+	s.roomManager = roomManager
+}
+
+func (s *Spectator) GetBalance() float64 { return 0 }
+func (s *Spectator) SetBalanceByBalanceReply(balanceReply *v1.BalanceReply) error {
+	return ErrSpectator
+}
+func (s *Spectator) SetBalanceByWinReply(winReply *v1.WinReply) error { return ErrSpectator }
+func (s *Spectator) SetBalanceByBetReply(betReply *v1.BetReply) error { return ErrSpectator }
+func (s *Spectator) SetBalanceByRefundReply(refundReply *v1.RefundReply) error {
+	return ErrSpectator
+}
+
+func (s *Spectator) GetPlayerIdent() string { return s.claims.AppId + "-" + s.id }
+func (s *Spectator) GetPlayerInfo() *player.PlayerInfo {
+	return &player.PlayerInfo{
+		RTP:      s.claims.Rtp,
+		AppID:    s.claims.AppId,
+		PlayerID: s.id,
+		GameID:   s.claims.GameId,
+		Currency: s.claims.Currency,
+		Lang:     s.claims.Lang,
+	}
+}
+func (s *Spectator) GetPlayerId() string { return s.id }
+func (s *Spectator) GetAppId() string    { return s.claims.AppId }
+func (s *Spectator) GetCurrency() string { return s.claims.Currency }
+func (s *Spectator) GetLang() string {
+	if s.claims.Lang == "" {
+		return "en"
+	}
+	return s.claims.Lang
+}
+func (s *Spectator) GetRtpStr() string { return s.claims.Rtp }
+func (s *Spectator) GetRtp() float64   { return 0 }
+
+func (s *Spectator) SendString(msg string) error {
+	return s.send(websocket.TextMessage, []byte(msg))
+}
+
+func (s *Spectator) SendBinary(data []byte) error {
+	return s.send(websocket.BinaryMessage, data)
+}
+
+// 放入发送队列后立即返回，由写协程写出
+func (s *Spectator) send(messageType int, data []byte) error {
+	s.mu.Lock()
+	if s.conn == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.queue == nil {
+		s.queue = common.NewSendQueue(s.conn, s.sendOpts, nil)
+	}
+	q := s.queue
+	s.mu.Unlock()
+	return q.Push(messageType, data)
+}
+
+var _ types.SpectatorImp = (*Spectator)(nil)
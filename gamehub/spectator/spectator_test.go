@@ -0,0 +1,97 @@
+package spectator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	token, err := Issue(secret, Claims{AppId: "app", Rtp: "97", Currency: "USD", ExpiresAt: now.Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := Parse(secret, token, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.AppId != "app" || claims.Rtp != "97" || claims.Currency != "USD" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	if _, err := Parse(secret, token, now.Add(2*time.Minute)); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got %v", err)
+	}
+	// 没有过期时间的token不能使用
+	forever, _ := Issue(secret, Claims{AppId: "app", Rtp: "97"})
+	if _, err := Parse(secret, forever, now); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken without exp, got %v", err)
+	}
+	if _, err := Parse([]byte("other"), token, now); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	for _, bad := range []string{"", "abc", token + "x", "e30." + token[len(token)-43:]} {
+		if _, err := Parse(secret, bad, now); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected ErrInvalidToken for %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestGate(t *testing.T) {
+	secret := []byte("secret")
+	gate := NewGate(Config{Secret: secret, MaxTotal: 3, MaxPerApp: 2})
+	exp := time.Now().Add(time.Minute).Unix()
+	tokenA, _ := Issue(secret, Claims{AppId: "a", Rtp: "97", ExpiresAt: exp})
+	tokenB, _ := Issue(secret, Claims{AppId: "b", Rtp: "97", ExpiresAt: exp})
+
+	_, releaseA1, err := gate.Admit(tokenA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gate.Admit(tokenA); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gate.Admit(tokenA); !errors.Is(err, ErrTooManySpectators) {
+		t.Fatalf("expected per app limit, got %v", err)
+	}
+	if _, _, err := gate.Admit(tokenB); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gate.Admit(tokenB); !errors.Is(err, ErrTooManySpectators) {
+		t.Fatalf("expected total limit, got %v", err)
+	}
+
+	// 重复release只释放一次
+	releaseA1()
+	releaseA1()
+	if gate.Count() != 2 {
+		t.Fatalf("count %d", gate.Count())
+	}
+	if _, _, err := gate.Admit(tokenA); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gate.Admit("bad"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+}
+
+// 房间管理器在其它协程中修改观战玩家的房间
+func TestSpectatorRoomConcurrent(t *testing.T) {
+	s := New(nil, &Claims{AppId: "app", Rtp: "97"})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.SetRoom(nil)
+			s.SetRoomManager(nil)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s.GetRoom()
+		s.ExitRoom(false)
+	}
+	<-done
+}
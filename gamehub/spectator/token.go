@@ -0,0 +1,65 @@
+// 观战: 运营方在大厅页面嵌入只读的对局画面，使用轻量的签名token鉴权，不需要玩家登录和钱包。
+package spectator
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("spectator: invalid token")
+	ErrTokenExpired = errors.New("spectator: token expired")
+)
+
+// token中的内容，决定观看哪个房间
+type Claims struct {
+	AppId     string `json:"appId"`
+	GameId    string `json:"gameId,omitempty"`
+	Rtp       string `json:"rtp"`
+	Currency  string `json:"currency"`
+	Lang      string `json:"lang,omitempty"`
+	ExpiresAt int64  `json:"exp"` // unix秒，必须设置，泄露的token不能一直使用
+}
+
+// 生成token: base64url(json).base64url(hmac-sha256)
+func Issue(secret []byte, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + sign(secret, body), nil
+}
+
+func Parse(secret []byte, token string, now time.Time) (*Claims, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(sign(secret, body))) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.AppId == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt <= 0 {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() > claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+func sign(secret []byte, body string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
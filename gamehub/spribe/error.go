@@ -8,6 +8,8 @@ const (
 	CodeSystemError         = 500 // 系统错误
 	CodeInvalidParameter    = 401 // 参数非法
 	CodeNotBettingStage     = 402 // 当前不是下注阶段
+	CodeLoggedInElsewhere   = 403 // 在其它地方登陆
+	CodeTooManyRequests     = 429 // 请求太频繁
 )
 
 // 语言类型定义
@@ -154,6 +156,62 @@ func init() {
 		LangElGR: "Δεν βρίσκεται στο στάδιο στοιχηματισμού",
 		LangFrFR: "Pas dans la phase de pari",
 	}
+
+	// 在其它地方登陆
+	errorMessages[CodeLoggedInElsewhere] = map[Language]string{
+		LangZhCN: "您的账号已在其它地方登录",
+		LangEnUS: "You have logged in from another device",
+		LangThTH: "บัญชีของคุณเข้าสู่ระบบจากอุปกรณ์อื่น",
+		LangViVN: "Tài khoản của bạn đã đăng nhập trên thiết bị khác",
+		LangIdID: "Akun Anda telah masuk dari perangkat lain",
+		LangHiIN: "आपने किसी अन्य डिवाइस से लॉग इन किया है",
+		LangTaIN: "நீங்கள் வேறு சாதனத்திலிருந்து உள்நுழைந்துள்ளீர்கள்",
+		LangMyMM: "သင်သည် အခြားစက်ပစ္စည်းမှ ဝင်ရောက်ထားပါသည်",
+		LangJaJP: "別の端末でログインしました",
+		LangMsMY: "Anda telah log masuk dari peranti lain",
+		LangKoKR: "다른 기기에서 로그인되었습니다",
+		LangBnIN: "আপনি অন্য ডিভাইস থেকে লগ ইন করেছেন",
+		LangEsAR: "Has iniciado sesión desde otro dispositivo",
+		LangPtBR: "Você entrou a partir de outro dispositivo",
+		LangItIT: "Hai effettuato l'accesso da un altro dispositivo",
+		LangSvSE: "Du har loggat in från en annan enhet",
+		LangDeDE: "Sie haben sich auf einem anderen Gerät angemeldet",
+		LangDaDK: "Du er logget ind fra en anden enhed",
+		LangRoRO: "V-ați conectat de pe alt dispozitiv",
+		LangNlNL: "U bent ingelogd vanaf een ander apparaat",
+		LangTrTR: "Başka bir cihazdan giriş yaptınız",
+		LangRuRU: "Вы вошли с другого устройства",
+		LangElGR: "Συνδεθήκατε από άλλη συσκευή",
+		LangFrFR: "Vous vous êtes connecté depuis un autre appareil",
+	}
+
+	// 请求太频繁
+	errorMessages[CodeTooManyRequests] = map[Language]string{
+		LangZhCN: "请求太频繁，请稍后再试",
+		LangEnUS: "Too many requests, please try again later",
+		LangThTH: "มีคำขอมากเกินไป โปรดลองอีกครั้งในภายหลัง",
+		LangViVN: "Quá nhiều yêu cầu, vui lòng thử lại sau",
+		LangIdID: "Terlalu banyak permintaan, silakan coba lagi nanti",
+		LangHiIN: "बहुत अधिक अनुरोध, कृपया बाद में पुनः प्रयास करें",
+		LangTaIN: "அதிகமான கோரிக்கைகள், பின்னர் மீண்டும் முயற்சிக்கவும்",
+		LangMyMM: "တောင်းဆိုမှုများလွန်းသည်၊ နောက်မှ ထပ်ကြိုးစားပါ",
+		LangJaJP: "リクエストが多すぎます。しばらくしてから再度お試しください",
+		LangMsMY: "Terlalu banyak permintaan, sila cuba lagi kemudian",
+		LangKoKR: "요청이 너무 많습니다. 잠시 후 다시 시도해 주세요",
+		LangBnIN: "অনেক বেশি অনুরোধ, অনুগ্রহ করে পরে আবার চেষ্টা করুন",
+		LangEsAR: "Demasiadas solicitudes, inténtalo de nuevo más tarde",
+		LangPtBR: "Muitas solicitações, tente novamente mais tarde",
+		LangItIT: "Troppe richieste, riprova più tardi",
+		LangSvSE: "För många förfrågningar, försök igen senare",
+		LangDeDE: "Zu viele Anfragen, bitte versuchen Sie es später erneut",
+		LangDaDK: "For mange anmodninger, prøv igen senere",
+		LangRoRO: "Prea multe cereri, încercați din nou mai târziu",
+		LangNlNL: "Te veel verzoeken, probeer het later opnieuw",
+		LangTrTR: "Çok fazla istek, lütfen daha sonra tekrar deneyin",
+		LangRuRU: "Слишком много запросов, попробуйте позже",
+		LangElGR: "Πάρα πολλά αιτήματα, δοκιμάστε ξανά αργότερα",
+		LangFrFR: "Trop de requêtes, veuillez réessayer plus tard",
+	}
 }
 
 func GetErrorMessage(code int, lang Language) string {
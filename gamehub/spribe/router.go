@@ -2,11 +2,14 @@ package spribe
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	v1 "github.com/card-engine/game_common/api/game/v1"
 	client_utils "github.com/card-engine/game_common/api/game/v1/client"
 	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/fairness"
+	"github.com/card-engine/game_common/gamehub/session"
 	"github.com/card-engine/game_common/gamehub/types"
 	"github.com/card-engine/game_common/player"
 	"github.com/card-engine/game_common/sfs/protocol"
@@ -30,6 +33,10 @@ type SpribeRouter struct {
 	roomManager *common.RoomManager
 	lobby       types.LobbyImp
 	logger      log.Logger
+
+	sessions  *session.Manager        // 为空时不限制多处登陆
+	sendQueue common.SendQueueOptions // 玩家连接的发送队列配置
+	flood     common.FloodOptions     // 消息限流，默认不限制
 }
 
 func NewSpribeRouter(
@@ -41,6 +48,10 @@ func NewSpribeRouter(
 	roomManager *common.RoomManager,
 	lobby types.LobbyImp,
 	logger log.Logger) *SpribeRouter {
+	// 客户端种子放在roomManager上，房间计算结果时使用
+	if rdb != nil {
+		roomManager.SetClientSeeds(fairness.NewRedisClientSeedStore(rdb))
+	}
 	return &SpribeRouter{
 		app:         app,
 		gameName:    gameName,
@@ -71,6 +82,9 @@ func (r *SpribeRouter) Route() {
 
 		var player types.PlayerImp = nil
 
+		guard := common.NewFloodGuard(r.flood)
+		guard.Watch(c)
+
 		defer func() {
 			if player != nil {
 				if err := r.onDisconnect(player); err != nil {
@@ -81,12 +95,18 @@ func (r *SpribeRouter) Route() {
 			if err := c.Close(); err != nil {
 				r.log.Errorf("close websocket error: %v", err)
 			}
+			// 连接释放前停止写协程
+			if player != nil {
+				player.SetConn(nil)
+			}
 		}()
 
 		for {
+			guard.BeforeRead(c)
 			_, msg, err := c.ReadMessage()
 
 			if err != nil {
+				guard.OnReadError(err)
 				r.log.Errorf("read websocket message error: %v", err)
 				break
 			}
@@ -104,7 +124,7 @@ func (r *SpribeRouter) Route() {
 				}
 				step += 1
 			} else {
-				if err := r.onMessage(player, msg); err != nil {
+				if err := r.onMessage(player, guard, msg); err != nil {
 					r.log.Errorf("handle message error: %v", err)
 					break
 				}
@@ -189,6 +209,7 @@ func (r *SpribeRouter) onLogin(c *websocket.Conn, buff []byte) (types.PlayerImp,
 	// }
 
 	player := common.NewPlayer(types.GameBrand_Spribe, c, playerInfo, r.rtpGrpcConn, r.log)
+	player.SetSendQueueOptions(r.sendQueue)
 
 	// 初使化金币
 	balanceRsp, err := client_utils.Balance(context.Background(), r.apiGrpcConn, playerInfo.AppID, &v1.BalanceRequest{
@@ -216,22 +237,38 @@ func (r *SpribeRouter) onLogin(c *websocket.Conn, buff []byte) (types.PlayerImp,
 	// 	conn.Close()
 	// 	return nil, err
 	// }
+	// 踢掉其它地方的登陆
+	if r.sessions != nil {
+		if err := r.sessions.Login(player, r.onLoggedInElsewhere); err != nil {
+			r.log.Errorf("session Login failed: %v", err)
+			return nil, err
+		}
+	}
+
 	if err := r.lobby.OnLogin(player); err != nil {
 		return nil, err
 	}
 	return player, nil
 }
 
-func (s *SpribeRouter) onMessage(player types.PlayerImp, buff []byte) error {
+func (s *SpribeRouter) onMessage(player types.PlayerImp, guard *common.FloodGuard, buff []byte) error {
 	action, controller, data, err := utils.Unpack(buff)
 	if err != nil {
 		return err
 	}
 
+	cmd, _ := data["c"].(string)
+	if ok, err := s.allow(player, guard, cmd); !ok {
+		return err
+	}
+
 	// ping
 	if action == 29 && controller == 0 {
 		return player.SendBinary(buff)
 	} else if action == 13 && controller == 1 {
+		if isClientSeedCmd(cmd) {
+			return s.onClientSeed(player, cmd, data)
+		}
 		// 如果有大厅的话，将消息转发至大厅
 		if s.lobby != nil {
 			if err := s.lobby.OnMessage(player, data); err != nil {
@@ -246,10 +283,121 @@ func (s *SpribeRouter) onMessage(player types.PlayerImp, buff []byte) error {
 	return nil
 }
 
+func isClientSeedCmd(cmd string) bool {
+	return cmd == "getClientSeed" || cmd == "setClientSeed" || cmd == "rotateClientSeed"
+}
+
+// 玩家查看、设置或者随机更换自己的客户端种子，下一局开始生效，回复 <cmd>Response
+func (s *SpribeRouter) onClientSeed(player types.PlayerImp, cmd string, data sfs.SFSObject) error {
+	ctx := context.Background()
+	playerIdent := player.GetPlayerIdent()
+
+	var seed string
+	var err error
+	switch cmd {
+	case "setClientSeed":
+		if p, ok := data["p"].(sfs.SFSObject); ok {
+			seed, _ = p["clientSeed"].(string)
+		}
+		err = s.roomManager.ClientSeeds().Set(ctx, playerIdent, seed)
+	case "rotateClientSeed":
+		seed, err = fairness.RotateClientSeed(ctx, s.roomManager.ClientSeeds(), playerIdent)
+	default:
+		seed, err = s.roomManager.ClientSeeds().Get(ctx, playerIdent)
+	}
+
+	rsp := sfs.SFSObject{"clientSeed": seed}
+	if err != nil {
+		s.log.Errorf("%s failed: %v", cmd, err)
+		if !errors.Is(err, fairness.ErrInvalidClientSeed) {
+			return err
+		}
+		rsp = sfs.SFSObject{
+			"code":    int32(CodeInvalidParameter),
+			"message": GetErrorMessage(CodeInvalidParameter, Language(player.GetLang())),
+		}
+	}
+
+	buff, err := utils.PackCustomData(cmd+"Response", rsp)
+	if err != nil {
+		return err
+	}
+	return player.SendBinary(buff)
+}
+
 func (s *SpribeRouter) onDisconnect(player types.PlayerImp) error {
+	if s.sessions != nil {
+		s.sessions.Logout(player)
+	}
 	return s.roomManager.OnDisConnect(player)
 }
 
+// 超过限制时丢弃消息，扩展消息回复<cmd>Response错误，次数太多时返回错误断开连接
+func (s *SpribeRouter) allow(player types.PlayerImp, guard *common.FloodGuard, cmd string) (bool, error) {
+	err := guard.Allow(cmd)
+	if errors.Is(err, common.ErrRateLimited) {
+		if cmd != "" {
+			buff, err := utils.PackCustomData(cmd+"Response", sfs.SFSObject{
+				"code":    int32(CodeTooManyRequests),
+				"message": GetErrorMessage(CodeTooManyRequests, Language(player.GetLang())),
+			})
+			if err != nil {
+				return false, err
+			}
+			player.SendBinary(buff)
+		}
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 设置消息限流、最大消息长度和空闲超时，需要在Start之前调用
+func (s *SpribeRouter) SetFloodOptions(opts common.FloodOptions) {
+	s.flood = opts
+}
+
+// 设置会话管理，同一个玩家只能在一个地方登陆
+func (s *SpribeRouter) SetSessionManager(sessions *session.Manager) {
+	s.sessions = sessions
+}
+
+// 设置玩家连接的发送队列，需要在Start之前调用
+func (s *SpribeRouter) SetSendQueueOptions(opts common.SendQueueOptions) {
+	s.sendQueue = opts
+}
+
+// 节点下线前通知客户端重连到其它节点
+func (s *SpribeRouter) ReconnectHint(player types.PlayerImp) {
+	buff, err := utils.PackCustomData("serverRestart", sfs.SFSObject{"reconnect": true})
+	if err != nil {
+		s.log.Errorf("PackCustomData failed: %v", err)
+		return
+	}
+	player.SendBinary(buff)
+}
+
+// 管理员踢人或销毁房间时通知玩家原因
+func (s *SpribeRouter) KickNotice(player types.PlayerImp, reason string) {
+	buff, err := utils.PackCustomData("kicked", sfs.SFSObject{"reason": reason})
+	if err != nil {
+		s.log.Errorf("PackCustomData failed: %v", err)
+		return
+	}
+	player.SendBinary(buff)
+}
+
+func (s *SpribeRouter) onLoggedInElsewhere(player types.PlayerImp) {
+	buff, err := utils.PackCustomData("loggedInElsewhere", sfs.SFSObject{
+		"code":    int32(CodeLoggedInElsewhere),
+		"message": GetErrorMessage(CodeLoggedInElsewhere, Language(player.GetLang())),
+	})
+	if err != nil {
+		s.log.Errorf("PackCustomData failed: %v", err)
+		return
+	}
+	player.SendBinary(buff)
+}
+
 func (s *SpribeRouter) Send(c *websocket.Conn, controller int16, action uint8, payload sfs.SFSObject) error {
 	sendData := sfs.SFSObject{
 		"a": action,
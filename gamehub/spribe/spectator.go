@@ -0,0 +1,107 @@
+package spribe
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/card-engine/game_common/gamehub/common"
+	"github.com/card-engine/game_common/gamehub/spectator"
+	"github.com/card-engine/game_common/sfs/utils"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// 观战入口，token由运营方通过spectator.Issue签发并放在url参数中，登陆协议里的token会被忽略
+func (r *SpribeRouter) RouteSpectator(gate *spectator.Gate) {
+	routPath := fmt.Sprintf("/%s/spectate", r.gameName)
+
+	r.app.Get(routPath, func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		claims, release, err := gate.Admit(c.Query("token"))
+		if err != nil {
+			r.log.Warnf("spectator rejected: %v", err)
+			if errors.Is(err, spectator.ErrTooManySpectators) {
+				return fiber.ErrTooManyRequests
+			}
+			return fiber.ErrUnauthorized
+		}
+
+		return websocket.New(func(conn *websocket.Conn) {
+			defer release()
+			watcher := spectator.New(conn, claims)
+			watcher.SetSendQueueOptions(r.sendQueue)
+			r.onSpectatorHandler(watcher)
+		})(c)
+	})
+}
+
+func (r *SpribeRouter) onSpectatorHandler(watcher *spectator.Spectator) {
+	conn := watcher.GetConn()
+	defer func() {
+		r.roomManager.OnDisConnect(watcher)
+		watcher.SetConn(nil)
+		conn.Close()
+	}()
+
+	guard := common.NewFloodGuard(r.flood)
+	guard.Watch(conn)
+	step := 0
+	for {
+		guard.BeforeRead(conn)
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			guard.OnReadError(err)
+			break
+		}
+
+		if step == 0 {
+			if err := r.onHandshake(conn, msg); err != nil {
+				r.log.Errorf("spectator handshake error: %v", err)
+				break
+			}
+			step += 1
+		} else if step == 1 {
+			if _, controller, _, err := utils.Unpack(msg); err != nil || controller != 0 {
+				r.log.Errorf("spectator login error: %v", err)
+				break
+			}
+			if err := r.roomManager.Spectate(watcher, watcher.RoomType(), watcher.RoomArgs()); err != nil {
+				r.log.Errorf("spectate error: %v", err)
+				break
+			}
+			step += 1
+		} else {
+			if err := r.onSpectatorMessage(watcher, guard, msg); err != nil {
+				r.log.Errorf("handle spectator message error: %v", err)
+				break
+			}
+		}
+	}
+}
+
+// 观战玩家的消息不经过大厅，只能心跳、查询状态和历史，下注和设置客户端种子都会被拒绝
+func (r *SpribeRouter) onSpectatorMessage(watcher *spectator.Spectator, guard *common.FloodGuard, buff []byte) error {
+	action, controller, data, err := utils.Unpack(buff)
+	if err != nil {
+		return err
+	}
+
+	cmd, _ := data["c"].(string)
+	if ok, err := r.allow(watcher, guard, cmd); !ok {
+		return err
+	}
+
+	if action == 29 && controller == 0 {
+		return watcher.SendBinary(buff)
+	} else if action == 13 && controller == 1 {
+		if !common.IsSpectatorCommand(cmd) {
+			r.log.Warnf("spectator %s command %s rejected", watcher.GetPlayerIdent(), cmd)
+			return nil
+		}
+		return r.roomManager.OnMessage(watcher, data)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package types
 
 import (
+	"context"
+	"time"
+
 	v1 "github.com/card-engine/game_common/api/game/v1"
 	"github.com/card-engine/game_common/player"
 	"github.com/gofiber/contrib/websocket"
@@ -31,6 +34,12 @@ type RoomManagerImp interface {
 	// 玩家登录房间
 	OnJoin(player PlayerImp, roomType string, roomArgs interface{}) error
 
+	// 使用TableMatcher为玩家配桌
+	OnMatch(player PlayerImp) error
+
+	// 观战玩家进入房间，房间需要实现SpectatableRoomImp
+	Spectate(player PlayerImp, roomType string, roomArgs interface{}) error
+
 	// 玩家收到了消息了
 	OnMessage(player PlayerImp, msg interface{}) error
 
@@ -73,6 +82,78 @@ type PlayerImp interface {
 	SendBinary(data []byte) error
 }
 
+// 机器人玩家，不访问钱包，在下注列表和历史记录中需要标记出来
+type BotImp interface {
+	PlayerImp
+	IsBot() bool
+}
+
+func IsBot(player PlayerImp) bool {
+	bot, ok := player.(BotImp)
+	return ok && bot.IsBot()
+}
+
+// 可以提供展示用的昵称和头像的玩家
+type ProfileImp interface {
+	GetNickname() string
+	GetAvatar() string
+}
+
+// 观战玩家，只接收广播，不能下注，不计入房间人数
+type SpectatorImp interface {
+	PlayerImp
+	IsSpectator() bool
+}
+
+func IsSpectator(player PlayerImp) bool {
+	spectator, ok := player.(SpectatorImp)
+	return ok && spectator.IsSpectator()
+}
+
+// 支持观战的房间
+type SpectatableRoomImp interface {
+	RoomImp
+	// 观战玩家进入，不影响GetPlayerNum
+	OnSpectate(player PlayerImp) error
+	// 观战玩家离开
+	OnSpectatorLeave(player PlayerImp)
+}
+
+// 断线超时后需要处理玩家未结束对局的房间，例如自动提现或者退款
+type SeatExpirableRoomImp interface {
+	RoomImp
+	// 玩家断线超过保留时间，随后玩家会被移出房间
+	OnSeatExpired(player PlayerImp)
+}
+
+// 节点下线时可以排空的房间
+type DrainableRoomImp interface {
+	RoomImp
+	// 不再开始新的一局，当前局结束后返回nil；ctx结束时当前局还没结束则返回ctx.Err()
+	Drain(ctx context.Context) error
+}
+
+// 排空超时后仍未结束对局的房间，由游戏退款或者结算，之后房间会被销毁
+type UnfinishedRoundHook func(room RoomImp)
+
+// 房间定时器，key在房间内唯一，房间销毁时自动取消
+type RoomTimersImp interface {
+	Now() time.Time
+	// d之后执行一次fn，同一个key会替换旧的定时器
+	Schedule(key string, d time.Duration, fn func())
+	// 每隔interval执行一次fn，直到取消
+	Repeat(key string, interval time.Duration, fn func())
+	// 取消定时器，返回是否存在
+	Cancel(key string) bool
+}
+
+// 需要定时器的房间，创建后由RoomManager注入。
+// 定时器回调在房间执行器(common.ActorRoom)中执行，创建器没有包装的房间会被自动包装
+type TimedRoomImp interface {
+	RoomImp
+	SetTimers(timers RoomTimersImp)
+}
+
 // 定义一个房间的概念
 type RoomImp interface {
 	// 获取当前玩家的数量
@@ -123,6 +204,14 @@ const (
 	TableMatcherType_CUSTOM                         //自定义配桌算法
 )
 
+// 自定义配桌算法，TableMatcherType_CUSTOM时必须设置，其它类型设置后用于挑选已有房间
+type TableMatcher interface {
+	// 玩家所在的房间分组和创建房间的参数，只有同一分组的房间会被匹配
+	Group(player PlayerImp) (roomType string, roomArgs interface{})
+	// 按优先顺序返回可以尝试加入的房间，房间拒绝时尝试下一个，都不行则创建新房间
+	Candidates(player PlayerImp, roomArgs interface{}, rooms []RoomImp) []RoomImp
+}
+
 // rtp类型的房间
 type RtpRoomArgs struct {
 	Appid    string
@@ -2,12 +2,12 @@ package utils
 
 import (
 	"context"
-	"math"
 	"math/rand/v2"
 	"strconv"
 
 	rtp_rpc_v1 "github.com/card-engine/game_common/api/rtp/v1"
 	rtp_rpc_client "github.com/card-engine/game_common/api/rtp/v1/client"
+	"github.com/card-engine/game_common/gamehub/utils/stock"
 	google_grpc "google.golang.org/grpc"
 )
 
@@ -35,39 +35,45 @@ func CalculateCrashX(
 		return 1, err
 	}
 
-	// 计算坠机的比例
-	if resp.Rate1 > 0 || resp.Rate2 > 0 {
-		randomValue := rand.Float64()
-		if randomValue < resp.Rate1 && resp.Rate1 > 0 {
-			return 1, nil
-		}
-		if randomValue < resp.Rate2 && resp.Rate2 > 0 {
-			return 2, nil
-		}
+	// 库存控制的强制坠机，见stock包
+	if x, ok := (stock.Rates{Rate1: resp.Rate1, Rate2: resp.Rate2}).Force(rand.Float64()); ok {
+		return x, nil
 	}
 
-	var crash float64 = 0
-	if int32(rtpNum) == 50 {
-		crash = min(100, rtpNum*500/(50000-RandFloat(0, 49999)))
-	} else if int32(rtpNum) == 65 {
-		crash = min(200, rtpNum*500/(50000-RandFloat(0, 49999)))
-	} else if int32(rtpNum) == 75 {
-		crash = min(500, rtpNum*500/(50000-RandFloat(0, 49999)))
-	} else if int32(rtpNum) == 85 {
-		crash = min(750, rtpNum*500/(50000-RandFloat(0, 49999)))
-	} else if int32(rtpNum) == 90 {
-		crash = min(1000, rtpNum*500/(50000-RandFloat(0, 49999)))
-	} else {
-		//容错的旧算法
-		crash = rtpNum * 500 / (50000 - RandFloat(0, 49999))
-	}
-
-	// 向下取整，保留两位小数
-	crash = math.Floor(crash*100) / 100
+	// 曲线按档位配置，见crash_curve.go
+	return GetCrashCurve(rtpNum).Crash(nil), nil
+}
 
-	if crash < 1.0 {
-		return 1.0, nil
-	}
+// 飞机类游戏每局结束后上报本局下注总额和平台盈利(下注 - 派奖)，rtp服务据此更新库存
+func SettleCrashRound(
+	rtpGrpcConn *google_grpc.ClientConn,
+	appId string,
+	gameBrand string,
+	gameId string,
+	roundId string,
+	rtp string,
+	roundBetTotal float64,
+	roundProfitTotal float64,
+) error {
+	_, err := rtp_rpc_client.SettleBaccarat(context.Background(), rtpGrpcConn, &rtp_rpc_v1.SettleBaccaratRequest{
+		AppId:            appId,
+		GameBrand:        gameBrand,
+		GameId:           gameId,
+		RoundBetTotal:    roundBetTotal,
+		RoundProfitTotal: roundProfitTotal,
+		RoundId:          roundId,
+		Rtp:              rtp,
+	})
+	return err
+}
 
-	return crash, nil
+// 重置库存，仅运营层面使用
+func ResetCrashStock(rtpGrpcConn *google_grpc.ClientConn, appId string, gameBrand string, gameId string, rtp string) error {
+	_, err := rtp_rpc_client.ResetBaccarat(context.Background(), rtpGrpcConn, &rtp_rpc_v1.ResetBaccaratRequest{
+		AppId:     appId,
+		GameBrand: gameBrand,
+		GameId:    gameId,
+		Rtp:       rtp,
+	})
+	return err
 }
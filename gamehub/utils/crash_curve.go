@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/redis/go-redis/v9"
+)
+
+// 飞机类游戏的坠机曲线，每个rtp档位一条:
+//
+//	crash = (1 - house_edge) / (1 - U)，U均匀分布在[0, 1-1/crashPrecision]
+//	以 instant_bust 的概率直接在1.00坠机，结果不超过 max_multiplier，向下取整保留两位小数
+//
+// 对于 1.01 <= m <= max_multiplier 的提现倍数，P(crash >= m) = (1-instant_bust) * P(U >= 1 - (1-house_edge)/m)，
+// 期望回报为 m * P(crash >= m)，最大值在 m = max(1-house_edge, 1.01) 处取得，即该档位的rtp。
+const (
+	crashPrecision = 50000
+
+	// 期望rtp与档位rtp允许的误差(百分点)
+	DefaultCrashRtpTolerance = 0.5
+	// 没有配置的档位使用的最大倍数
+	DefaultCrashMaxMultiplier = 1000
+
+	CrashCurveConsulKey = "aigc/crash/curves"
+	CrashCurveRedisKey  = "crash:curves"
+)
+
+type CrashCurve struct {
+	Rtp           float64 `json:"rtp"`            // 档位rtp(%)
+	HouseEdge     float64 `json:"house_edge"`     // 曲线的抽水，rtp大于100的档位为负数
+	MaxMultiplier float64 `json:"max_multiplier"` // 最大倍数
+	InstantBust   float64 `json:"instant_bust"`   // 直接在1.00坠机的概率
+}
+
+// 默认曲线，50~90档的最大倍数与原先的if分支一致
+var DefaultCrashCurves = []CrashCurve{
+	{Rtp: 50, HouseEdge: 0.50, MaxMultiplier: 100},
+	{Rtp: 65, HouseEdge: 0.35, MaxMultiplier: 200},
+	{Rtp: 75, HouseEdge: 0.25, MaxMultiplier: 500},
+	{Rtp: 85, HouseEdge: 0.15, MaxMultiplier: 750},
+	{Rtp: 90, HouseEdge: 0.10, MaxMultiplier: 1000},
+	{Rtp: 95, HouseEdge: 0.05, MaxMultiplier: 1000},
+	{Rtp: 97, HouseEdge: 0.03, MaxMultiplier: 1000},
+	{Rtp: 100, HouseEdge: 0, MaxMultiplier: 1000},
+	{Rtp: 150, HouseEdge: -0.5, MaxMultiplier: 1000},
+	{Rtp: 500, HouseEdge: -4, MaxMultiplier: 1000},
+}
+
+// 曲线的系数 1-house_edge
+func (c *CrashCurve) scale() float64 {
+	return 1 - c.HouseEdge
+}
+
+// 生成一个坠机倍数，r为nil时使用全局随机源
+func (c *CrashCurve) Crash(r *rand.Rand) float64 {
+	float := rand.Float64
+	if r != nil {
+		float = r.Float64
+	}
+	if c.InstantBust > 0 && float() < c.InstantBust {
+		return 1
+	}
+	return c.crashOf(float())
+}
+
+// 由[0,1)上均匀分布的f确定坠机倍数，同样的f总是得到同样的倍数，可证明公平的一局使用fairness.Outcome.Float。
+// f小于instant_bust时直接坠机，其余部分线性映射为U，分布与Crash相同
+func (c *CrashCurve) CrashAt(f float64) float64 {
+	if c.InstantBust > 0 {
+		if f < c.InstantBust {
+			return 1
+		}
+		f = (f - c.InstantBust) / (1 - c.InstantBust)
+	}
+	return c.crashOf(f)
+}
+
+func (c *CrashCurve) crashOf(f float64) float64 {
+	u := f * (crashPrecision - 1) / crashPrecision
+	crash := min(c.MaxMultiplier, c.scale()/(1-u))
+
+	// 向下取整，保留两位小数
+	crash = math.Floor(crash*100) / 100
+	if crash < 1.0 {
+		return 1.0
+	}
+	return crash
+}
+
+// 坠机倍数不小于m的概率(m保留两位小数)
+func (c *CrashCurve) SurvivalProbability(m float64) float64 {
+	if m <= 1 {
+		return 1
+	}
+	if m > c.MaxMultiplier {
+		return 0
+	}
+	// U >= 1 - scale/m
+	umax := float64(crashPrecision-1) / crashPrecision
+	p := (umax - (1 - c.scale()/m)) / umax
+	p = min(max(p, 0), 1)
+	return (1 - c.InstantBust) * p
+}
+
+// 在m倍提现的期望回报(%)
+func (c *CrashCurve) ExpectedRtp(m float64) float64 {
+	return m * c.SurvivalProbability(m) * 100
+}
+
+// 回报最高的提现倍数
+func (c *CrashCurve) BestTarget() float64 {
+	m := math.Ceil(max(c.scale(), 1.01)*100) / 100
+	return min(m, c.MaxMultiplier)
+}
+
+func (c *CrashCurve) Validate(tolerance float64) error {
+	if c.Rtp <= 0 {
+		return fmt.Errorf("crash curve: invalid rtp %v", c.Rtp)
+	}
+	if c.HouseEdge >= 1 {
+		return fmt.Errorf("crash curve %v: house_edge must be less than 1", c.Rtp)
+	}
+	if c.MaxMultiplier < 1.01 {
+		return fmt.Errorf("crash curve %v: max_multiplier must be at least 1.01", c.Rtp)
+	}
+	if c.InstantBust < 0 || c.InstantBust >= 1 {
+		return fmt.Errorf("crash curve %v: instant_bust must be in [0, 1)", c.Rtp)
+	}
+	if expected := c.ExpectedRtp(c.BestTarget()); math.Abs(expected-c.Rtp) > tolerance {
+		return fmt.Errorf("crash curve %v: expected rtp %.4f deviates from tier", c.Rtp, expected)
+	}
+	return nil
+}
+
+type CrashCurveTable struct {
+	curves map[int]*CrashCurve
+}
+
+// 解析并校验曲线配置，格式为CrashCurve的json数组
+func ParseCrashCurves(data []byte, tolerance float64) (*CrashCurveTable, error) {
+	var curves []CrashCurve
+	if err := json.Unmarshal(data, &curves); err != nil {
+		return nil, fmt.Errorf("crash curve: %w", err)
+	}
+	return NewCrashCurveTable(curves, tolerance)
+}
+
+func NewCrashCurveTable(curves []CrashCurve, tolerance float64) (*CrashCurveTable, error) {
+	if len(curves) == 0 {
+		return nil, errors.New("crash curve: empty table")
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultCrashRtpTolerance
+	}
+	t := &CrashCurveTable{curves: make(map[int]*CrashCurve, len(curves))}
+	for i := range curves {
+		c := curves[i]
+		if err := c.Validate(tolerance); err != nil {
+			return nil, err
+		}
+		if _, ok := t.curves[int(c.Rtp)]; ok {
+			return nil, fmt.Errorf("crash curve: duplicate tier %v", c.Rtp)
+		}
+		t.curves[int(c.Rtp)] = &c
+	}
+	return t, nil
+}
+
+func (t *CrashCurveTable) Get(rtp int) (*CrashCurve, bool) {
+	c, ok := t.curves[rtp]
+	return c, ok
+}
+
+// 按rtp从小到大
+func (t *CrashCurveTable) Curves() []CrashCurve {
+	curves := make([]CrashCurve, 0, len(t.curves))
+	for _, c := range t.curves {
+		curves = append(curves, *c)
+	}
+	sort.Slice(curves, func(i, j int) bool { return curves[i].Rtp < curves[j].Rtp })
+	return curves
+}
+
+var crashCurves atomic.Pointer[CrashCurveTable]
+
+func init() {
+	table, err := NewCrashCurveTable(DefaultCrashCurves, DefaultCrashRtpTolerance)
+	if err != nil {
+		panic(err)
+	}
+	crashCurves.Store(table)
+}
+
+// 替换当前使用的曲线表
+func SetCrashCurves(table *CrashCurveTable) {
+	if table != nil {
+		crashCurves.Store(table)
+	}
+}
+
+func GetCrashCurves() *CrashCurveTable {
+	return crashCurves.Load()
+}
+
+// 获取某个档位的曲线，没有配置时按档位rtp生成一条，最大倍数为DefaultCrashMaxMultiplier
+func GetCrashCurve(rtp float64) *CrashCurve {
+	if c, ok := GetCrashCurves().Get(int(rtp)); ok {
+		return c
+	}
+	return &CrashCurve{Rtp: rtp, HouseEdge: 1 - rtp/100, MaxMultiplier: DefaultCrashMaxMultiplier}
+}
+
+// 从redis缓存加载曲线表，key不存在时保持当前配置
+func LoadCrashCurvesFromRedis(ctx context.Context, rdb *redis.Client, key string) error {
+	if key == "" {
+		key = CrashCurveRedisKey
+	}
+	data, err := rdb.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	table, err := ParseCrashCurves(data, DefaultCrashRtpTolerance)
+	if err != nil {
+		return err
+	}
+	SetCrashCurves(table)
+	return nil
+}
+
+// 从consul加载曲线表并监听变化，校验失败的配置会被忽略并继续使用旧的曲线
+func WatchCrashCurvesFromConsul(ctx context.Context, consulAddr, consulToken, key string, onError func(error)) error {
+	if key == "" {
+		key = CrashCurveConsulKey
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+	client, err := api.NewClient(&api.Config{Address: consulAddr, Token: consulToken})
+	if err != nil {
+		return err
+	}
+
+	load := func(waitIndex uint64) (uint64, error) {
+		kv, meta, err := client.KV().Get(key, (&api.QueryOptions{WaitIndex: waitIndex, WaitTime: 5 * time.Minute}).WithContext(ctx))
+		if err != nil {
+			return waitIndex, err
+		}
+		if kv != nil && meta.LastIndex != waitIndex {
+			table, err := ParseCrashCurves(kv.Value, DefaultCrashRtpTolerance)
+			if err != nil {
+				onError(fmt.Errorf("%s: %w", key, err))
+			} else {
+				SetCrashCurves(table)
+			}
+		}
+		return meta.LastIndex, nil
+	}
+
+	lastIndex, err := load(0)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for ctx.Err() == nil {
+			index, err := load(lastIndex)
+			if err != nil {
+				if ctx.Err() == nil {
+					onError(err)
+					time.Sleep(5 * time.Second)
+				}
+				continue
+			}
+			lastIndex = index
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestDefaultCrashCurves(t *testing.T) {
+	table := GetCrashCurves()
+	for _, rtp := range []int{50, 65, 75, 85, 90, 95, 97, 100, 150, 500} {
+		c, ok := table.Get(rtp)
+		if !ok {
+			t.Fatalf("tier %d has no curve", rtp)
+		}
+		if got := c.ExpectedRtp(c.BestTarget()); math.Abs(got-float64(rtp)) > DefaultCrashRtpTolerance {
+			t.Fatalf("tier %d expected rtp %.4f", rtp, got)
+		}
+		// 所有档位都必须封顶
+		if c.SurvivalProbability(c.MaxMultiplier+0.01) != 0 {
+			t.Fatalf("tier %d is not capped", rtp)
+		}
+	}
+
+	// 没有配置的档位按rtp生成
+	if c := GetCrashCurve(88); c.MaxMultiplier != DefaultCrashMaxMultiplier || c.ExpectedRtp(c.BestTarget())-88 > DefaultCrashRtpTolerance {
+		t.Fatalf("fallback curve %+v", c)
+	}
+}
+
+func TestCrashCurveRange(t *testing.T) {
+	c := GetCrashCurve(97)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 100000; i++ {
+		crash := c.Crash(r)
+		if crash < 1 || crash > c.MaxMultiplier || math.Abs(crash*100-math.Round(crash*100)) > 1e-6 {
+			t.Fatalf("invalid crash %v", crash)
+		}
+	}
+}
+
+// 由均匀分布的f确定的倍数，分布与SurvivalProbability一致
+func TestCrashCurveCrashAt(t *testing.T) {
+	c := CrashCurve{Rtp: 97, HouseEdge: 0.01, InstantBust: 0.02, MaxMultiplier: 1000}
+	if c.CrashAt(0.01) != 1 || c.CrashAt(0.5) != c.CrashAt(0.5) {
+		t.Fatal("instant bust or determinism broken")
+	}
+	r := rand.New(rand.NewPCG(3, 4))
+	const n = 200000
+	survived := 0
+	for i := 0; i < n; i++ {
+		if c.CrashAt(r.Float64()) >= 2 {
+			survived++
+		}
+	}
+	p := c.SurvivalProbability(2)
+	if got := float64(survived) / n; math.Abs(got-p) > 4*math.Sqrt(p*(1-p)/n) {
+		t.Fatalf("P(crash >= 2) = %.4f, expected %.4f", got, p)
+	}
+}
+
+func TestParseCrashCurves(t *testing.T) {
+	table, err := ParseCrashCurves([]byte(`[
+		{"rtp": 97, "house_edge": 0.01, "instant_bust": 0.02, "max_multiplier": 5000}
+	]`), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := table.Get(97)
+	if c.MaxMultiplier != 5000 || c.InstantBust != 0.02 {
+		t.Fatalf("unexpected curve %+v", c)
+	}
+
+	bad := []string{
+		`[]`,
+		`[{"rtp": 97, "house_edge": 0.10, "max_multiplier": 1000}]`,
+		`[{"rtp": 97, "house_edge": 0.03, "max_multiplier": 1}]`,
+		`[{"rtp": 97, "house_edge": 0.03, "max_multiplier": 100, "instant_bust": 1}]`,
+		`[{"rtp": 97, "house_edge": 0.03, "max_multiplier": 100}, {"rtp": 97, "house_edge": 0.03, "max_multiplier": 100}]`,
+	}
+	for _, data := range bad {
+		if _, err := ParseCrashCurves([]byte(data), 0); err == nil {
+			t.Fatalf("expected error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// 离线坠机曲线模拟器: 使用线上同样的CrashCurve.Crash生成N局，
+// 统计在几个典型提现倍数下的实际回报，与闭式期望值比较，曲线配置推到生产之前先用它验证。
+package crashsim
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/card-engine/game_common/gamehub/utils"
+)
+
+const (
+	DefaultRounds = 1_000_000
+	// 99.9%置信区间，每个档位要同时检验多个提现倍数，95%会经常误报
+	DefaultConfidenceZ = 3.29
+)
+
+type Options struct {
+	Rounds      int       // 每个档位模拟的局数
+	Workers     int       // 并发数，默认为cpu核数
+	Targets     []float64 // 额外统计的提现倍数，最优倍数和最大倍数总是会统计
+	ConfidenceZ float64   // 置信区间的z值
+	Seed        uint64    // 随机种子，0表示随机
+}
+
+func (o *Options) normalize() {
+	if o.Rounds <= 0 {
+		o.Rounds = DefaultRounds
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.ConfidenceZ <= 0 {
+		o.ConfidenceZ = DefaultConfidenceZ
+	}
+	if o.Seed == 0 {
+		o.Seed = rand.Uint64()
+	}
+}
+
+type Report struct {
+	Rounds      int           `json:"rounds"`
+	ConfidenceZ float64       `json:"confidence_z"`
+	Seed        uint64        `json:"seed"`
+	Tiers       []*TierReport `json:"tiers"`
+}
+
+type TierReport struct {
+	Curve       utils.CrashCurve `json:"curve"`
+	InstantBust float64          `json:"instant_bust"` // 实际在1.00坠机的比例
+	MaxCrash    float64          `json:"max_crash"`
+	Targets     []*TargetReport  `json:"targets"`
+	Pass        bool             `json:"pass"`
+}
+
+// 在某个提现倍数下的回报
+type TargetReport struct {
+	Target      float64 `json:"target"`
+	ExpectedRtp float64 `json:"expected_rtp"` // 闭式计算的rtp(%)
+	RealizedRtp float64 `json:"realized_rtp"` // 模拟得到的rtp(%)
+	CILow       float64 `json:"ci_low"`
+	CIHigh      float64 `json:"ci_high"`
+	Pass        bool    `json:"pass"` // 期望rtp落在置信区间内
+}
+
+func (r *Report) Pass() bool {
+	for _, t := range r.Tiers {
+		if !t.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// 按rtp从小到大模拟每一条曲线
+func Run(curves []utils.CrashCurve, opts Options) *Report {
+	opts.normalize()
+	sorted := append([]utils.CrashCurve(nil), curves...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Rtp < sorted[j].Rtp })
+
+	report := &Report{Rounds: opts.Rounds, ConfidenceZ: opts.ConfidenceZ, Seed: opts.Seed}
+	for i := range sorted {
+		report.Tiers = append(report.Tiers, runTier(&sorted[i], opts, uint64(i)))
+	}
+	return report
+}
+
+func targetsOf(c *utils.CrashCurve, extra []float64) []float64 {
+	seen := make(map[float64]struct{})
+	var targets []float64
+	for _, m := range append([]float64{c.BestTarget(), c.MaxMultiplier}, extra...) {
+		m = math.Round(m*100) / 100
+		if m <= 1 || m > c.MaxMultiplier {
+			continue
+		}
+		if _, ok := seen[m]; !ok {
+			seen[m] = struct{}{}
+			targets = append(targets, m)
+		}
+	}
+	sort.Float64s(targets)
+	return targets
+}
+
+// 单个worker的统计
+type accumulator struct {
+	busts    int
+	maxCrash float64
+	hits     []int // 每个提现倍数成功的局数
+}
+
+func runTier(c *utils.CrashCurve, opts Options, stream uint64) *TierReport {
+	targets := targetsOf(c, opts.Targets)
+	workers := min(opts.Workers, opts.Rounds)
+	accs := make([]accumulator, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		n := opts.Rounds / workers
+		if w < opts.Rounds%workers {
+			n++
+		}
+		wg.Add(1)
+		go func(acc *accumulator, n int, seq uint64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewPCG(opts.Seed, seq))
+			acc.hits = make([]int, len(targets))
+			for i := 0; i < n; i++ {
+				crash := c.Crash(rng)
+				if crash <= 1 {
+					acc.busts++
+				}
+				acc.maxCrash = max(acc.maxCrash, crash)
+				for j, m := range targets {
+					if crash >= m {
+						acc.hits[j]++
+					}
+				}
+			}
+		}(&accs[w], n, stream<<32|uint64(w))
+	}
+	wg.Wait()
+
+	tr := &TierReport{Curve: *c, Pass: true}
+	hits := make([]int, len(targets))
+	busts := 0
+	for i := range accs {
+		busts += accs[i].busts
+		tr.MaxCrash = max(tr.MaxCrash, accs[i].maxCrash)
+		for j := range hits {
+			hits[j] += accs[i].hits[j]
+		}
+	}
+	n := float64(opts.Rounds)
+	tr.InstantBust = float64(busts) / n
+
+	for j, m := range targets {
+		// 单局回报为m或0，伯努利分布
+		p := float64(hits[j]) / n
+		stderr := m * math.Sqrt(p*(1-p)/n) * 100
+		t := &TargetReport{
+			Target:      m,
+			ExpectedRtp: c.ExpectedRtp(m),
+			RealizedRtp: m * p * 100,
+		}
+		t.CILow = t.RealizedRtp - opts.ConfidenceZ*stderr
+		t.CIHigh = t.RealizedRtp + opts.ConfidenceZ*stderr
+		t.Pass = t.ExpectedRtp >= t.CILow && t.ExpectedRtp <= t.CIHigh
+		tr.Pass = tr.Pass && t.Pass
+		tr.Targets = append(tr.Targets, t)
+	}
+	return tr
+}
+
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "rounds per tier: %d, z: %.2f, seed: %d\n\n", r.Rounds, r.ConfidenceZ, r.Seed)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "rtp\tedge\tmax\tbust\ttarget\texpected\trealized\tci\tpass\t")
+	for _, t := range r.Tiers {
+		for _, target := range t.Targets {
+			fmt.Fprintf(tw, "%.0f\t%.4f\t%.2f\t%.4f\t%.2f\t%.4f\t%.4f\t[%.4f, %.4f]\t%v\t\n",
+				t.Curve.Rtp, t.Curve.HouseEdge, t.Curve.MaxMultiplier, t.InstantBust,
+				target.Target, target.ExpectedRtp, target.RealizedRtp, target.CILow, target.CIHigh, target.Pass)
+		}
+	}
+	return tw.Flush()
+}
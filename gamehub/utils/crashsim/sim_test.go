@@ -0,0 +1,41 @@
+package crashsim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/card-engine/game_common/gamehub/utils"
+)
+
+func TestRunDefaultCurves(t *testing.T) {
+	// 固定种子，结果可复现
+	report := Run(utils.DefaultCrashCurves, Options{Rounds: 200000, Targets: []float64{2, 10}, ConfidenceZ: 4, Seed: 7})
+	if len(report.Tiers) != len(utils.DefaultCrashCurves) {
+		t.Fatalf("got %d tiers", len(report.Tiers))
+	}
+	for _, tier := range report.Tiers {
+		if !tier.Pass {
+			var sb strings.Builder
+			report.WriteText(&sb)
+			t.Fatalf("tier %v failed:\n%s", tier.Curve.Rtp, sb.String())
+		}
+		if tier.MaxCrash > tier.Curve.MaxMultiplier {
+			t.Fatalf("tier %v crashed above cap: %v", tier.Curve.Rtp, tier.MaxCrash)
+		}
+	}
+}
+
+func TestRunInstantBust(t *testing.T) {
+	curve := utils.CrashCurve{Rtp: 92, HouseEdge: 0.03, InstantBust: 0.05, MaxMultiplier: 1000}
+	report := Run([]utils.CrashCurve{curve}, Options{Rounds: 200000, Workers: 2, ConfidenceZ: 4, Seed: 7})
+	tier := report.Tiers[0]
+	// 1.00坠机的比例包括instant_bust和曲线本身小于1.01的部分
+	if tier.InstantBust < 0.05 || !tier.Pass {
+		t.Fatalf("unexpected report %+v", tier)
+	}
+
+	again := Run([]utils.CrashCurve{curve}, Options{Rounds: 200000, Workers: 2, ConfidenceZ: 4, Seed: 7})
+	if again.Tiers[0].Targets[0].RealizedRtp != tier.Targets[0].RealizedRtp {
+		t.Fatal("same seed should give the same result")
+	}
+}
@@ -0,0 +1,133 @@
+// 库存控制的本地实现，接口对应rtp服务的GetBaccaratRtp/SettleBaccarat/ResetBaccarat，
+// 但算法是独立实现的，不保证与rtp服务的结果一致，只用于在没有rtp服务的情况下测试和模拟。
+//
+// 每个(商户, 品牌, 游戏, rtp档位)一个库存池，每局结算后
+//
+//	库存 += 下注总额 * rtp - 派奖总额 = 平台盈利 - 下注总额 * (1 - rtp)
+//
+// 即玩家按目标rtp应得的部分进入库存，派奖从库存中支出，抽水(1-rtp)不进入库存。
+// 库存为负说明玩家实际拿到的超过了目标rtp，此时按亏空的深度提高强制坠机的概率，直到库存回正。
+package stock
+
+import (
+	"sync"
+)
+
+// 强制坠机的概率，与GetBaccaratRtpReply的Rate1/Rate2含义相同
+type Rates struct {
+	Rate1 float64 // 强制在1倍坠机的概率
+	Rate2 float64 // 强制在2倍坠机的概率
+}
+
+// 根据[0,1)的随机数决定是否强制坠机，返回强制的坠机倍数
+func (r Rates) Force(u float64) (float64, bool) {
+	if r.Rate1 > 0 && u < r.Rate1 {
+		return 1, true
+	}
+	if r.Rate2 > 0 && u < r.Rate2 {
+		return 2, true
+	}
+	return 0, false
+}
+
+type Config struct {
+	InitStock float64 // 初始库存，也是重置后的库存
+	KillLine  float64 // 库存低于0时开始控制，低于这个值(负数)时达到最大概率
+	MaxRate1  float64
+	MaxRate2  float64
+}
+
+const (
+	DefaultKillLine = -10000
+	DefaultMaxRate1 = 0.05
+	DefaultMaxRate2 = 0.15
+)
+
+func DefaultConfig() Config {
+	return Config{KillLine: DefaultKillLine, MaxRate1: DefaultMaxRate1, MaxRate2: DefaultMaxRate2}
+}
+
+type Key struct {
+	AppId     string
+	GameBrand string
+	GameId    string
+	Rtp       string
+}
+
+type Pool struct {
+	Stock       float64 `json:"stock"`
+	BetTotal    float64 `json:"bet_total"`
+	ProfitTotal float64 `json:"profit_total"`
+	Rounds      int64   `json:"rounds"`
+}
+
+// 实际的rtp(%)
+func (p Pool) Rtp() float64 {
+	if p.BetTotal == 0 {
+		return 0
+	}
+	return (p.BetTotal - p.ProfitTotal) / p.BetTotal * 100
+}
+
+type Controller struct {
+	cfg Config
+
+	mu    sync.Mutex
+	pools map[Key]*Pool
+}
+
+func NewController(cfg Config) *Controller {
+	if cfg.KillLine >= 0 {
+		cfg.KillLine = DefaultKillLine
+	}
+	return &Controller{cfg: cfg, pools: make(map[Key]*Pool)}
+}
+
+func (c *Controller) pool(key Key) *Pool {
+	p, ok := c.pools[key]
+	if !ok {
+		p = &Pool{Stock: c.cfg.InitStock}
+		c.pools[key] = p
+	}
+	return p
+}
+
+// 当前库存对应的强制坠机概率
+func (c *Controller) Rates(key Key) Rates {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rates(c.pool(key))
+}
+
+func (c *Controller) rates(p *Pool) Rates {
+	if p.Stock >= 0 {
+		return Rates{}
+	}
+	depth := min(p.Stock/c.cfg.KillLine, 1)
+	return Rates{Rate1: depth * c.cfg.MaxRate1, Rate2: depth * c.cfg.MaxRate2}
+}
+
+// 一局结束后上报下注总额和平台盈利(下注 - 派奖)，rtp为百分比
+func (c *Controller) Settle(key Key, rtp, betTotal, profitTotal float64) Pool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.pool(key)
+	p.Stock += profitTotal - betTotal*(1-rtp/100)
+	p.BetTotal += betTotal
+	p.ProfitTotal += profitTotal
+	p.Rounds++
+	return *p
+}
+
+// 重置库存，运营调整后使用
+func (c *Controller) Reset(key Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools[key] = &Pool{Stock: c.cfg.InitStock}
+}
+
+func (c *Controller) Pool(key Key) Pool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.pool(key)
+}
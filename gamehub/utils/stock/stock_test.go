@@ -0,0 +1,74 @@
+package stock
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+var testKey = Key{AppId: "app", GameBrand: "spribe", GameId: "aviator", Rtp: "95"}
+
+func TestControllerRates(t *testing.T) {
+	c := NewController(Config{KillLine: -100, MaxRate1: 0.1, MaxRate2: 0.2})
+	if r := c.Rates(testKey); r != (Rates{}) {
+		t.Fatalf("unexpected rates %+v", r)
+	}
+
+	// 下注100，派奖155，平台亏55，库存 = -55 - 100*0.05 = -60
+	p := c.Settle(testKey, 95, 100, -55)
+	if math.Abs(p.Stock+60) > 1e-9 || p.Rounds != 1 || math.Abs(p.Rtp()-155) > 1e-9 {
+		t.Fatalf("unexpected pool %+v", p)
+	}
+	if r := c.Rates(testKey); math.Abs(r.Rate1-0.06) > 1e-9 || math.Abs(r.Rate2-0.12) > 1e-9 {
+		t.Fatalf("unexpected rates %+v", r)
+	}
+
+	// 超过kill line后不再增加
+	c.Settle(testKey, 95, 100, -200)
+	if r := c.Rates(testKey); r != (Rates{Rate1: 0.1, Rate2: 0.2}) {
+		t.Fatalf("unexpected rates %+v", r)
+	}
+
+	c.Reset(testKey)
+	if p := c.Pool(testKey); p.Stock != 0 || p.Rounds != 0 {
+		t.Fatalf("unexpected pool after reset %+v", p)
+	}
+}
+
+func TestRatesForce(t *testing.T) {
+	r := Rates{Rate1: 0.1, Rate2: 0.3}
+	for _, c := range []struct {
+		u    float64
+		x    float64
+		want bool
+	}{{0.05, 1, true}, {0.2, 2, true}, {0.5, 0, false}} {
+		if x, ok := r.Force(c.u); x != c.x || ok != c.want {
+			t.Fatalf("Force(%v) = %v, %v", c.u, x, ok)
+		}
+	}
+}
+
+// 曲线本身的rtp为100，库存控制把实际rtp拉回到95
+func TestControllerSimulation(t *testing.T) {
+	c := NewController(Config{KillLine: -100, MaxRate1: 0.1, MaxRate2: 0.2})
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	const rounds, target = 500000, 3.0
+	for i := 0; i < rounds; i++ {
+		crash, ok := c.Rates(testKey).Force(rng.Float64())
+		if !ok {
+			// 没有抽水的坠机曲线
+			crash = math.Floor(100/(1-rng.Float64())) / 100
+		}
+		win := 0.0
+		if crash > target {
+			win = target
+		}
+		c.Settle(testKey, 95, 1, 1-win)
+	}
+
+	p := c.Pool(testKey)
+	if math.Abs(p.Rtp()-95) > 0.5 {
+		t.Fatalf("realized rtp %.4f, stock %.2f", p.Rtp(), p.Stock)
+	}
+}
@@ -3,12 +3,14 @@ package health
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
+
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
-	"time"
 )
 
 func Check(db *gorm.DB, rdb *redis.Client, opts ...HealthCheckOption) fiber.Handler {
@@ -27,6 +29,11 @@ func Check(db *gorm.DB, rdb *redis.Client, opts ...HealthCheckOption) fiber.Hand
 		ReadinessEndpoint: "/health/readyz",
 		// 自定义就绪检查逻辑
 		ReadinessProbe: func(c *fiber.Ctx) bool {
+			// 节点正在下线
+			if config.readiness != nil && !config.readiness.Ready() {
+				return false
+			}
+
 			// 检查数据库连接
 			if config.dbChecker != nil {
 				if err := config.dbChecker(db); err != nil {
@@ -54,6 +61,7 @@ type HealthCheckOption func(*healthCheckConfig)
 type healthCheckConfig struct {
 	dbChecker    func(*gorm.DB) error
 	redisChecker func(*redis.Client) error
+	readiness    *Readiness
 }
 
 // WithDBChecker 设置数据库检查器
@@ -70,6 +78,30 @@ func WithRedisChecker(checker func(*redis.Client) error) HealthCheckOption {
 	}
 }
 
+// WithReadiness 设置就绪开关，节点下线排空时置为未就绪，负载均衡不再转发新的连接
+func WithReadiness(readiness *Readiness) HealthCheckOption {
+	return func(config *healthCheckConfig) {
+		config.readiness = readiness
+	}
+}
+
+// Readiness 就绪开关，默认就绪
+type Readiness struct {
+	unready atomic.Bool
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+func (r *Readiness) Ready() bool {
+	return !r.unready.Load()
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	r.unready.Store(!ready)
+}
+
 // defaultRedisCheck 默认Redis检查
 func defaultRedisCheck(redisClient *redis.Client) error {
 	if redisClient == nil {
@@ -0,0 +1,97 @@
+package slot
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 本地选样的结果，字段与rtp服务的SelectSpinReply保持一致
+type SpinSelection struct {
+	SpinId        int64   // 样本id，分组样本为group_id
+	TableName     string  // 本次使用的样本表
+	Rtp           string  // 本次rtp档位
+	Rate          float64 // 样本倍率(相对originBet)
+	RoundModel    string
+	RoundExtModel string
+	Bet           float64 // 实际扣款 = originBet * cost
+	OriginBet     float64
+	IsSpecial     bool
+}
+
+// 按局的模式选取样本，流程与DEFAULT一致: 判断特殊模式 -> 按权重取rate -> 按rate取样本
+func (r *Rtp) SelectSpin(gi string, rtp string, bet float64, roundModel string, roundExtModel string) (*SpinSelection, error) {
+	if rtp == "" {
+		if v, ok := r.rtpConfig.Load(r.brand + "_spin_" + gi); ok {
+			rtp = v.(*RtpConfig).Use
+		}
+	}
+	rateConfig, ok := r.GetRtpConfig(gi, rtp)
+	if !ok {
+		return nil, fmt.Errorf("rtp config %s of %s not found", rtp, gi)
+	}
+
+	modelConfig, cost, tableSuffix, err := rateConfig.ForRoundModel(roundModel, roundExtModel)
+	if err != nil {
+		return nil, err
+	}
+
+	isSpecial := r.IsSpecialModeTriggered(modelConfig)
+	weights, err := r.LoadWeightsFromJSON(modelConfig, isSpecial)
+	if err != nil {
+		return nil, err
+	}
+	rate := r.GetRateByWeight(weights)
+
+	tableName := sampleTableName(r.brand, gi, tableSuffix)
+	ret, err := r.GetOneSimpleByRate2(tableName, isSpecial, rate)
+	if err != nil {
+		return nil, err
+	}
+	id, ok := ret.(uint)
+	if !ok {
+		return nil, fmt.Errorf("no sample of rate %.6f in %s", rate, tableName)
+	}
+
+	if roundModel == "" {
+		roundModel = RoundModelDefault
+	}
+	return &SpinSelection{
+		SpinId:        int64(id),
+		TableName:     tableName,
+		Rtp:           rtp,
+		Rate:          rate,
+		RoundModel:    roundModel,
+		RoundExtModel: roundExtModel,
+		Bet:           bet * cost,
+		OriginBet:     bet,
+		IsSpecial:     isSpecial,
+	}, nil
+}
+
+// 加载默认样本表以及配置中各模式使用的样本表
+func (r *Rtp) LoadRoundModelSamples(ctx context.Context, db *gorm.DB, gameId string) ([]SampleStats, error) {
+	suffixes := []string{""}
+	seen := map[string]struct{}{"": {}}
+	if v, ok := r.rtpConfig.Load(r.brand + "_spin_" + gameId); ok {
+		for _, rateConfig := range v.(*RtpConfig).Data {
+			for _, model := range rateConfig.Models {
+				if _, ok := seen[model.Table]; !ok {
+					seen[model.Table] = struct{}{}
+					suffixes = append(suffixes, model.Table)
+				}
+			}
+		}
+	}
+
+	var all []SampleStats
+	for _, suffix := range suffixes {
+		stats, err := r.LoadSamplesWithOptions(ctx, db, gameId, SampleLoadOptions{TableSuffix: suffix})
+		if err != nil {
+			return all, err
+		}
+		all = append(all, stats)
+	}
+	return all, nil
+}
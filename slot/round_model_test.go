@@ -0,0 +1,70 @@
+package slot
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const roundModelConfig = `{
+	"use": "95",
+	"95": {
+		"rate": 0,
+		"normal": [{"rate": 1, "weighting": 1}],
+		"models": {
+			"BUY:1": {"cost": 100, "table": "buy", "rate": 1, "special": [{"rate": 96, "weighting": 1}]},
+			"BUY": {"cost": 50, "rate": 1, "special": [{"rate": 40, "weighting": 1}]},
+			"EXTRA:1.5": {"rate": 0, "normal": [{"rate": 2, "weighting": 1}]}
+		}
+	}
+}`
+
+func TestSelectSpinRoundModel(t *testing.T) {
+	var config RtpConfig
+	if err := json.Unmarshal([]byte(roundModelConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewLocalRtp("pg")
+	r.SetRtpConfig("98", &config)
+	if err := r.CacheSimpleByRate("98", []SpinData{
+		{ID: 1, Rate: 1},
+		{ID: 2, Rate: 2},
+		{ID: 3, Rate: 40, GameType: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CacheSimpleByRate2("pg_spin_98_buy", []SpinData{{ID: 7, Rate: 96, GameType: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		model, ext string
+		wantId     int64
+		wantTable  string
+		wantBet    float64
+		wantSpec   bool
+	}{
+		{"", "", 1, "pg_spin_98", 2, false},
+		{RoundModelDefault, "", 1, "pg_spin_98", 2, false},
+		{RoundModelBuy, "1", 7, "pg_spin_98_buy", 200, true},
+		{RoundModelBuy, "2", 3, "pg_spin_98", 100, true},
+		{RoundModelExtra, "1.5", 2, "pg_spin_98", 3, false},
+	}
+	for _, tt := range tests {
+		sel, err := r.SelectSpin("98", "", 2, tt.model, tt.ext)
+		if err != nil {
+			t.Fatalf("%s:%s: %v", tt.model, tt.ext, err)
+		}
+		if sel.SpinId != tt.wantId || sel.TableName != tt.wantTable || sel.Bet != tt.wantBet ||
+			sel.IsSpecial != tt.wantSpec || sel.OriginBet != 2 || sel.Rtp != "95" {
+			t.Fatalf("%s:%s: unexpected selection %+v", tt.model, tt.ext, sel)
+		}
+	}
+
+	if _, err := r.SelectSpin("98", "95", 2, RoundModelExtra, "3"); err == nil {
+		t.Fatal("expected error for unconfigured EXTRA:3")
+	}
+	if _, err := r.SelectSpin("98", "95", 2, "FREE", ""); err == nil {
+		t.Fatal("expected error for unknown round model")
+	}
+}
@@ -1,12 +1,11 @@
 package slot
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"math/rand/v2"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,7 +21,9 @@ type Rtp struct {
 	rtpConfig   *sync.Map
 
 	cacheByLocal  bool
-	localCacheMap sync.Map //本地存储的容器
+	localCacheMap sync.Map //本地存储的容器，tableName -> *sampleIndex
+	reloading     sync.Map //正在后台加载的表
+	redisVersions sync.Map //redis模式下缓存的当前版本，tableName -> *redisVersion
 }
 
 type SpinData struct {
@@ -37,6 +38,26 @@ func NewRtp(brand string, redisClient *redisClient.Client, consulAdd string, con
 	return rtp
 }
 
+// 样本索引存放在redis中，多个pod共享一份，由一个加载方写入(LoadSamples)，其它pod只读
+func NewRedisRtp(brand string, redisClient *redisClient.Client, consulAdd string, consulToken string) *Rtp {
+	if redisClient == nil {
+		panic("slot: redis client is required for redis sample cache")
+	}
+	rtp := &Rtp{brand: brand, redisClient: redisClient, rtpConfig: new(sync.Map), cacheByLocal: false}
+	rtp.loadRtpConfig(consulAdd, consulToken)
+	return rtp
+}
+
+// 不连接consul和redis，仅使用本地缓存，用于离线模拟和测试
+func NewLocalRtp(brand string) *Rtp {
+	return &Rtp{brand: brand, rtpConfig: new(sync.Map), cacheByLocal: true}
+}
+
+// 手动设置某个游戏的rtp配置(不经过consul)
+func (r *Rtp) SetRtpConfig(gi string, config *RtpConfig) {
+	r.rtpConfig.Store(r.brand+"_spin_"+gi, config)
+}
+
 func (r *Rtp) loadRtpConfig(consulAdd string, consulToken string) {
 	consulClient, err := api.NewClient(&api.Config{
 		Address: consulAdd,
@@ -83,8 +104,10 @@ func (r *Rtp) loadRtpConfig(consulAdd string, consulToken string) {
 
 		r.rtpConfig.Store(tableName, &config)
 
-		// 清除缓存
-		r.ClearCacheByRate(tableName)
+		// 清除缓存，redis样本是多个节点共享的，由加载方负责替换
+		if r.cacheByLocal {
+			r.ClearCacheByRate2(tableName)
+		}
 	}
 
 	// 初始化加载和监听变化的通用函数
@@ -175,70 +198,51 @@ func (r *Rtp) loadRtpConfig(consulAdd string, consulToken string) {
 // 有没有缓存数据
 func (r *Rtp) HasCacheSimpleRate(gi string) (bool, error) {
 	tableName := r.brand + "_spin_" + gi
-	cacheKey := fmt.Sprintf("%s_normal:all_rates", tableName)
-
-	_, ok := r.localCacheMap.Load(cacheKey)
-	return ok, nil
+	return r.HasCacheSimpleRate2(tableName)
 }
 
 func (r *Rtp) HasCacheSimpleRate2(tableName string) (bool, error) {
-
-	cacheKey := fmt.Sprintf("%s_normal:all_rates", tableName)
-
-	_, ok := r.localCacheMap.Load(cacheKey)
-	return ok, nil
+	if !r.cacheByLocal {
+		return r.hasRedisSamples(context.Background(), tableName)
+	}
+	idx := r.loadIndex(tableName)
+	return idx != nil && len(idx.normal.allRates) > 0, nil
 }
 
-// 以rate为key，缓存样本的id
+// 以rate为key，缓存样本的id，每次调用整体替换已有的缓存
 func (r *Rtp) CacheSimpleByRate(gi string, records []SpinData) error {
 	tableName := r.brand + "_spin_" + gi
 	return r.CacheSimpleByRate2(tableName, records)
 }
 
 func (r *Rtp) CacheSimpleByRate2(tableName string, records []SpinData) error {
-	// 使用更高效的数据结构来存储
-	rateSetMap := make(map[string][]interface{})
-	allRateSetMap := make(map[string]map[string]struct{}) // 使用map实现去重
-
-	for _, rec := range records {
-		rateStr := fmt.Sprintf("%.6f", rec.Rate)
-
-		// 根据gameType确定key的前缀
-		prefix := "normal"
-		if rec.GameType == 1 {
-			prefix = "special"
-		}
-
-		// 构建keys
-		rateKey := fmt.Sprintf("%s_%s:rate:%s", tableName, prefix, rateStr)
-		allRateKey := fmt.Sprintf("%s_%s:all_rates", tableName, prefix)
+	return r.cacheSimpleByRate(tableName, records, false)
+}
 
-		// 聚合ID到对应的rateKey
-		rateSetMap[rateKey] = append(rateSetMap[rateKey], rec.ID)
+// 追加到已有的缓存中，同一个rate下重复的id只保留一个。
+// 本地模式每次追加都会复制已有的索引，样本量大时使用LoadSamples分页加载
+func (r *Rtp) AppendSimpleByRate(gi string, records []SpinData) error {
+	tableName := r.brand + "_spin_" + gi
+	return r.AppendSimpleByRate2(tableName, records)
+}
 
-		// 初始化allRateSetMap的子map（如果不存在）
-		if _, exists := allRateSetMap[allRateKey]; !exists {
-			allRateSetMap[allRateKey] = make(map[string]struct{})
-		}
+func (r *Rtp) AppendSimpleByRate2(tableName string, records []SpinData) error {
+	return r.cacheSimpleByRate(tableName, records, true)
+}
 
-		// 使用map特性自动去重
-		allRateSetMap[allRateKey][rateStr] = struct{}{}
+func (r *Rtp) cacheSimpleByRate(tableName string, records []SpinData, merge bool) error {
+	ctx := context.Background()
+	start := time.Now()
+	sink, err := r.newSampleSink(ctx, tableName, false, merge)
+	if err != nil {
+		return err
 	}
-
-	for key, members := range rateSetMap {
-		log.Print("key", key)
-		r.localCacheMap.Store(key, members)
+	if err := sink.add(ctx, records); err != nil {
+		sink.abort()
+		return err
 	}
-
-	for key, rateSet := range allRateSetMap {
-		var rateSlice []interface{}
-		for rate := range rateSet {
-			rateSlice = append(rateSlice, rate)
-		}
-		r.localCacheMap.Store(key, rateSlice)
-	}
-
-	return nil
+	_, err = sink.commit(ctx, start)
+	return err
 }
 
 // 获取样本所有的rate
@@ -248,106 +252,65 @@ func (r *Rtp) GetRoundRate(gi string, isSpecial bool) float64 {
 }
 
 func (r *Rtp) GetRoundRate2(tableName string, isSpecial bool) float64 {
-	allRateKey := ""
-	if isSpecial {
-		allRateKey = fmt.Sprintf("%s_special:all_rates", tableName)
-	} else {
-		allRateKey = fmt.Sprintf("%s_normal:all_rates", tableName)
-	}
-	if list, ok := r.localCacheMap.Load(allRateKey); ok {
-		if rates, ok := list.([]interface{}); ok && len(rates) > 0 {
-			// 随机选择一个索引
-			randomIndex := rand.IntN(len(rates))
-			if rateStr, ok := rates[randomIndex].(string); ok {
-				if rate, err := strconv.ParseFloat(rateStr, 64); err == nil {
-					return rate
-				}
-			}
-		}
+	if !r.cacheByLocal {
+		return r.redisRoundRate(context.Background(), tableName, isSpecial)
+	}
+	idx := r.loadIndex(tableName)
+	if idx == nil {
+		return 0
+	}
+	if rates := idx.mode(isSpecial).allRates; len(rates) > 0 {
+		// 随机选择一个索引
+		return fixedToRate(rates[rand.IntN(len(rates))])
 	}
 
 	return 0
 }
 
 // 通过rate随机获取一条样本数据
+// 分组样本(GameInfo.IsGroupSpinData)返回的是group_id
 func (r *Rtp) GetOneSimpleByRate(gi string, isSpecial bool, rate float64) (interface{}, error) {
 	tableName := r.brand + "_spin_" + gi
 	return r.GetOneSimpleByRate2(tableName, isSpecial, rate)
 }
 
 func (r *Rtp) GetOneSimpleByRate2(tableName string, isSpecial bool, rate float64) (interface{}, error) {
-	rateKey := ""
-	// 如果是特殊模式的，则保存到special:rate:xxx的集合中
-	if isSpecial {
-		rateKey = fmt.Sprintf("%s_special:rate:%.6f", tableName, rate)
-	} else {
-		rateKey = fmt.Sprintf("%s_normal:rate:%.6f", tableName, rate)
+	if !r.cacheByLocal {
+		return r.redisPickSample(context.Background(), tableName, isSpecial, rate)
 	}
-
-	var id interface{}
-	if list, ok := r.localCacheMap.Load(rateKey); ok {
-		if ids, ok := list.([]interface{}); ok {
-			if len(ids) > 0 {
-				// 从本地缓存中随机选择一个 ID
-				randomIndex := rand.IntN(len(ids))
-				id = ids[randomIndex]
-			}
-		} else {
-			return nil, errors.New("没有发现配置信息")
-		}
-	} else {
+	idx := r.loadIndex(tableName)
+	if idx == nil {
+		return nil, errors.New("没有发现配置信息")
+	}
+	// 如果是特殊模式的，则从special的集合中随机取一个 ID
+	id, ok := idx.mode(isSpecial).pick(rateToFixed(rate))
+	if !ok {
 		return nil, errors.New("没有发现配置信息")
 	}
 
 	return id, nil
 }
 
+// 清除样本缓存，redis模式下旧版本的key设置过期时间后删除
 func (r *Rtp) ClearCacheByRate(gi string) error {
-	// tableName := "pg_spin_" + strconv.Itoa(gi)
-	// ctx := context.Background()
-
-	// // 构建可能的缓存键模式
-	// specialRatePattern := fmt.Sprintf("%s_special:rate:*", tableName)
-	// normalRatePattern := fmt.Sprintf("%s_normal:rate:*", tableName)
-	// specialAllRatesKey := fmt.Sprintf("%s_special:all_rates", tableName)
-	// normalAllRatesKey := fmt.Sprintf("%s_normal:all_rates", tableName)
-
-	// // 查找并删除特殊模式的 rate 缓存键
-	// specialRateKeys, err := r.redisClient.Keys(ctx, specialRatePattern).Result()
-	// if err != nil {
-	// 	log.Printf("Failed to get special rate keys: %v", err)
-	// 	return err
-	// }
-	// if len(specialRateKeys) > 0 {
-	// 	if err := r.redisClient.Del(ctx, specialRateKeys...).Err(); err != nil {
-	// 		log.Printf("Failed to delete special rate keys: %v", err)
-	// 		return err
-	// 	}
-	// }
-
-	// // 查找并删除普通模式的 rate 缓存键
-	// normalRateKeys, err := r.redisClient.Keys(ctx, normalRatePattern).Result()
-	// if err != nil {
-	// 	log.Printf("Failed to get normal rate keys: %v", err)
-	// 	return err
-	// }
-	// if len(normalRateKeys) > 0 {
-	// 	if err := r.redisClient.Del(ctx, normalRateKeys...).Err(); err != nil {
-	// 		log.Printf("Failed to delete normal rate keys: %v", err)
-	// 		return err
-	// 	}
-	// }
-
-	// // 删除特殊模式和普通模式的 all_rates 键
-	// if err := r.redisClient.Del(ctx, specialAllRatesKey, normalAllRatesKey).Err(); err != nil {
-	// 	log.Printf("Failed to delete all_rates keys: %v", err)
-	// 	return err
-	// }
+	tableName := r.brand + "_spin_" + gi
+	return r.ClearCacheByRate2(tableName)
+}
 
+func (r *Rtp) ClearCacheByRate2(tableName string) error {
+	if !r.cacheByLocal {
+		return r.clearRedisSamples(context.Background(), tableName)
+	}
+	r.localCacheMap.Delete(tableName)
 	return nil
 }
 
 func (r *Rtp) GetRateByWeight(ratesWithWeights []RateWeight) float64 {
+	return RateByWeight(ratesWithWeights, nil)
+}
+
+// 按权重随机选择rate，rng为nil时使用全局随机数，离线模拟传入固定种子的rng使结果可以复现
+func RateByWeight(ratesWithWeights []RateWeight, rng *rand.Rand) float64 {
 	if len(ratesWithWeights) == 0 {
 		return 0
 	}
@@ -362,7 +325,7 @@ func (r *Rtp) GetRateByWeight(ratesWithWeights []RateWeight) float64 {
 	}
 
 	// 生成一个 0 到总权重之间的随机数
-	randomNum := rand.IntN(totalWeight)
+	randomNum := randIntN(rng, totalWeight)
 
 	currentWeight := 0
 	for _, rw := range ratesWithWeights {
@@ -430,6 +393,11 @@ func (r *Rtp) GetRtpConfig2(tableName string, rtp string) (*RateConfig, bool) {
 }
 
 func (r *Rtp) IsSpecialModeTriggered(rateConfig *RateConfig) bool {
+	return SpecialModeTriggered(rateConfig, nil)
+}
+
+// 是否触发特殊模式，rng为nil时使用全局随机数
+func SpecialModeTriggered(rateConfig *RateConfig, rng *rand.Rand) bool {
 
 	// 5. 校验Rate值合法性 (0 <= Rate <= 1)
 	if rateConfig.Rate < 0 || rateConfig.Rate > 1 {
@@ -445,7 +413,21 @@ func (r *Rtp) IsSpecialModeTriggered(rateConfig *RateConfig) bool {
 		return true
 	}
 
-	return rand.Float64() < rateConfig.Rate
+	return randFloat64(rng) < rateConfig.Rate
+}
+
+func randIntN(rng *rand.Rand, n int) int {
+	if rng == nil {
+		return rand.IntN(n)
+	}
+	return rng.IntN(n)
+}
+
+func randFloat64(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
 }
 
 func (r *Rtp) LoadWeightsFromJSON(rateConfig *RateConfig, isSpecial bool) ([]RateWeight, error) {
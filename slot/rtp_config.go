@@ -1,6 +1,18 @@
 package slot
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 局的模式，与rtp服务SelectSpinRequest.round_model一致
+const (
+	RoundModelDefault = "DEFAULT"
+	RoundModelExtra   = "EXTRA" // 额外下注，round_ext_model为下注倍数，如1.5、3
+	RoundModelBuy     = "BUY"   // 购买免费游戏，round_ext_model为购买档位，如1、2、3
+)
 
 type RateWeight struct {
 	Rate      float64 `json:"rate"`
@@ -11,6 +23,54 @@ type RateConfig struct {
 	Rate    float64      `json:"rate"`
 	Normal  []RateWeight `json:"normal"`
 	Special []RateWeight `json:"special"`
+	// 非DEFAULT模式的权重表，key为RoundModelKey，例如"BUY:1"、"EXTRA:1.5"，也可只配置"BUY"作为该模式的默认值
+	Models map[string]RoundModelConfig `json:"models,omitempty"`
+}
+
+type RoundModelConfig struct {
+	Cost    float64      `json:"cost"`  // 成本倍数，实际扣款 = bet * cost，EXTRA不配置时取round_ext_model
+	Table   string       `json:"table"` // 样本表后缀，样本表为<brand>_spin_<gi>_<table>，为空时使用默认样本表
+	Rate    float64      `json:"rate"`  // 特殊模式触发率，BUY一般为1
+	Normal  []RateWeight `json:"normal"`
+	Special []RateWeight `json:"special"`
+}
+
+func RoundModelKey(roundModel, roundExtModel string) string {
+	roundModel = strings.ToUpper(strings.TrimSpace(roundModel))
+	roundExtModel = strings.TrimSpace(roundExtModel)
+	if roundExtModel == "" {
+		return roundModel
+	}
+	return roundModel + ":" + roundExtModel
+}
+
+// 获取某个模式下使用的权重配置、成本倍数和样本表后缀，DEFAULT(或为空)返回自身
+func (c *RateConfig) ForRoundModel(roundModel, roundExtModel string) (*RateConfig, float64, string, error) {
+	roundModel = strings.ToUpper(strings.TrimSpace(roundModel))
+	if roundModel == "" || roundModel == RoundModelDefault {
+		return c, 1, "", nil
+	}
+	if roundModel != RoundModelExtra && roundModel != RoundModelBuy {
+		return nil, 0, "", fmt.Errorf("unsupported round model %s", roundModel)
+	}
+
+	key := RoundModelKey(roundModel, roundExtModel)
+	model, ok := c.Models[key]
+	if !ok {
+		if model, ok = c.Models[roundModel]; !ok {
+			return nil, 0, "", fmt.Errorf("round model %s not configured", key)
+		}
+	}
+
+	cost := model.Cost
+	if cost <= 0 && roundModel == RoundModelExtra {
+		cost, _ = strconv.ParseFloat(strings.TrimSpace(roundExtModel), 64)
+	}
+	if cost <= 0 {
+		return nil, 0, "", fmt.Errorf("round model %s has no cost", key)
+	}
+
+	return &RateConfig{Rate: model.Rate, Normal: model.Normal, Special: model.Special}, cost, model.Table, nil
 }
 
 type RtpConfig struct {
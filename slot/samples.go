@@ -0,0 +1,402 @@
+package slot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/card-engine/game_common/models"
+	"gorm.io/gorm"
+)
+
+const DefaultSamplePageSize = 50000
+
+// rate统一转为6位小数的定点整数作为key，与原先fmt.Sprintf("%.6f")的精度一致
+const rateScale = 1_000_000
+
+func rateToFixed(rate float64) int64 {
+	return int64(math.Round(rate * rateScale))
+}
+
+func fixedToRate(v int64) float64 {
+	return float64(v) / rateScale
+}
+
+// 同一个rate的样本id在ids中的区间[start, end)
+type rateBucket struct {
+	start, end int
+}
+
+// 单个模式(normal/special)下的样本索引，所有id连续存放，id都能放进uint32时只用ids32
+type modeIndex struct {
+	buckets  map[int64]rateBucket // 定点rate -> 样本id区间
+	allRates []int64              // 所有的rate
+	ids32    []uint32
+	ids64    []uint64
+}
+
+func (m *modeIndex) samples() int {
+	return len(m.ids32) + len(m.ids64)
+}
+
+// 随机取rate对应的一个样本id
+func (m *modeIndex) pick(rate int64) (uint, bool) {
+	b, ok := m.buckets[rate]
+	if !ok {
+		return 0, false
+	}
+	i := b.start + rand.IntN(b.end-b.start)
+	if m.ids64 != nil {
+		return uint(m.ids64[i]), true
+	}
+	return uint(m.ids32[i]), true
+}
+
+// map每个entry约按key+value+溢出桶开销32字节估算
+func (m *modeIndex) memoryBytes() int64 {
+	n := int64(len(m.buckets)) * (8 + 16 + 32)
+	n += int64(cap(m.allRates)) * 8
+	n += int64(cap(m.ids32))*4 + int64(cap(m.ids64))*8
+	return n
+}
+
+// 按rate顺序遍历所有样本id
+func (m *modeIndex) each(fn func(rate int64, id uint64)) {
+	for _, rate := range m.allRates {
+		b := m.buckets[rate]
+		for i := b.start; i < b.end; i++ {
+			if m.ids64 != nil {
+				fn(rate, m.ids64[i])
+			} else {
+				fn(rate, uint64(m.ids32[i]))
+			}
+		}
+	}
+}
+
+// 一张样本表的完整索引，整体替换，保证读到的是同一个版本
+type sampleIndex struct {
+	normal  modeIndex
+	special modeIndex
+	stats   SampleStats
+}
+
+func (idx *sampleIndex) mode(isSpecial bool) *modeIndex {
+	if isSpecial {
+		return &idx.special
+	}
+	return &idx.normal
+}
+
+// 样本加载的统计信息
+type SampleStats struct {
+	TableName      string        `json:"table_name"`
+	Group          bool          `json:"group"`           // 是否按group_id分组加载
+	Rows           int           `json:"rows"`            // 加载的样本数(分组时为组数)
+	NormalRates    int           `json:"normal_rates"`    // normal的rate种类
+	SpecialRates   int           `json:"special_rates"`   // special的rate种类
+	NormalSamples  int           `json:"normal_samples"`  // normal的样本数
+	SpecialSamples int           `json:"special_samples"` // special的样本数
+	MemoryBytes    int64         `json:"memory_bytes"`    // 索引占用内存的估算值
+	Duration       time.Duration `json:"duration"`
+	LoadedAt       time.Time     `json:"loaded_at"`
+}
+
+// 加载过程中先按rate分组暂存，build时再压缩成连续的数组
+type modeBuilder struct {
+	pending map[int64][]uint64
+	rates   []int64
+	maxID   uint64
+	seen    map[sampleKey]struct{} // 追加缓存时用于去重，为nil时不去重
+}
+
+type sampleKey struct {
+	rate int64
+	id   uint64
+}
+
+// 返回是否加入，重复的id不加入
+func (m *modeBuilder) add(rate int64, id uint64) bool {
+	if m.seen != nil {
+		key := sampleKey{rate: rate, id: id}
+		if _, ok := m.seen[key]; ok {
+			return false
+		}
+		m.seen[key] = struct{}{}
+	}
+	ids, ok := m.pending[rate]
+	if !ok {
+		m.rates = append(m.rates, rate)
+	}
+	m.pending[rate] = append(ids, id)
+	m.maxID = max(m.maxID, id)
+	return true
+}
+
+func (m *modeBuilder) build() modeIndex {
+	total := 0
+	for _, ids := range m.pending {
+		total += len(ids)
+	}
+
+	idx := modeIndex{
+		buckets:  make(map[int64]rateBucket, len(m.rates)),
+		allRates: slices.Clip(m.rates),
+	}
+	wide := m.maxID > math.MaxUint32
+	if wide {
+		idx.ids64 = make([]uint64, 0, total)
+	} else {
+		idx.ids32 = make([]uint32, 0, total)
+	}
+	for _, rate := range m.rates {
+		ids := m.pending[rate]
+		start := idx.samples()
+		if wide {
+			idx.ids64 = append(idx.ids64, ids...)
+		} else {
+			for _, id := range ids {
+				idx.ids32 = append(idx.ids32, uint32(id))
+			}
+		}
+		idx.buckets[rate] = rateBucket{start: start, end: idx.samples()}
+		// 尽早释放暂存的数据
+		delete(m.pending, rate)
+	}
+	return idx
+}
+
+type sampleIndexBuilder struct {
+	tableName string
+	group     bool
+	rows      int
+	normal    modeBuilder
+	special   modeBuilder
+}
+
+func newSampleIndexBuilder(tableName string) *sampleIndexBuilder {
+	return &sampleIndexBuilder{
+		tableName: tableName,
+		normal:    modeBuilder{pending: make(map[int64][]uint64)},
+		special:   modeBuilder{pending: make(map[int64][]uint64)},
+	}
+}
+
+func (b *sampleIndexBuilder) add(rec SpinData) {
+	mode := &b.normal
+	if rec.GameType == 1 {
+		mode = &b.special
+	}
+	if mode.add(rateToFixed(rec.Rate), uint64(rec.ID)) {
+		b.rows++
+	}
+}
+
+// 合并已有的索引，追加缓存时使用，之后加入的重复id被忽略
+func (b *sampleIndexBuilder) addIndex(idx *sampleIndex) {
+	for _, pair := range []struct {
+		builder *modeBuilder
+		index   *modeIndex
+	}{{&b.normal, &idx.normal}, {&b.special, &idx.special}} {
+		pair.builder.seen = make(map[sampleKey]struct{}, pair.index.samples())
+		pair.index.each(func(rate int64, id uint64) { pair.builder.add(rate, id) })
+	}
+	b.group = idx.stats.Group
+	b.rows += idx.stats.Rows
+}
+
+func (b *sampleIndexBuilder) build(start time.Time) *sampleIndex {
+	idx := &sampleIndex{
+		normal:  b.normal.build(),
+		special: b.special.build(),
+		stats:   SampleStats{TableName: b.tableName, Group: b.group, Rows: b.rows},
+	}
+	idx.stats.NormalRates = len(idx.normal.allRates)
+	idx.stats.SpecialRates = len(idx.special.allRates)
+	idx.stats.NormalSamples = idx.normal.samples()
+	idx.stats.SpecialSamples = idx.special.samples()
+	idx.stats.MemoryBytes = idx.normal.memoryBytes() + idx.special.memoryBytes()
+	idx.stats.LoadedAt = time.Now()
+	idx.stats.Duration = idx.stats.LoadedAt.Sub(start)
+	return idx
+}
+
+// 样本的写入目标，本地模式写入内存索引，redis模式写入共享的redis集合
+type sampleSink interface {
+	add(ctx context.Context, records []SpinData) error
+	// 写入完成后切换到新版本
+	commit(ctx context.Context, start time.Time) (SampleStats, error)
+	// 中途失败时丢弃已写入的数据
+	abort()
+}
+
+// merge为true时在已有的样本上追加，否则写入新的版本整体替换
+func (r *Rtp) newSampleSink(ctx context.Context, tableName string, group bool, merge bool) (sampleSink, error) {
+	if r.cacheByLocal {
+		builder := newSampleIndexBuilder(tableName)
+		builder.group = group
+		if idx := r.loadIndex(tableName); merge && idx != nil {
+			builder.addIndex(idx)
+		}
+		return &localSampleSink{r: r, builder: builder}, nil
+	}
+	return r.newRedisSampleSink(ctx, tableName, group, merge)
+}
+
+type localSampleSink struct {
+	r       *Rtp
+	builder *sampleIndexBuilder
+}
+
+func (s *localSampleSink) add(ctx context.Context, records []SpinData) error {
+	for _, rec := range records {
+		s.builder.add(rec)
+	}
+	return nil
+}
+
+func (s *localSampleSink) commit(ctx context.Context, start time.Time) (SampleStats, error) {
+	idx := s.builder.build(start)
+	s.r.storeIndex(s.builder.tableName, idx)
+	return idx.stats, nil
+}
+
+func (s *localSampleSink) abort() {}
+
+func (r *Rtp) loadIndex(tableName string) *sampleIndex {
+	if v, ok := r.localCacheMap.Load(tableName); ok {
+		return v.(*sampleIndex)
+	}
+	return nil
+}
+
+// 原子替换，替换前的读请求继续使用旧索引
+func (r *Rtp) storeIndex(tableName string, idx *sampleIndex) {
+	r.localCacheMap.Store(tableName, idx)
+}
+
+// 获取样本加载的统计信息
+func (r *Rtp) SampleStats(gi string) (SampleStats, bool) {
+	if !r.cacheByLocal {
+		return r.redisSampleStats(context.Background(), r.brand+"_spin_"+gi)
+	}
+	idx := r.loadIndex(r.brand + "_spin_" + gi)
+	if idx == nil {
+		return SampleStats{}, false
+	}
+	return idx.stats, true
+}
+
+type SampleLoadOptions struct {
+	PageSize int
+	// 为nil时从game_info表读取SpinDataModel判断
+	Group *bool
+	// 样本表后缀，用于BUY/EXTRA模式单独的样本表(RoundModelConfig.Table)
+	TableSuffix string
+}
+
+// 从<brand>_spin_<gi>表分页加载样本，加载完成后整体替换本地缓存
+func (r *Rtp) LoadSamples(ctx context.Context, db *gorm.DB, gameId string) (SampleStats, error) {
+	return r.LoadSamplesWithOptions(ctx, db, gameId, SampleLoadOptions{})
+}
+
+func (r *Rtp) LoadSamplesWithOptions(ctx context.Context, db *gorm.DB, gameId string, opts SampleLoadOptions) (SampleStats, error) {
+	if db == nil {
+		return SampleStats{}, errors.New("slot: db is nil")
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultSamplePageSize
+	}
+
+	group := false
+	if opts.Group != nil {
+		group = *opts.Group
+	} else {
+		var info models.GameInfo
+		err := db.WithContext(ctx).Where("game_brand = ? AND game_id = ?", r.brand, gameId).First(&info).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return SampleStats{}, fmt.Errorf("slot: load game_info %s/%s: %w", r.brand, gameId, err)
+		}
+		group = info.IsGroupSpinData()
+	}
+
+	start := time.Now()
+	tableName := sampleTableName(r.brand, gameId, opts.TableSuffix)
+	sink, err := r.newSampleSink(ctx, tableName, group, false)
+	if err != nil {
+		return SampleStats{}, err
+	}
+
+	var lastID uint
+	page := make([]SpinData, 0, opts.PageSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			sink.abort()
+			return SampleStats{}, err
+		}
+
+		page = page[:0]
+		query := db.WithContext(ctx).Table(tableName)
+		if group {
+			// 一局对应多行，有效倍率为组内MAX(rate)，取到的样本id为group_id
+			query = query.Select("group_id AS id, MAX(rate) AS rate, MAX(gameType) AS gameType").
+				Where("group_id > ?", lastID).Group("group_id").Order("group_id")
+		} else {
+			query = query.Select("id, rate, gameType").Where("id > ?", lastID).Order("id")
+		}
+		if err := query.Limit(opts.PageSize).Find(&page).Error; err != nil {
+			sink.abort()
+			return SampleStats{}, fmt.Errorf("slot: load samples from %s: %w", tableName, err)
+		}
+
+		if err := sink.add(ctx, page); err != nil {
+			sink.abort()
+			return SampleStats{}, err
+		}
+		if len(page) < opts.PageSize {
+			break
+		}
+		lastID = page[len(page)-1].ID
+	}
+
+	stats, err := sink.commit(ctx, start)
+	if err != nil {
+		return SampleStats{}, err
+	}
+	log.Printf("slot: loaded samples %s local=%v group=%v rows=%d normal=%d/%d special=%d/%d memory=%dKB in %s",
+		tableName, r.cacheByLocal, group, stats.Rows, stats.NormalRates, stats.NormalSamples,
+		stats.SpecialRates, stats.SpecialSamples, stats.MemoryBytes/1024, stats.Duration)
+	return stats, nil
+}
+
+func sampleTableName(brand, gameId, suffix string) string {
+	tableName := brand + "_spin_" + gameId
+	if suffix != "" {
+		tableName += "_" + suffix
+	}
+	return tableName
+}
+
+// 后台重新加载样本，加载期间继续使用旧的索引，同一张表同时只会有一个加载任务
+func (r *Rtp) ReloadSamplesAsync(ctx context.Context, db *gorm.DB, gameId string, done func(SampleStats, error)) bool {
+	tableName := r.brand + "_spin_" + gameId
+	if _, loading := r.reloading.LoadOrStore(tableName, struct{}{}); loading {
+		return false
+	}
+	go func() {
+		defer r.reloading.Delete(tableName)
+		stats, err := r.LoadSamples(ctx, db, gameId)
+		if err != nil {
+			log.Printf("slot: reload samples %s failed: %v", tableName, err)
+		}
+		if done != nil {
+			done(stats, err)
+		}
+	}()
+	return true
+}
@@ -0,0 +1,167 @@
+package slot
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// 旧版本的样本索引实现(sync.Map + 字符串key + interface{}装箱id)，仅用于基准对比
+type legacyIndex struct {
+	localCacheMap sync.Map
+}
+
+func (r *legacyIndex) cache(tableName string, records []SpinData) {
+	rateSetMap := make(map[string][]interface{})
+	allRateSetMap := make(map[string]map[string]struct{})
+	for _, rec := range records {
+		rateStr := fmt.Sprintf("%.6f", rec.Rate)
+		prefix := "normal"
+		if rec.GameType == 1 {
+			prefix = "special"
+		}
+		rateKey := fmt.Sprintf("%s_%s:rate:%s", tableName, prefix, rateStr)
+		allRateKey := fmt.Sprintf("%s_%s:all_rates", tableName, prefix)
+		rateSetMap[rateKey] = append(rateSetMap[rateKey], rec.ID)
+		if _, exists := allRateSetMap[allRateKey]; !exists {
+			allRateSetMap[allRateKey] = make(map[string]struct{})
+		}
+		allRateSetMap[allRateKey][rateStr] = struct{}{}
+	}
+	for key, members := range rateSetMap {
+		r.localCacheMap.Store(key, members)
+	}
+	for key, rateSet := range allRateSetMap {
+		var rateSlice []interface{}
+		for rate := range rateSet {
+			rateSlice = append(rateSlice, rate)
+		}
+		r.localCacheMap.Store(key, rateSlice)
+	}
+}
+
+func (r *legacyIndex) roundRate(tableName string) float64 {
+	if list, ok := r.localCacheMap.Load(tableName + "_normal:all_rates"); ok {
+		rates := list.([]interface{})
+		rate, _ := strconv.ParseFloat(rates[rand.IntN(len(rates))].(string), 64)
+		return rate
+	}
+	return 0
+}
+
+func (r *legacyIndex) pick(tableName string, rate float64) interface{} {
+	if list, ok := r.localCacheMap.Load(fmt.Sprintf("%s_normal:rate:%.6f", tableName, rate)); ok {
+		ids := list.([]interface{})
+		return ids[rand.IntN(len(ids))]
+	}
+	return nil
+}
+
+const (
+	benchTable   = "pg_spin_98"
+	benchSamples = 1_000_000
+	benchRates   = 2000
+)
+
+func benchRecords() ([]SpinData, []float64) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	rates := make([]float64, benchRates)
+	for i := range rates {
+		rates[i] = float64(i) * 0.05
+	}
+	records := make([]SpinData, benchSamples)
+	for i := range records {
+		gameType := 0
+		if i%50 == 0 {
+			gameType = 1
+		}
+		records[i] = SpinData{ID: uint(i + 1), Rate: rates[rng.IntN(len(rates))], GameType: gameType}
+	}
+	return records, rates
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+func BenchmarkSampleIndexMemory(b *testing.B) {
+	records, _ := benchRecords()
+
+	b.Run("legacy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			before := heapInUse()
+			idx := &legacyIndex{}
+			idx.cache(benchTable, records)
+			after := heapInUse()
+			b.ReportMetric(float64(after-before)/benchSamples, "heapB/sample")
+			runtime.KeepAlive(idx)
+		}
+	})
+	b.Run("compact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			before := heapInUse()
+			r := NewLocalRtp("pg")
+			_ = r.CacheSimpleByRate2(benchTable, records)
+			after := heapInUse()
+			b.ReportMetric(float64(after-before)/benchSamples, "heapB/sample")
+			runtime.KeepAlive(r)
+		}
+	})
+}
+
+func BenchmarkGetOneSimpleByRate(b *testing.B) {
+	records, rates := benchRecords()
+
+	b.Run("legacy", func(b *testing.B) {
+		idx := &legacyIndex{}
+		idx.cache(benchTable, records)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if idx.pick(benchTable, rates[i%len(rates)]) == nil {
+				b.Fatal("no sample")
+			}
+		}
+	})
+	b.Run("compact", func(b *testing.B) {
+		r := NewLocalRtp("pg")
+		_ = r.CacheSimpleByRate2(benchTable, records)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := r.GetOneSimpleByRate2(benchTable, false, rates[i%len(rates)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetRoundRate(b *testing.B) {
+	records, _ := benchRecords()
+
+	b.Run("legacy", func(b *testing.B) {
+		idx := &legacyIndex{}
+		idx.cache(benchTable, records)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = idx.roundRate(benchTable)
+		}
+	})
+	b.Run("compact", func(b *testing.B) {
+		r := NewLocalRtp("pg")
+		_ = r.CacheSimpleByRate2(benchTable, records)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = r.GetRoundRate2(benchTable, false)
+		}
+	})
+}
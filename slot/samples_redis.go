@@ -0,0 +1,447 @@
+package slot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	redisClient "github.com/redis/go-redis/v9"
+)
+
+// redis样本索引的key设计，{tableName}作为hash tag保证集群下同一张表落在同一个slot:
+//
+//	slot:samples:{pg_spin_98}:version                    当前版本号
+//	slot:samples:{pg_spin_98}:lock                       加载锁
+//	slot:samples:{pg_spin_98}:v<ver>:stats               加载统计(json)
+//	slot:samples:{pg_spin_98}:v<ver>:normal:rates        normal所有的定点rate
+//	slot:samples:{pg_spin_98}:v<ver>:normal:rate:<rate>  该rate的样本id
+//
+// 加载方把新版本完整写入后再切换version，旧版本的key设置过期时间，不使用KEYS扫描。
+const (
+	redisSampleKeyPrefix = "slot:samples:"
+
+	// 读取方缓存版本号的时间
+	redisVersionTTL = 3 * time.Second
+	// 旧版本保留的时间，需要大于redisVersionTTL，保证切换期间的读请求还能读到旧数据
+	redisOldVersionGrace = time.Minute
+	// 加载锁的超时时间，防止加载方崩溃后锁不释放，加载期间每redisLoadLockTTL/3续期一次
+	redisLoadLockTTL = time.Minute
+)
+
+// 锁还属于自己时才删除或续期，GET和DEL分开执行时锁可能已经过期并被其它节点拿到
+var (
+	redisUnlockScript = redisClient.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	redisRefreshLockScript = redisClient.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+var (
+	ErrSamplesLoading = errors.New("slot: samples are being loaded by another node")
+	ErrLoadLockLost   = errors.New("slot: samples load lock lost")
+)
+
+func redisSampleKey(tableName string) string {
+	return redisSampleKeyPrefix + "{" + tableName + "}"
+}
+
+func redisVersionKey(tableName string) string {
+	return redisSampleKey(tableName) + ":version"
+}
+
+func redisLockKey(tableName string) string {
+	return redisSampleKey(tableName) + ":lock"
+}
+
+func redisVersionPrefix(tableName, version string) string {
+	return redisSampleKey(tableName) + ":v" + version
+}
+
+func redisModeName(isSpecial bool) string {
+	if isSpecial {
+		return "special"
+	}
+	return "normal"
+}
+
+func redisRatesKey(versionPrefix string, isSpecial bool) string {
+	return versionPrefix + ":" + redisModeName(isSpecial) + ":rates"
+}
+
+func redisRateKey(versionPrefix string, isSpecial bool, rate int64) string {
+	return versionPrefix + ":" + redisModeName(isSpecial) + ":rate:" + strconv.FormatInt(rate, 10)
+}
+
+type redisVersion struct {
+	version   string
+	fetchedAt time.Time
+}
+
+// 当前版本号，本地缓存redisVersionTTL，空字符串表示没有数据
+func (r *Rtp) currentRedisVersion(ctx context.Context, tableName string) (string, error) {
+	if v, ok := r.redisVersions.Load(tableName); ok {
+		rv := v.(*redisVersion)
+		if time.Since(rv.fetchedAt) < redisVersionTTL {
+			return rv.version, nil
+		}
+	}
+	version, err := r.redisClient.Get(ctx, redisVersionKey(tableName)).Result()
+	if errors.Is(err, redisClient.Nil) {
+		version, err = "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	r.redisVersions.Store(tableName, &redisVersion{version: version, fetchedAt: time.Now()})
+	return version, nil
+}
+
+func (r *Rtp) hasRedisSamples(ctx context.Context, tableName string) (bool, error) {
+	version, err := r.currentRedisVersion(ctx, tableName)
+	if err != nil || version == "" {
+		return false, err
+	}
+	n, err := r.redisClient.SCard(ctx, redisRatesKey(redisVersionPrefix(tableName, version), false)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *Rtp) redisRoundRate(ctx context.Context, tableName string, isSpecial bool) float64 {
+	version, err := r.currentRedisVersion(ctx, tableName)
+	if err != nil || version == "" {
+		return 0
+	}
+	member, err := r.redisClient.SRandMember(ctx, redisRatesKey(redisVersionPrefix(tableName, version), isSpecial)).Result()
+	if err != nil {
+		return 0
+	}
+	rate, err := strconv.ParseInt(member, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return fixedToRate(rate)
+}
+
+func (r *Rtp) redisPickSample(ctx context.Context, tableName string, isSpecial bool, rate float64) (interface{}, error) {
+	version, err := r.currentRedisVersion(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return nil, errors.New("没有发现配置信息")
+	}
+	member, err := r.redisClient.SRandMember(ctx, redisRateKey(redisVersionPrefix(tableName, version), isSpecial, rateToFixed(rate))).Result()
+	if errors.Is(err, redisClient.Nil) {
+		return nil, errors.New("没有发现配置信息")
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseUint(member, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("slot: invalid sample id %q: %w", member, err)
+	}
+	return uint(id), nil
+}
+
+func (r *Rtp) redisSampleStats(ctx context.Context, tableName string) (SampleStats, bool) {
+	version, err := r.currentRedisVersion(ctx, tableName)
+	if err != nil || version == "" {
+		return SampleStats{}, false
+	}
+	data, err := r.redisClient.Get(ctx, redisVersionPrefix(tableName, version)+":stats").Bytes()
+	if err != nil {
+		return SampleStats{}, false
+	}
+	var stats SampleStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return SampleStats{}, false
+	}
+	return stats, true
+}
+
+// 删除当前版本，数据在redisOldVersionGrace后过期
+func (r *Rtp) clearRedisSamples(ctx context.Context, tableName string) error {
+	version, err := r.redisClient.Get(ctx, redisVersionKey(tableName)).Result()
+	if errors.Is(err, redisClient.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := r.redisClient.Del(ctx, redisVersionKey(tableName)).Err(); err != nil {
+		return err
+	}
+	r.redisVersions.Delete(tableName)
+	return r.expireRedisVersion(ctx, tableName, version, redisOldVersionGrace)
+}
+
+// 通过rates集合找到该版本所有的key并设置过期
+func (r *Rtp) expireRedisVersion(ctx context.Context, tableName, version string, ttl time.Duration) error {
+	prefix := redisVersionPrefix(tableName, version)
+	pipe := r.redisClient.Pipeline()
+	for _, isSpecial := range []bool{false, true} {
+		ratesKey := redisRatesKey(prefix, isSpecial)
+		members, err := r.redisClient.SMembers(ctx, ratesKey).Result()
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			rate, err := strconv.ParseInt(member, 10, 64)
+			if err != nil {
+				continue
+			}
+			pipe.Expire(ctx, redisRateKey(prefix, isSpecial, rate), ttl)
+		}
+		pipe.Expire(ctx, ratesKey, ttl)
+	}
+	pipe.Expire(ctx, prefix+":stats", ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// 按页写入redis，每页按rate聚合后批量SADD，不需要在内存中保留整张表
+type redisSampleSink struct {
+	r         *Rtp
+	tableName string
+	version   string
+	prefix    string
+	lockToken string
+	stats     SampleStats
+	rates     [2]map[int64]struct{} // 已写入的rate，[0]为normal，[1]为special
+	appending bool                  // 追加到当前版本，不切换版本
+	lockLost  atomic.Bool
+	stop      chan struct{}
+	once      sync.Once
+}
+
+func (r *Rtp) newRedisSampleSink(ctx context.Context, tableName string, group bool, merge bool) (*redisSampleSink, error) {
+	token := uuid.NewString()
+	ok, err := r.redisClient.SetNX(ctx, redisLockKey(tableName), token, redisLoadLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrSamplesLoading
+	}
+	version := strconv.FormatInt(time.Now().UnixNano(), 10)
+	s := &redisSampleSink{
+		r:         r,
+		tableName: tableName,
+		version:   version,
+		prefix:    redisVersionPrefix(tableName, version),
+		lockToken: token,
+		stats:     SampleStats{TableName: tableName, Group: group},
+		rates:     [2]map[int64]struct{}{make(map[int64]struct{}), make(map[int64]struct{})},
+		stop:      make(chan struct{}),
+	}
+	go s.keepLock()
+	if merge {
+		if err := s.appendToCurrent(ctx); err != nil {
+			s.unlock()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// 有当前版本时直接写入当前版本，统计在原有的基础上累加
+func (s *redisSampleSink) appendToCurrent(ctx context.Context) error {
+	version, err := s.r.redisClient.Get(ctx, redisVersionKey(s.tableName)).Result()
+	if errors.Is(err, redisClient.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.version = version
+	s.prefix = redisVersionPrefix(s.tableName, version)
+	s.appending = true
+
+	if data, err := s.r.redisClient.Get(ctx, s.prefix+":stats").Bytes(); err == nil {
+		var stats SampleStats
+		if json.Unmarshal(data, &stats) == nil {
+			s.stats = stats
+		}
+	}
+	for mode := range s.rates {
+		members, err := s.r.redisClient.SMembers(ctx, redisRatesKey(s.prefix, mode == 1)).Result()
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			if rate, err := strconv.ParseInt(member, 10, 64); err == nil {
+				s.rates[mode][rate] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+// 加载期间定时续期，锁被其它节点拿走后停止写入
+func (s *redisSampleSink) keepLock() {
+	ticker := time.NewTicker(redisLoadLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.refreshLock(context.Background()); err != nil {
+				log.Printf("slot: refresh samples lock %s failed: %v", s.tableName, err)
+				if errors.Is(err, ErrLoadLockLost) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *redisSampleSink) refreshLock(ctx context.Context) error {
+	n, err := redisRefreshLockScript.Run(ctx, s.r.redisClient, []string{redisLockKey(s.tableName)},
+		s.lockToken, redisLoadLockTTL.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		s.lockLost.Store(true)
+		return ErrLoadLockLost
+	}
+	return nil
+}
+
+func (s *redisSampleSink) add(ctx context.Context, records []SpinData) error {
+	if s.lockLost.Load() {
+		return ErrLoadLockLost
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	type bucketKey struct {
+		special bool
+		rate    int64
+	}
+	buckets := make(map[bucketKey][]interface{})
+	for _, rec := range records {
+		key := bucketKey{special: rec.GameType == 1, rate: rateToFixed(rec.Rate)}
+		buckets[key] = append(buckets[key], uint64(rec.ID))
+	}
+
+	pipe := s.r.redisClient.Pipeline()
+	added := make(map[bucketKey]*redisClient.IntCmd, len(buckets))
+	for key, ids := range buckets {
+		added[key] = pipe.SAdd(ctx, redisRateKey(s.prefix, key.special, key.rate), ids...)
+		mode := 0
+		if key.special {
+			mode = 1
+		}
+		if _, ok := s.rates[mode][key.rate]; !ok {
+			s.rates[mode][key.rate] = struct{}{}
+			pipe.SAdd(ctx, redisRatesKey(s.prefix, key.special), key.rate)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("slot: write samples to redis: %w", err)
+	}
+	// 按SADD实际加入的数量统计，追加时重复的id不计入
+	for key, cmd := range added {
+		n := int(cmd.Val())
+		if key.special {
+			s.stats.SpecialSamples += n
+		} else {
+			s.stats.NormalSamples += n
+		}
+		s.stats.Rows += n
+	}
+	return nil
+}
+
+func (s *redisSampleSink) commit(ctx context.Context, start time.Time) (SampleStats, error) {
+	defer s.unlock()
+
+	// 切换版本之前确认锁还在并续期，避免覆盖其它节点的加载
+	if err := s.refreshLock(ctx); err != nil {
+		s.discard()
+		return SampleStats{}, err
+	}
+
+	s.stats.NormalRates = len(s.rates[0])
+	s.stats.SpecialRates = len(s.rates[1])
+	s.stats.LoadedAt = time.Now()
+	s.stats.Duration = s.stats.LoadedAt.Sub(start)
+	data, err := json.Marshal(s.stats)
+	if err != nil {
+		s.discard()
+		return SampleStats{}, err
+	}
+	if err := s.r.redisClient.Set(ctx, s.prefix+":stats", data, 0).Err(); err != nil {
+		s.discard()
+		return SampleStats{}, err
+	}
+	if s.appending {
+		return s.stats, nil
+	}
+
+	// 切换版本，旧版本延迟过期
+	old, err := s.r.redisClient.SetArgs(ctx, redisVersionKey(s.tableName), s.version, redisClient.SetArgs{Get: true}).Result()
+	if err != nil && !errors.Is(err, redisClient.Nil) {
+		s.discard()
+		return SampleStats{}, err
+	}
+	s.r.redisVersions.Store(s.tableName, &redisVersion{version: s.version, fetchedAt: time.Now()})
+	if old != "" && old != s.version {
+		if err := s.r.expireRedisVersion(ctx, s.tableName, old, redisOldVersionGrace); err != nil {
+			log.Printf("slot: expire old samples %s v%s failed: %v", s.tableName, old, err)
+		}
+	}
+	return s.stats, nil
+}
+
+func (s *redisSampleSink) abort() {
+	s.discard()
+	s.unlock()
+}
+
+// 丢弃未切换的新版本数据，追加到当前版本时已写入的样本无法区分，保留
+func (s *redisSampleSink) discard() {
+	if s.appending {
+		return
+	}
+	ctx := context.Background()
+	pipe := s.r.redisClient.Pipeline()
+	for mode, rates := range s.rates {
+		for rate := range rates {
+			pipe.Del(ctx, redisRateKey(s.prefix, mode == 1, rate))
+		}
+		pipe.Del(ctx, redisRatesKey(s.prefix, mode == 1))
+	}
+	pipe.Del(ctx, s.prefix+":stats")
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("slot: discard samples %s v%s failed: %v", s.tableName, s.version, err)
+	}
+}
+
+func (s *redisSampleSink) unlock() {
+	s.once.Do(func() {
+		close(s.stop)
+		err := redisUnlockScript.Run(context.Background(), s.r.redisClient, []string{redisLockKey(s.tableName)}, s.lockToken).Err()
+		if err != nil {
+			log.Printf("slot: unlock samples %s failed: %v", s.tableName, err)
+		}
+	})
+}
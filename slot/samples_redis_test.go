@@ -0,0 +1,196 @@
+package slot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redisClient "github.com/redis/go-redis/v9"
+)
+
+func newRedisRtp(t *testing.T) (*Rtp, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redisClient.NewClient(&redisClient.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &Rtp{brand: "pg", redisClient: client, rtpConfig: new(sync.Map)}, mr
+}
+
+func TestRedisSamplesCommit(t *testing.T) {
+	r, mr := newRedisRtp(t)
+	db := newSampleDB(t)
+	db.Exec("INSERT INTO pg_spin_98 (id, group_id, rate, gameType) VALUES (1, 1, 0, 0), (2, 2, 1.5, 0), (3, 3, 30, 1)")
+
+	group := false
+	stats, err := r.LoadSamplesWithOptions(context.Background(), db, "98", SampleLoadOptions{PageSize: 2, Group: &group})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 3 || stats.NormalRates != 2 || stats.SpecialRates != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if ok, err := r.HasCacheSimpleRate("98"); err != nil || !ok {
+		t.Fatalf("expected samples in redis: %v %v", ok, err)
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 1.5); err != nil || id != uint(2) {
+		t.Fatalf("rate 1.5: id=%v err=%v", id, err)
+	}
+	if rate := r.GetRoundRate("98", true); rate != 30 {
+		t.Fatalf("special round rate = %v", rate)
+	}
+	if got, ok := r.SampleStats("98"); !ok || got.Rows != 3 {
+		t.Fatalf("stats from redis: %+v %v", got, ok)
+	}
+	if mr.Exists(redisLockKey("pg_spin_98")) {
+		t.Fatal("lock should be released after commit")
+	}
+}
+
+func TestRedisSamplesVersionSwitch(t *testing.T) {
+	r, mr := newRedisRtp(t)
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 1, Rate: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	oldVersion, _ := mr.Get(redisVersionKey("pg_spin_98"))
+
+	db := newSampleDB(t)
+	db.Exec("INSERT INTO pg_spin_98 (id, group_id, rate, gameType) VALUES (5, 5, 2, 0)")
+	group := false
+	if _, err := r.LoadSamplesWithOptions(context.Background(), db, "98", SampleLoadOptions{Group: &group}); err != nil {
+		t.Fatal(err)
+	}
+	newVersion, _ := mr.Get(redisVersionKey("pg_spin_98"))
+	if newVersion == "" || newVersion == oldVersion {
+		t.Fatalf("version should switch: %q -> %q", oldVersion, newVersion)
+	}
+
+	// 旧版本延迟过期，其它节点缓存的旧版本号在宽限期内还能读
+	oldRateKey := redisRateKey(redisVersionPrefix("pg_spin_98", oldVersion), false, rateToFixed(1.5))
+	if ttl := mr.TTL(oldRateKey); ttl <= 0 || ttl > redisOldVersionGrace {
+		t.Fatalf("old version ttl = %v", ttl)
+	}
+	if _, err := r.GetOneSimpleByRate("98", false, 1.5); err == nil {
+		t.Fatal("new version should not contain old rate")
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 2); err != nil || id != uint(5) {
+		t.Fatalf("rate 2: id=%v err=%v", id, err)
+	}
+	mr.FastForward(redisOldVersionGrace)
+	if mr.Exists(oldRateKey) {
+		t.Fatal("old version should expire")
+	}
+}
+
+func TestRedisSamplesMerge(t *testing.T) {
+	r, _ := newRedisRtp(t)
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 1, Rate: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AppendSimpleByRate("98", []SpinData{{ID: 2, Rate: 2}, {ID: 3, Rate: 30, GameType: 1}, {ID: 1, Rate: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 1.5); err != nil || id != uint(1) {
+		t.Fatalf("first batch: id=%v err=%v", id, err)
+	}
+	if id, err := r.GetOneSimpleByRate("98", true, 30); err != nil || id != uint(3) {
+		t.Fatalf("second batch: id=%v err=%v", id, err)
+	}
+	if stats, _ := r.SampleStats("98"); stats.Rows != 3 || stats.NormalRates != 2 || stats.SpecialRates != 1 || stats.NormalSamples != 2 {
+		t.Fatalf("unexpected merged stats: %+v", stats)
+	}
+
+	// 不追加时整体替换
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 4, Rate: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetOneSimpleByRate("98", false, 1.5); err == nil {
+		t.Fatal("replaced cache should not contain old rate")
+	}
+}
+
+func TestRedisSamplesAbort(t *testing.T) {
+	r, mr := newRedisRtp(t)
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 1, Rate: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	current, _ := mr.Get(redisVersionKey("pg_spin_98"))
+	before := len(mr.Keys())
+
+	ctx := context.Background()
+	sink, err := r.newSampleSink(ctx, "pg_spin_98", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.add(ctx, []SpinData{{ID: 7, Rate: 9}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.abort()
+
+	if version, _ := mr.Get(redisVersionKey("pg_spin_98")); version != current {
+		t.Fatalf("abort should keep version %q, got %q", current, version)
+	}
+	if after := len(mr.Keys()); after != before {
+		t.Fatalf("abort should discard new keys: %d -> %d, %v", before, after, mr.Keys())
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 1.5); err != nil || id != uint(1) {
+		t.Fatalf("current version should still be readable: id=%v err=%v", id, err)
+	}
+}
+
+func TestRedisSamplesLockContention(t *testing.T) {
+	r, mr := newRedisRtp(t)
+	ctx := context.Background()
+	lockKey := redisLockKey("pg_spin_98")
+
+	first, err := r.newRedisSampleSink(ctx, "pg_spin_98", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.newRedisSampleSink(ctx, "pg_spin_98", false, false); !errors.Is(err, ErrSamplesLoading) {
+		t.Fatalf("expected ErrSamplesLoading, got %v", err)
+	}
+
+	// 续期后锁的过期时间重置
+	mr.FastForward(redisLoadLockTTL / 2)
+	if err := first.refreshLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := mr.TTL(lockKey); ttl != redisLoadLockTTL {
+		t.Fatalf("lock ttl after refresh = %v", ttl)
+	}
+
+	// 锁过期后被其它节点拿到，原来的加载方不能提交，也不能删除别人的锁
+	mr.FastForward(redisLoadLockTTL + time.Second)
+	second, err := r.newRedisSampleSink(ctx, "pg_spin_98", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.add(ctx, []SpinData{{ID: 1, Rate: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.commit(ctx, time.Now()); !errors.Is(err, ErrLoadLockLost) {
+		t.Fatalf("expected ErrLoadLockLost, got %v", err)
+	}
+	if token, _ := mr.Get(lockKey); token != second.lockToken {
+		t.Fatalf("lock of the new loader was removed, token %q", token)
+	}
+	if mr.Exists(redisVersionKey("pg_spin_98")) {
+		t.Fatal("lost loader should not switch version")
+	}
+	if err := first.add(ctx, []SpinData{{ID: 2, Rate: 1}}); !errors.Is(err, ErrLoadLockLost) {
+		t.Fatalf("expected ErrLoadLockLost after losing the lock, got %v", err)
+	}
+
+	if err := second.add(ctx, []SpinData{{ID: 3, Rate: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.commit(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists(lockKey) {
+		t.Fatal("lock should be released")
+	}
+}
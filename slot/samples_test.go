@@ -0,0 +1,188 @@
+package slot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCacheSimpleByRateIndex(t *testing.T) {
+	r := NewLocalRtp("pg")
+	if ok, _ := r.HasCacheSimpleRate("98"); ok {
+		t.Fatal("empty rtp should have no cache")
+	}
+
+	err := r.CacheSimpleByRate("98", []SpinData{
+		{ID: 1, Rate: 0},
+		{ID: 2, Rate: 0},
+		{ID: 3, Rate: 1.5},
+		{ID: 4, Rate: 30, GameType: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := r.HasCacheSimpleRate("98"); !ok {
+		t.Fatal("expected cache after CacheSimpleByRate")
+	}
+
+	stats, ok := r.SampleStats("98")
+	if !ok {
+		t.Fatal("expected sample stats")
+	}
+	if stats.TableName != "pg_spin_98" || stats.Rows != 4 || stats.NormalRates != 2 || stats.NormalSamples != 3 ||
+		stats.SpecialRates != 1 || stats.SpecialSamples != 1 || stats.MemoryBytes <= 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	for i := 0; i < 20; i++ {
+		id, err := r.GetOneSimpleByRate("98", false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != uint(1) && id != uint(2) {
+			t.Fatalf("unexpected id %v for rate 0", id)
+		}
+	}
+	if id, err := r.GetOneSimpleByRate("98", true, 30); err != nil || id != uint(4) {
+		t.Fatalf("special rate 30: id=%v err=%v", id, err)
+	}
+	if _, err := r.GetOneSimpleByRate("98", true, 1.5); err == nil {
+		t.Fatal("normal rate should not be found in special mode")
+	}
+	if rate := r.GetRoundRate("98", true); rate != 30 {
+		t.Fatalf("special round rate = %v, want 30", rate)
+	}
+
+	// 重复缓存相同的数据结果不变
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 3, Rate: 1.5}, {ID: 4, Rate: 30, GameType: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 3, Rate: 1.5}, {ID: 4, Rate: 30, GameType: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := r.SampleStats("98"); stats.Rows != 2 || stats.NormalSamples != 1 || stats.SpecialSamples != 1 {
+		t.Fatalf("cache should be replaced: %+v", stats)
+	}
+
+	// 追加到已有的索引，重复的id不会加入
+	if err := r.AppendSimpleByRate("98", []SpinData{{ID: 9, Rate: 2}, {ID: 10, Rate: 1.5}, {ID: 3, Rate: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := r.GetOneSimpleByRate("98", true, 30); err != nil || id != uint(4) {
+		t.Fatalf("first batch should be kept: id=%v err=%v", id, err)
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 2); err != nil || id != uint(9) {
+		t.Fatalf("second batch rate 2: id=%v err=%v", id, err)
+	}
+	seen := map[interface{}]bool{}
+	for i := 0; i < 100; i++ {
+		id, _ := r.GetOneSimpleByRate("98", false, 1.5)
+		seen[id] = true
+	}
+	if !seen[uint(3)] || !seen[uint(10)] || len(seen) != 2 {
+		t.Fatalf("rate 1.5 should contain both batches: %v", seen)
+	}
+	if stats, _ := r.SampleStats("98"); stats.Rows != 4 || stats.NormalRates != 2 || stats.NormalSamples != 3 {
+		t.Fatalf("unexpected merged stats: %+v", stats)
+	}
+
+	// 清除后重新缓存，旧的rate不再残留
+	if err := r.ClearCacheByRate("98"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CacheSimpleByRate("98", []SpinData{{ID: 11, Rate: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetOneSimpleByRate("98", false, 1.5); err == nil {
+		t.Fatal("stale rate should be gone after clear")
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 2); err != nil || id != uint(11) {
+		t.Fatalf("reloaded rate 2: id=%v err=%v", id, err)
+	}
+
+	if err := r.ClearCacheByRate("98"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := r.HasCacheSimpleRate("98"); ok {
+		t.Fatal("cache should be cleared")
+	}
+}
+
+func newSampleDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec("CREATE TABLE pg_spin_98 (id INTEGER PRIMARY KEY, group_id INTEGER, rate REAL, gameType INTEGER)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLoadSamplesPaging(t *testing.T) {
+	db := newSampleDB(t)
+	for id := 1; id <= 7; id++ {
+		gameType := 0
+		if id == 7 {
+			gameType = 1
+		}
+		db.Exec("INSERT INTO pg_spin_98 (id, group_id, rate, gameType) VALUES (?, ?, ?, ?)", id, id, float64(id%3), gameType)
+	}
+
+	r := NewLocalRtp("pg")
+	group := false
+	// 每页3条，需要翻3页，最后一页不满
+	stats, err := r.LoadSamplesWithOptions(context.Background(), db, "98", SampleLoadOptions{PageSize: 3, Group: &group})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 7 || stats.NormalSamples != 6 || stats.NormalRates != 3 || stats.SpecialSamples != 1 || stats.Group {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if id, err := r.GetOneSimpleByRate("98", true, 1); err != nil || id != uint(7) {
+		t.Fatalf("special sample: id=%v err=%v", id, err)
+	}
+
+	// 页大小正好整除时最后多查一次空页
+	stats, err = r.LoadSamplesWithOptions(context.Background(), db, "98", SampleLoadOptions{PageSize: 7, Group: &group})
+	if err != nil || stats.Rows != 7 {
+		t.Fatalf("exact page: %+v %v", stats, err)
+	}
+}
+
+func TestLoadSamplesGroup(t *testing.T) {
+	db := newSampleDB(t)
+	// 组10的有效倍率为组内最大的2.5，组11只有一行，组12是特殊模式
+	rows := [][4]interface{}{
+		{1, 10, 0.5, 0}, {2, 10, 2.5, 0}, {3, 10, 0, 0},
+		{4, 11, 1.5, 0},
+		{5, 12, 0, 1}, {6, 12, 8, 1},
+	}
+	for _, row := range rows {
+		db.Exec("INSERT INTO pg_spin_98 (id, group_id, rate, gameType) VALUES (?, ?, ?, ?)", row[:]...)
+	}
+
+	r := NewLocalRtp("pg")
+	group := true
+	stats, err := r.LoadSamplesWithOptions(context.Background(), db, "98", SampleLoadOptions{PageSize: 2, Group: &group})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rows != 3 || stats.NormalRates != 2 || stats.SpecialRates != 1 || !stats.Group {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if id, err := r.GetOneSimpleByRate("98", false, 2.5); err != nil || id != uint(10) {
+		t.Fatalf("group 10: id=%v err=%v", id, err)
+	}
+	if id, err := r.GetOneSimpleByRate("98", true, 8); err != nil || id != uint(12) {
+		t.Fatalf("group 12: id=%v err=%v", id, err)
+	}
+	if _, err := r.GetOneSimpleByRate("98", false, 0.5); err == nil {
+		t.Fatal("rows inside a group should not be indexed separately")
+	}
+}
@@ -0,0 +1,122 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/card-engine/game_common/slot"
+)
+
+// 读取consul中RtpConfig对应的json(同aigc/<brand>/<gi>的value)
+func LoadConfig(path string) (*slot.RtpConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config slot.RtpConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse rtp config %s: %w", path, err)
+	}
+	if len(config.Data) == 0 {
+		return nil, fmt.Errorf("rtp config %s has no tier", path)
+	}
+	return &config, nil
+}
+
+// 读取样本导出文件，根据扩展名区分csv和json
+func LoadSamples(path string) ([]slot.SpinData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ReadSamplesCSV(f)
+	case ".json":
+		return ReadSamplesJSON(f)
+	default:
+		return nil, fmt.Errorf("unsupported sample file %s, need .csv or .json", path)
+	}
+}
+
+// json格式: [{"id":1,"rate":0.5,"gameType":0}, ...]
+func ReadSamplesJSON(r io.Reader) ([]slot.SpinData, error) {
+	var rows []struct {
+		ID       uint    `json:"id"`
+		Rate     float64 `json:"rate"`
+		GameType int     `json:"gameType"`
+	}
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return nil, err
+	}
+	samples := make([]slot.SpinData, 0, len(rows))
+	for _, row := range rows {
+		samples = append(samples, slot.SpinData{ID: row.ID, Rate: row.Rate, GameType: row.GameType})
+	}
+	return samples, nil
+}
+
+// csv格式，第一行为表头，需要包含id,rate,gameType三列(顺序不限，大小写不敏感)
+func ReadSamplesCSV(r io.Reader) ([]slot.SpinData, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty sample csv")
+		}
+		return nil, err
+	}
+	idIdx, rateIdx, typeIdx := -1, -1, -1
+	for i, col := range header {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case "id":
+			idIdx = i
+		case "rate":
+			rateIdx = i
+		case "gametype", "game_type":
+			typeIdx = i
+		}
+	}
+	if idIdx < 0 || rateIdx < 0 || typeIdx < 0 {
+		return nil, fmt.Errorf("sample csv header must contain id,rate,gameType, got %v", header)
+	}
+
+	var samples []slot.SpinData
+	line := 1
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line++
+
+		id, err := strconv.ParseUint(strings.TrimSpace(record[idIdx]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid id %q", line, record[idIdx])
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[rateIdx]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[rateIdx])
+		}
+		gameType, err := strconv.Atoi(strings.TrimSpace(record[typeIdx]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid gameType %q", line, record[typeIdx])
+		}
+		samples = append(samples, slot.SpinData{ID: uint(id), Rate: rate, GameType: gameType})
+	}
+	return samples, nil
+}
@@ -0,0 +1,102 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+type Report struct {
+	Use         string        `json:"use"`
+	Spins       int           `json:"spins"`
+	ConfidenceZ float64       `json:"confidence_z"`
+	Seed        uint64        `json:"seed,omitempty"`
+	Tiers       []*TierReport `json:"tiers"`
+}
+
+type TierReport struct {
+	Tier             string    `json:"tier"`
+	NominalRtp       float64   `json:"nominal_rtp"`  // 档位名对应的rtp
+	ExpectedRtp      float64   `json:"expected_rtp"` // 按权重计算的理论rtp
+	RealizedRtp      float64   `json:"realized_rtp"` // 模拟得到的rtp
+	CILow            float64   `json:"ci_low"`
+	CIHigh           float64   `json:"ci_high"`
+	HitFrequency     float64   `json:"hit_frequency"`
+	Volatility       float64   `json:"volatility"` // 单局赢分的标准差
+	MaxWin           float64   `json:"max_win"`
+	SpecialRate      float64   `json:"special_rate"`      // 配置的特殊模式触发率
+	SpecialFrequency float64   `json:"special_frequency"` // 实际触发率
+	Spins            int       `json:"spins"`
+	SpecialSpins     int       `json:"special_spins"`
+	Misses           int       `json:"misses"` // 取不到样本的次数
+	MissingNormal    []float64 `json:"missing_normal,omitempty"`
+	MissingSpecial   []float64 `json:"missing_special,omitempty"`
+	Pass             bool      `json:"pass"`
+	Problems         []string  `json:"problems,omitempty"`
+}
+
+// 认证规则: 所有配置的rate都有样本；理论rtp与名义rtp误差在容忍范围内；名义rtp落在模拟结果的置信区间内
+func (t *TierReport) certify(tolerance float64) {
+	if len(t.MissingNormal) > 0 {
+		t.Problems = append(t.Problems, fmt.Sprintf("normal rates without samples: %v", t.MissingNormal))
+	}
+	if len(t.MissingSpecial) > 0 {
+		t.Problems = append(t.Problems, fmt.Sprintf("special rates without samples: %v", t.MissingSpecial))
+	}
+	if t.Spins == 0 {
+		t.Problems = append(t.Problems, "no spin succeeded")
+	}
+	if t.NominalRtp > 0 {
+		if math.Abs(t.ExpectedRtp-t.NominalRtp) > tolerance {
+			t.Problems = append(t.Problems, fmt.Sprintf("expected rtp %.4f deviates from nominal %.4f", t.ExpectedRtp, t.NominalRtp))
+		}
+		if t.Spins > 0 && (t.NominalRtp < t.CILow || t.NominalRtp > t.CIHigh) {
+			t.Problems = append(t.Problems, fmt.Sprintf("nominal rtp %.4f outside confidence interval [%.4f, %.4f]", t.NominalRtp, t.CILow, t.CIHigh))
+		}
+	}
+	t.Pass = len(t.Problems) == 0
+}
+
+func (r *Report) Pass() bool {
+	for _, t := range r.Tiers {
+		if !t.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *Report) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "spins per tier: %d, use: %s, z: %.2f\n\n", r.Spins, r.Use, r.ConfidenceZ)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "tier\tnominal\texpected\trealized\tci_low\tci_high\thit_freq\tvolatility\tmax_win\tspecial\tmisses\tresult\t")
+	for _, t := range r.Tiers {
+		result := "PASS"
+		if !t.Pass {
+			result = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%.2f\t%.4f\t%d\t%s\t\n",
+			t.Tier, t.NominalRtp, t.ExpectedRtp, t.RealizedRtp, t.CILow, t.CIHigh,
+			t.HitFrequency, t.Volatility, t.MaxWin, t.SpecialFrequency, t.Misses, result)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, t := range r.Tiers {
+		if len(t.Problems) > 0 {
+			fmt.Fprintf(w, "\ntier %s:\n  %s\n", t.Tier, strings.Join(t.Problems, "\n  "))
+		}
+	}
+	return nil
+}
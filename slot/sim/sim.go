@@ -0,0 +1,330 @@
+// 离线rtp模拟器: 使用线上同样的选样逻辑(IsSpecialModeTriggered -> GetRateByWeight -> GetOneSimpleByRate)
+// 跑N次spin，统计每个rtp档位的实际回报，配置推到生产之前先用它验证。
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/card-engine/game_common/slot"
+)
+
+const (
+	simBrand = "sim"
+	simGi    = "0"
+
+	DefaultSpins = 1_000_000
+	// 95%置信区间
+	DefaultConfidenceZ = 1.96
+	// 配置期望rtp和档位名义rtp之间允许的误差
+	DefaultTolerance = 0.005
+)
+
+type Options struct {
+	Spins       int      // 每个档位模拟的次数
+	Workers     int      // 并发数，默认为cpu核数
+	Tiers       []string // 只模拟指定档位，为空则模拟全部
+	ConfidenceZ float64  // 置信区间的z值
+	Tolerance   float64  // 期望rtp与名义rtp允许的误差
+	Seed        uint64   // 随机数种子，非0时同样的参数得到同样的结果
+}
+
+func (o *Options) normalize() {
+	if o.Spins <= 0 {
+		o.Spins = DefaultSpins
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.ConfidenceZ <= 0 {
+		o.ConfidenceZ = DefaultConfidenceZ
+	}
+	if o.Tolerance <= 0 {
+		o.Tolerance = DefaultTolerance
+	}
+}
+
+type Simulator struct {
+	config  *slot.RtpConfig
+	rtp     *slot.Rtp
+	idRates map[uint]float64
+
+	// 每种模式下有样本的rate
+	normalRates  map[int64]struct{}
+	specialRates map[int64]struct{}
+}
+
+func New(config *slot.RtpConfig, samples []slot.SpinData) (*Simulator, error) {
+	if config == nil || len(config.Data) == 0 {
+		return nil, errors.New("rtp config is empty")
+	}
+	if len(samples) == 0 {
+		return nil, errors.New("no samples")
+	}
+
+	rtp := slot.NewLocalRtp(simBrand)
+	rtp.SetRtpConfig(simGi, config)
+	if err := rtp.CacheSimpleByRate(simGi, samples); err != nil {
+		return nil, err
+	}
+
+	s := &Simulator{
+		config:       config,
+		rtp:          rtp,
+		idRates:      make(map[uint]float64, len(samples)),
+		normalRates:  make(map[int64]struct{}),
+		specialRates: make(map[int64]struct{}),
+	}
+	for _, rec := range samples {
+		s.idRates[rec.ID] = rec.Rate
+		if rec.GameType == 1 {
+			s.specialRates[rateKey(rec.Rate)] = struct{}{}
+		} else {
+			s.normalRates[rateKey(rec.Rate)] = struct{}{}
+		}
+	}
+	return s, nil
+}
+
+// 与Rtp中样本索引使用相同的精度(6位小数定点)
+func rateKey(rate float64) int64 {
+	return int64(math.Round(rate * 1_000_000))
+}
+
+// 按档位名从小到大模拟
+func (s *Simulator) Run(opts Options) (*Report, error) {
+	opts.normalize()
+
+	tiers := opts.Tiers
+	if len(tiers) == 0 {
+		for tier := range s.config.Data {
+			tiers = append(tiers, tier)
+		}
+	}
+	sortTiers(tiers)
+
+	report := &Report{Spins: opts.Spins, ConfidenceZ: opts.ConfidenceZ, Seed: opts.Seed, Use: s.config.Use}
+	for _, tier := range tiers {
+		rateConfig, ok := s.rtp.GetRtpConfig(simGi, tier)
+		if !ok {
+			return nil, fmt.Errorf("tier %s not found in rtp config", tier)
+		}
+		tr, err := s.runTier(tier, rateConfig, opts)
+		if err != nil {
+			return nil, fmt.Errorf("tier %s: %w", tier, err)
+		}
+		report.Tiers = append(report.Tiers, tr)
+	}
+	return report, nil
+}
+
+// 单个worker的统计
+type accumulator struct {
+	spins        int
+	specialSpins int
+	hits         int
+	misses       int
+	sum          float64
+	sumSq        float64
+	maxWin       float64
+}
+
+func (a *accumulator) merge(b *accumulator) {
+	a.spins += b.spins
+	a.specialSpins += b.specialSpins
+	a.hits += b.hits
+	a.misses += b.misses
+	a.sum += b.sum
+	a.sumSq += b.sumSq
+	a.maxWin = max(a.maxWin, b.maxWin)
+}
+
+func (s *Simulator) runTier(tier string, rateConfig *slot.RateConfig, opts Options) (*TierReport, error) {
+	normalWeights, err := s.rtp.LoadWeightsFromJSON(rateConfig, false)
+	if err != nil {
+		return nil, err
+	}
+	if specialEnabled(rateConfig) {
+		if _, err := s.rtp.LoadWeightsFromJSON(rateConfig, true); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkWeights(rateConfig); err != nil {
+		return nil, err
+	}
+
+	workers := min(opts.Workers, opts.Spins)
+	accs := make([]accumulator, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		n := opts.Spins / workers
+		if w < opts.Spins%workers {
+			n++
+		}
+		// 每个worker使用独立的随机数，固定种子时结果与调度无关
+		var rng *rand.Rand
+		if opts.Seed != 0 {
+			rng = rand.New(rand.NewPCG(opts.Seed, uint64(w)))
+		}
+		wg.Add(1)
+		go func(acc *accumulator, rng *rand.Rand, n int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				s.spin(rateConfig, normalWeights, rng, acc)
+			}
+		}(&accs[w], rng, n)
+	}
+	wg.Wait()
+
+	total := &accumulator{}
+	for i := range accs {
+		total.merge(&accs[i])
+	}
+
+	tr := &TierReport{
+		Tier:         tier,
+		NominalRtp:   nominalRtp(tier),
+		SpecialRate:  rateConfig.Rate,
+		Spins:        total.spins,
+		SpecialSpins: total.specialSpins,
+		Misses:       total.misses,
+		MaxWin:       total.maxWin,
+	}
+	tr.ExpectedRtp, tr.MissingNormal, tr.MissingSpecial = s.expectedRtp(rateConfig)
+
+	if n := float64(total.spins); n > 0 {
+		mean := total.sum / n
+		variance := max(total.sumSq/n-mean*mean, 0)
+		tr.RealizedRtp = mean
+		tr.HitFrequency = float64(total.hits) / n
+		tr.SpecialFrequency = float64(total.specialSpins) / n
+		tr.Volatility = math.Sqrt(variance)
+		half := opts.ConfidenceZ * tr.Volatility / math.Sqrt(n)
+		tr.CILow = mean - half
+		tr.CIHigh = mean + half
+	}
+	tr.certify(opts.Tolerance)
+	return tr, nil
+}
+
+// 与线上选样流程一致，押注按1计算，赢分即样本倍率
+func (s *Simulator) spin(rateConfig *slot.RateConfig, normalWeights []slot.RateWeight, rng *rand.Rand, acc *accumulator) {
+	isSpecial := slot.SpecialModeTriggered(rateConfig, rng)
+	weights := normalWeights
+	if isSpecial {
+		weights = rateConfig.Special
+	}
+	rate := slot.RateByWeight(weights, rng)
+
+	ret, err := s.rtp.GetOneSimpleByRate(simGi, isSpecial, rate)
+	if err != nil || ret == nil {
+		acc.misses++
+		return
+	}
+	id, ok := ret.(uint)
+	if !ok {
+		acc.misses++
+		return
+	}
+	win := s.idRates[id]
+
+	acc.spins++
+	if isSpecial {
+		acc.specialSpins++
+	}
+	if win > 0 {
+		acc.hits++
+	}
+	acc.sum += win
+	acc.sumSq += win * win
+	acc.maxWin = max(acc.maxWin, win)
+}
+
+// GetRateByWeight在总权重<=0时会panic，提前拦截
+func checkWeights(rateConfig *slot.RateConfig) error {
+	check := func(name string, weights []slot.RateWeight) error {
+		total := 0
+		for _, rw := range weights {
+			if rw.Weighting < 0 {
+				return fmt.Errorf("%s rate %.6f has negative weighting", name, rw.Rate)
+			}
+			total += rw.Weighting
+		}
+		if total <= 0 {
+			return fmt.Errorf("%s total weighting is 0", name)
+		}
+		return nil
+	}
+	if err := check("normal", rateConfig.Normal); err != nil {
+		return err
+	}
+	if specialEnabled(rateConfig) {
+		return check("special", rateConfig.Special)
+	}
+	return nil
+}
+
+// 与IsSpecialModeTriggered一致，触发率不在(0,1]内视为不触发
+func specialEnabled(rateConfig *slot.RateConfig) bool {
+	return rateConfig.Rate > 0 && rateConfig.Rate <= 1
+}
+
+// 按权重直接计算的理论rtp，同时返回配置了权重但没有样本的rate
+func (s *Simulator) expectedRtp(rateConfig *slot.RateConfig) (float64, []float64, []float64) {
+	modeRtp := func(weights []slot.RateWeight, rates map[int64]struct{}) (float64, []float64) {
+		var missing []float64
+		total, sum := 0, 0.0
+		for _, rw := range weights {
+			// 没有样本的rate线上会取样失败，不计入期望(模拟时记为misses)
+			if _, ok := rates[rateKey(rw.Rate)]; !ok {
+				if rw.Weighting > 0 {
+					missing = append(missing, rw.Rate)
+				}
+				continue
+			}
+			total += rw.Weighting
+			sum += rw.Rate * float64(rw.Weighting)
+		}
+		if total == 0 {
+			return 0, missing
+		}
+		return sum / float64(total), missing
+	}
+
+	normal, missingNormal := modeRtp(rateConfig.Normal, s.normalRates)
+	if !specialEnabled(rateConfig) {
+		return normal, missingNormal, nil
+	}
+	special, missingSpecial := modeRtp(rateConfig.Special, s.specialRates)
+	p := rateConfig.Rate
+	return (1-p)*normal + p*special, missingNormal, missingSpecial
+}
+
+// 档位名即rtp百分比，例如"95"表示0.95，非数字的档位返回0
+func nominalRtp(tier string) float64 {
+	v, err := strconv.ParseFloat(tier, 64)
+	if err != nil {
+		return 0
+	}
+	return v / 100
+}
+
+func sortTiers(tiers []string) {
+	sort.Slice(tiers, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(tiers[i], 64)
+		b, errB := strconv.ParseFloat(tiers[j], 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		if errA == nil || errB == nil {
+			return errA == nil
+		}
+		return tiers[i] < tiers[j]
+	})
+}
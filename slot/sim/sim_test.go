@@ -0,0 +1,130 @@
+package sim
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/card-engine/game_common/slot"
+)
+
+const testConfig = `{
+	"use": "95",
+	"95": {
+		"rate": 0.1,
+		"normal": [{"rate": 0, "weighting": 1}, {"rate": 1, "weighting": 1}],
+		"special": [{"rate": 4.5, "weighting": 1}]
+	},
+	"50": {
+		"rate": 0,
+		"normal": [{"rate": 0, "weighting": 1}, {"rate": 1, "weighting": 1}, {"rate": 3, "weighting": 0}]
+	}
+}`
+
+func testSamples() []slot.SpinData {
+	return []slot.SpinData{
+		{ID: 1, Rate: 0, GameType: 0},
+		{ID: 2, Rate: 0, GameType: 0},
+		{ID: 3, Rate: 1, GameType: 0},
+		{ID: 4, Rate: 4.5, GameType: 1},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	var config slot.RtpConfig
+	if err := json.Unmarshal([]byte(testConfig), &config); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(&config, testSamples())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 固定种子，置信区间的检查不会随机失败
+	report, err := s.Run(Options{Spins: 200_000, Workers: 4, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Tiers) != 2 || report.Tiers[0].Tier != "50" || report.Tiers[1].Tier != "95" {
+		t.Fatalf("unexpected tiers: %+v", report.Tiers)
+	}
+
+	low, high := report.Tiers[0], report.Tiers[1]
+
+	// 0.9*0.5 + 0.1*4.5 = 0.9
+	if math.Abs(high.ExpectedRtp-0.9) > 1e-9 {
+		t.Fatalf("expected rtp = %v, want 0.9", high.ExpectedRtp)
+	}
+	if high.Pass {
+		t.Fatal("tier 95 has expected rtp 0.9, should fail certification")
+	}
+	if high.RealizedRtp < high.CILow || high.RealizedRtp > high.CIHigh || high.CIHigh-high.CILow <= 0 {
+		t.Fatalf("bad confidence interval: %+v", high)
+	}
+	if math.Abs(high.RealizedRtp-0.9) > 0.05 || math.Abs(high.SpecialFrequency-0.1) > 0.01 {
+		t.Fatalf("realized stats far from theory: %+v", high)
+	}
+	if high.MaxWin != 4.5 || high.Misses != 0 {
+		t.Fatalf("unexpected max win or misses: %+v", high)
+	}
+
+	// 权重为0的rate没有样本不算缺失
+	if math.Abs(low.ExpectedRtp-0.5) > 1e-9 || len(low.MissingNormal) != 0 {
+		t.Fatalf("unexpected tier 50: %+v", low)
+	}
+	if !low.Pass || low.SpecialSpins != 0 {
+		t.Fatalf("tier 50 should pass: %+v", low)
+	}
+	if math.Abs(low.HitFrequency-0.5) > 0.01 {
+		t.Fatalf("hit frequency = %v, want ~0.5", low.HitFrequency)
+	}
+}
+
+func TestSimulateMissingRate(t *testing.T) {
+	config := &slot.RtpConfig{Use: "95", Data: map[string]slot.RateConfig{
+		"95": {Normal: []slot.RateWeight{{Rate: 0.95, Weighting: 1}, {Rate: 2, Weighting: 1}}},
+	}}
+	s, err := New(config, []slot.SpinData{{ID: 1, Rate: 0.95}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.Run(Options{Spins: 10_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := report.Tiers[0]
+	if tr.Pass || len(tr.MissingNormal) != 1 || tr.MissingNormal[0] != 2 || tr.Misses == 0 {
+		t.Fatalf("missing rate should fail: %+v", tr)
+	}
+	if math.Abs(tr.RealizedRtp-0.95) > 1e-9 || tr.Volatility > 1e-6 {
+		t.Fatalf("unexpected realized stats: %+v", tr)
+	}
+}
+
+func TestReadSamplesCSV(t *testing.T) {
+	samples, err := ReadSamplesCSV(strings.NewReader("rate,ID,game_type\n0.5,10,0\n12.25,11,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []slot.SpinData{{ID: 10, Rate: 0.5}, {ID: 11, Rate: 12.25, GameType: 1}}
+	if len(samples) != len(want) || samples[0] != want[0] || samples[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", samples, want)
+	}
+
+	if _, err := ReadSamplesCSV(strings.NewReader("id,rate\n1,2\n")); err == nil {
+		t.Fatal("expected error for missing gameType column")
+	}
+	if _, err := ReadSamplesCSV(strings.NewReader("id,rate,gameType\nx,1,0\n")); err == nil {
+		t.Fatal("expected error for invalid id")
+	}
+}
+
+func TestReadSamplesJSON(t *testing.T) {
+	samples, err := ReadSamplesJSON(strings.NewReader(`[{"id":1,"rate":0.2,"gameType":1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0] != (slot.SpinData{ID: 1, Rate: 0.2, GameType: 1}) {
+		t.Fatalf("unexpected samples: %+v", samples)
+	}
+}
@@ -1,53 +1,25 @@
 package spribe
 
 import (
-	"crypto/sha1"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/binary"
 	"fmt"
 	"math/rand"
-	"strings"
+
+	"github.com/card-engine/game_common/gamehub/fairness"
 )
 
-// 公平性的计算相关的逻辑，思路就是可以通过roundId做种子
+// 公平性的种子算法统一在 gamehub/fairness 中实现，这里只保留旧的入口
 
-// 你可以使用roundId做种子，这样子，查询也不需要存库，只需要重新计算一下就可以了
 // 生成基于哈希的服务器种子
+//
+// Deprecated: 使用 fairness.Engine，展示格式使用 fairness.SpribeFormatter。
 func GenerateServerSeedHashBased(seed int32) (string, error) {
-
-	rng := rand.New(rand.NewSource(int64(seed)))
-	randomBytes := make([]byte, 16)
-	for i := range randomBytes {
-		randomBytes[i] = byte(rng.Intn(256))
-	}
-	data := randomBytes
-	// 计算哈希
-	hash := sha256.Sum256(data)
-
-	// Base64 URL 编码
-	encoded := base64.URLEncoding.EncodeToString(hash[:])
-	// 去掉末尾的 '='
-	encoded = strings.TrimRight(encoded, "=")
-
-	return encoded, nil
+	return fairness.GenerateSpribeServerSeed(seed)
 }
 
 // GenerateHashBasedID 基于哈希生成ID
 // 再使用服务器的种子生成ID
 func GenerateHashBasedID(seed string) int64 {
-	data := []byte(seed)
-
-	hash := sha1.Sum(data)
-
-	// 取前4字节并转换为int32，然后加上基数
-	id := int64(binary.BigEndian.Uint32(hash[:4]))
-	baseValue := int64(3260000000)
-
-	resultID := baseValue + (id % 1000000) // 限制在合理范围内
-
-	// 转换为字符串返回
-	return resultID
+	return fairness.GenerateSpribeHashBasedID(seed)
 }
 
 // generateProfile 根据固定种子和数量，生成 profile 数据